@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/deployer"
+	"github.com/victorlunam/dbgo/internal/history"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// runApply executes a generated script against the target database.
+//
+//	dbgo apply <script.sql> [--dry-run] [--transaction] [--ignore-drift] [--history] [--var name=value]
+func runApply(args []string) {
+	isDryRun := checkFlag(&args, "--dry-run")
+	useTransaction := checkFlag(&args, "--transaction", "-t")
+	ignoreDrift := checkFlag(&args, "--ignore-drift")
+	recordHistory := checkFlag(&args, "--history")
+	varFlags := checkFlagValues(&args, "--var")
+
+	if len(args) != 1 {
+		color.Red("Usage: dbgo apply <script.sql> [--dry-run] [--transaction] [--ignore-drift] [--history] [--var name=value]")
+		os.Exit(1)
+	}
+	scriptPath := args[0]
+
+	appConfig, hasConfigFile := loadConfig()
+
+	// variables of the target environment, overridden by --var
+	variables := map[string]string{}
+	for name, value := range appConfig.Target.Variables {
+		variables[strings.ToLower(name)] = value
+	}
+	for _, varFlag := range varFlags {
+		name, value, ok := strings.Cut(varFlag, "=")
+		if !ok || name == "" {
+			color.Red("Invalid --var %q, expected name=value", varFlag)
+			os.Exit(1)
+		}
+		variables[strings.ToLower(name)] = value
+	}
+
+	script, err := deployer.ReadScript(scriptPath, appConfig.Target.Engine, variables)
+	if err != nil {
+		color.Red("%v", err)
+		os.Exit(1)
+	}
+
+	if useTransaction && script.Metadata.OwnTransaction {
+		color.Red("The script rebuilds tables in transactions of its own, which cannot run inside --transaction")
+		color.Red("Apply it without --transaction, each rebuild is rolled back on its own when it fails")
+		os.Exit(1)
+	}
+
+	if isDryRun {
+		color.Green("Dry run of '%s', %d batches would be executed:", scriptPath, len(script.Batches))
+		deployer.PrintPlan(script.Batches, useTransaction)
+		return
+	}
+
+	target := readDatabaseConfig("TARGET DATABASE", appConfig, hasConfigFile)
+
+	targetDB, err := database.Connect(target)
+	if err != nil {
+		color.Red("Error connecting to target database: %v", err)
+		os.Exit(1)
+	}
+	color.Green("Successfully connected to target database")
+	defer targetDB.Close()
+
+	deploy := deployer.NewDeployer(targetDB, appConfig.Compare, useTransaction)
+
+	if ignoreDrift {
+		color.Yellow("Skipping the drift check of %d target objects", len(script.Metadata.Fingerprints))
+		script.SkipDriftGuard()
+	} else {
+		drifts, err := deploy.VerifyFingerprints(script.Metadata.Fingerprints)
+		if err != nil {
+			color.Red("Error verifying the target database: %v", err)
+			targetDB.Close()
+			os.Exit(1)
+		}
+		if len(drifts) > 0 {
+			color.Red("The target database changed since the script was generated:")
+			for _, drift := range drifts {
+				color.Red("  - %s", drift)
+			}
+			color.Red("Compare the databases again or use --ignore-drift to apply the script anyway")
+			targetDB.Close()
+			os.Exit(1)
+		}
+		color.Green("Verified %d target objects against the comparison", len(script.Metadata.Fingerprints))
+	}
+
+	var store *history.Store
+	var deployment *models.Deployment
+	if (recordHistory || appConfig.History.Enabled) && !targetDB.RecordsHistory() {
+		color.Yellow("The deployment history is not recorded in %s databases, this deployment is not recorded", targetDB.Product())
+	} else if recordHistory || appConfig.History.Enabled {
+		store = history.NewStore(targetDB, appConfig.History.Schema)
+		deployment = script.Deployment(targetDB, Version)
+
+		if err := store.EnsureTables(); err != nil {
+			color.Red("Error creating the deployment history tables: %v", err)
+			targetDB.Close()
+			os.Exit(1)
+		}
+		if err := store.Start(deployment); err != nil {
+			color.Red("Error recording the deployment: %v", err)
+			targetDB.Close()
+			os.Exit(1)
+		}
+		color.Cyan("Recording the deployment in the history with run id %s", deployment.RunID)
+	}
+
+	err = deploy.Apply(script.Batches)
+
+	if store != nil {
+		outcome, errorMessage := history.OutcomeSucceeded, ""
+		if err != nil {
+			outcome, errorMessage = history.OutcomeFailed, err.Error()
+		}
+		if historyErr := store.Finish(deployment.RunID, outcome, errorMessage); historyErr != nil {
+			color.Red("Error recording the deployment outcome: %v", historyErr)
+		}
+	}
+
+	if err != nil {
+		var batchErr *deployer.BatchError
+		if errors.As(err, &batchErr) {
+			color.Red("Error in batch %d at line %d: %v", batchErr.Index, batchErr.Line, batchErr.Err)
+			fmt.Println(batchErr.Batch.SQL)
+		} else {
+			color.Red("Error applying script: %v", err)
+		}
+		if useTransaction {
+			color.Yellow("The transaction was rolled back, no changes were applied")
+		}
+		targetDB.Close()
+		os.Exit(1)
+	}
+
+	color.Green("Script '%s' applied successfully to %s", scriptPath, target.Database)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/comparator"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/datacompare"
+	"github.com/victorlunam/dbgo/internal/filter"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// runData compares the rows of the selected tables between the source and
+// target databases. Tables are selected with --table rules, the data.tables
+// rules of the configuration file, or interactively. With --sync, the script
+// that brings the target rows in line with the source is written as well.
+// With --checksum, large tables are compared by the checksums of key ranges.
+// With --export, the differing rows of each table are written to CSV or JSON
+// files.
+//
+//	dbgo data [--table rule]... [--checksum] [--sync] [--export csv|json]...
+func runData(args []string) {
+	appConfig, hasConfigFile := loadConfig()
+	tableRules := append(appConfig.Data.Tables, checkFlagValues(&args, "--table")...)
+	exportFormats := checkFlagValues(&args, "--export")
+	generateSync := checkFlag(&args, "--sync")
+	useChecksum := checkFlag(&args, "--checksum")
+
+	if len(args) > 0 {
+		color.Red("Usage: dbgo data [--table rule]... [--checksum] [--sync] [--export csv|json]...")
+		os.Exit(1)
+	}
+	for _, format := range exportFormats {
+		if _, err := datacompare.NewExporter(format, ""); err != nil {
+			color.Red("%v", err)
+			os.Exit(1)
+		}
+	}
+
+	objectFilter, err := buildFilter(appConfig.Filters)
+	if err != nil {
+		color.Red("Error reading filters: %v", err)
+		os.Exit(1)
+	}
+
+	source := readDatabaseConfig("SOURCE DATABASE", appConfig, hasConfigFile)
+	sourceDB, err := database.Connect(source)
+	if err != nil {
+		color.Red("Error connecting to source database: %v", err)
+		os.Exit(1)
+	}
+	sourceDB.Filter = objectFilter
+	color.Green("Successfully connected to source database")
+	defer sourceDB.Close()
+
+	target := readDatabaseConfig("TARGET DATABASE", appConfig, hasConfigFile)
+	targetDB, err := database.Connect(target)
+	if err != nil {
+		color.Red("Error connecting to target database: %v", err)
+		os.Exit(1)
+	}
+	color.Green("Successfully connected to target database")
+	defer targetDB.Close()
+
+	if !sourceDB.ComparesData() || !targetDB.ComparesData() {
+		color.Red("Data comparison is not available between %s and %s databases", sourceDB.Product(), targetDB.Product())
+		sourceDB.Close()
+		targetDB.Close()
+		os.Exit(1)
+	}
+
+	tables, err := selectTables(sourceDB, tableRules)
+	if err != nil {
+		color.Red("Error selecting tables: %v", err)
+		sourceDB.Close()
+		targetDB.Close()
+		os.Exit(1)
+	}
+	if len(tables) == 0 {
+		color.Yellow("No tables selected")
+		return
+	}
+
+	fileName := fmt.Sprintf("data-diff-%s-%s-%s.txt", source.Database, target.Database, time.Now().Format("20060102150405"))
+	outputFile, err := os.Create(fileName)
+	if err != nil {
+		color.Red("Error creating output file: %v", err)
+		sourceDB.Close()
+		targetDB.Close()
+		os.Exit(1)
+	}
+	defer outputFile.Close()
+
+	exportDir := strings.TrimSuffix(fileName, ".txt")
+	if len(exportFormats) > 0 {
+		if err := os.MkdirAll(exportDir, 0755); err != nil {
+			color.Red("Error creating export directory: %v", err)
+			sourceDB.Close()
+			targetDB.Close()
+			os.Exit(1)
+		}
+	}
+
+	fmt.Fprintf(outputFile, "Data comparison between %s and %s\n", source.Database, target.Database)
+	sourceSizes := readTableSizes(outputFile, "Source", sourceDB)
+	targetSizes := readTableSizes(outputFile, "Target", targetDB)
+	fmt.Fprint(outputFile, "\n")
+
+	comp := datacompare.NewComparator(sourceDB, targetDB)
+	compareTable := comp.CompareTable
+	if useChecksum {
+		compareTable = comp.ChecksumTable
+	}
+	syncScript := datacompare.NewSyncScript(sourceDB, targetDB, appConfig.Data.BatchSize)
+	defer syncScript.Close()
+	differentTables := 0
+
+	for _, table := range tables {
+		targetTable := comparator.MapToTarget(appConfig.Compare.Mappings, table)
+		targetTable, exists, err := targetDB.FindObject(targetTable.Schema, targetTable.Name)
+		if err != nil {
+			color.Red("Error looking up %s.%s in the target database: %v", table.Schema, table.Name, err)
+			continue
+		}
+		if !exists || targetTable.Type != "USER_TABLE" {
+			color.Yellow("The table %s.%s does not exist in the target database", table.Schema, table.Name)
+			fmt.Fprintf(outputFile, "Table %s.%s: missing in the target database\n\n", table.Schema, table.Name)
+			continue
+		}
+
+		// row lines are spooled to a temporary file so the counts can be
+		// written first without holding large differences in memory
+		rowLines, err := os.CreateTemp("", "dbgo-rows-*")
+		if err != nil {
+			color.Red("Error creating temporary file: %v", err)
+			continue
+		}
+		rowWriter := bufio.NewWriter(rowLines)
+
+		var exporters []*datacompare.Exporter
+		for _, format := range exportFormats {
+			exporter, _ := datacompare.NewExporter(format, filepath.Join(exportDir, exportFileName(table, format)))
+			exporters = append(exporters, exporter)
+		}
+
+		// the differences of the sync script are spooled the same way
+		var syncRows *datacompare.RowSpool
+		if generateSync {
+			syncRows, err = datacompare.NewRowSpool()
+			if err != nil {
+				rowLines.Close()
+				os.Remove(rowLines.Name())
+				color.Red("Error creating temporary file: %v", err)
+				continue
+			}
+		}
+
+		result, err := compareTable(table, targetTable, func(diff datacompare.RowDiff) error {
+			datacompare.WriteRowDiff(rowWriter, diff)
+			for _, exporter := range exporters {
+				if err := exporter.Write(diff); err != nil {
+					return fmt.Errorf("error exporting to %s: %v", exporter.Path, err)
+				}
+			}
+			if syncRows != nil {
+				if err := syncRows.Write(diff); err != nil {
+					return fmt.Errorf("error spooling the row differences: %v", err)
+				}
+			}
+			return nil
+		})
+		for _, exporter := range exporters {
+			if closeErr := exporter.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("error exporting to %s: %v", exporter.Path, closeErr)
+			}
+		}
+		if err != nil {
+			rowLines.Close()
+			os.Remove(rowLines.Name())
+			if syncRows != nil {
+				syncRows.Close()
+			}
+			color.Red("Error comparing the data of %s.%s: %v", table.Schema, table.Name, err)
+			fmt.Fprintf(outputFile, "Table %s.%s: not compared, %v\n\n", table.Schema, table.Name, err)
+			continue
+		}
+		result.SourceSize = sourceSizes.Get(table)
+		result.TargetSize = targetSizes.Get(targetTable)
+
+		datacompare.WriteTableSummary(outputFile, result)
+		rowWriter.Flush()
+		rowLines.Seek(0, io.SeekStart)
+		io.Copy(outputFile, rowLines)
+		rowLines.Close()
+		os.Remove(rowLines.Name())
+		fmt.Fprint(outputFile, "\n")
+
+		if result.HasDifferences() {
+			differentTables++
+			if syncRows != nil {
+				syncScript.AddTable(result, syncRows)
+			}
+			color.Yellow("%s.%s: %d to insert, %d to update, %d to delete", table.Schema, table.Name, result.Inserted, result.Updated, result.Deleted)
+			if result.TargetSize != nil {
+				color.Yellow("  target size: %s", result.TargetSize)
+			}
+		} else {
+			if syncRows != nil {
+				syncRows.Close()
+			}
+			color.Green("%s.%s: %d rows match", table.Schema, table.Name, result.Unchanged)
+		}
+	}
+
+	color.Cyan("Found data differences in %d of %d tables", differentTables, len(tables))
+	color.Green("Data comparison completed. The results are in the '%s' file", fileName)
+	if len(exportFormats) > 0 {
+		color.Green("The differing rows of each table are exported to the '%s' directory", exportDir)
+	}
+
+	if generateSync && differentTables > 0 {
+		writeSyncScript(syncScript, source.Database, target.Database, targetDB)
+	}
+}
+
+// exportFileName returns the name of the export file of a table, replacing
+// the characters that file names cannot hold.
+func exportFileName(table models.SchemaObject, format string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, table.Schema+"."+table.Name)
+	return name + "." + format
+}
+
+// readTableSizes reads the table sizes of a database and writes its totals
+// to the report. Sizes are left out when they cannot be read.
+func readTableSizes(w io.Writer, label string, db *database.Database) database.TableSizes {
+	tables, err := db.GetTableSizes()
+	if err == nil {
+		var size models.DatabaseSize
+		if size, err = db.GetDatabaseSize(tables); err == nil {
+			fmt.Fprintf(w, "%s size: %s\n", label, size)
+			return tables
+		}
+	}
+	color.Yellow("Table sizes of the %s database are not reported: %v", strings.ToLower(label), err)
+	return nil
+}
+
+// writeSyncScript writes the data synchronization script to a file that can
+// be run with dbgo apply.
+func writeSyncScript(syncScript *datacompare.SyncScript, sourceName, targetName string, targetDB *database.Database) {
+	references, err := targetDB.GetTableReferences()
+	if err != nil {
+		color.Red("Error reading the foreign keys of the target database: %v", err)
+		return
+	}
+
+	fileName := fmt.Sprintf("data-sync-%s-%s-%s.sql", sourceName, targetName, time.Now().Format("20060102150405"))
+	outputFile, err := os.Create(fileName)
+	if err != nil {
+		color.Red("Error creating output file: %v", err)
+		return
+	}
+	defer outputFile.Close()
+
+	if err := syncScript.Write(outputFile, references); err != nil {
+		color.Red("Error writing the data synchronization script: %v", err)
+		return
+	}
+	color.Green("The data synchronization script is in the '%s' file", fileName)
+}
+
+// selectTables returns the source tables matching rules, or the tables picked
+// by the user when there are no rules.
+func selectTables(sourceDB *database.Database, rules []string) ([]models.SchemaObject, error) {
+	tables, err := sourceDB.GetObjectsList([]string{"TABLE"})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		names := make([]string, len(tables))
+		for i, table := range tables {
+			names[i] = table.Schema + "." + table.Name
+		}
+		selected := make(map[string]bool)
+		for _, name := range selectItems(names) {
+			selected[name] = true
+		}
+
+		var selectedTables []models.SchemaObject
+		for _, table := range tables {
+			if selected[table.Schema+"."+table.Name] {
+				selectedTables = append(selectedTables, table)
+			}
+		}
+		return selectedTables, nil
+	}
+
+	tableFilter, err := filter.New(rules, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var selectedTables []models.SchemaObject
+	for _, table := range tables {
+		if tableFilter.Allows(table.Schema, table.Name, "TABLE", table.Type) {
+			selectedTables = append(selectedTables, table)
+		}
+	}
+	return selectedTables, nil
+}
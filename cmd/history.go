@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/history"
+)
+
+// runHistory lists the deployments recorded in the target database, or shows
+// the details of one of them.
+//
+//	dbgo history [run-id] [--limit n]
+func runHistory(args []string) {
+	limit := 20
+	if values := checkFlagValues(&args, "--limit"); len(values) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(values[len(values)-1]); err != nil || limit < 1 {
+			color.Red("Invalid --limit value: %s", values[len(values)-1])
+			os.Exit(1)
+		}
+	}
+
+	if len(args) > 1 {
+		color.Red("Usage: dbgo history [run-id] [--limit n]")
+		os.Exit(1)
+	}
+
+	appConfig, hasConfigFile := loadConfig()
+	target := readDatabaseConfig("TARGET DATABASE", appConfig, hasConfigFile)
+
+	targetDB, err := database.Connect(target)
+	if err != nil {
+		color.Red("Error connecting to target database: %v", err)
+		os.Exit(1)
+	}
+	defer targetDB.Close()
+
+	if !targetDB.RecordsHistory() {
+		color.Red("The deployment history is not recorded in %s databases", targetDB.Product())
+		targetDB.Close()
+		os.Exit(1)
+	}
+
+	store := history.NewStore(targetDB, appConfig.History.Schema)
+	exists, err := store.Exists()
+	if err != nil {
+		color.Red("Error reading the deployment history: %v", err)
+		targetDB.Close()
+		os.Exit(1)
+	}
+	if !exists {
+		color.Yellow("No deployment history in %s, enable it with apply --history", target.Database)
+		return
+	}
+
+	if len(args) == 1 {
+		showDeployment(store, args[0])
+		return
+	}
+
+	deployments, err := store.List(limit)
+	if err != nil {
+		color.Red("Error reading the deployment history: %v", err)
+		targetDB.Close()
+		os.Exit(1)
+	}
+
+	if len(deployments) == 0 {
+		color.Yellow("No deployments recorded in %s", target.Database)
+		return
+	}
+
+	fmt.Printf("%-36s  %-19s  %-9s  %-20s  %s\n", "RUN ID", "STARTED (UTC)", "OUTCOME", "SOURCE", "SCRIPT")
+	for _, deployment := range deployments {
+		fmt.Printf("%-36s  %-19s  %-9s  %-20s  %s\n", deployment.RunID, deployment.StartedAt.Format("2006-01-02 15:04:05"),
+			deployment.Outcome, deployment.SourceDatabase, deployment.ScriptName)
+	}
+}
+
+func showDeployment(store *history.Store, runID string) {
+	deployment, err := store.Get(runID)
+	if err != nil {
+		color.Red("Error reading deployment %s: %v", runID, err)
+		store.DB.Close()
+		os.Exit(1)
+	}
+
+	finishedAt := "-"
+	if !deployment.FinishedAt.IsZero() {
+		finishedAt = deployment.FinishedAt.Format("2006-01-02 15:04:05")
+	}
+
+	fmt.Printf("Run ID:       %s\n", deployment.RunID)
+	fmt.Printf("Outcome:      %s\n", deployment.Outcome)
+	fmt.Printf("Source:       %s / %s\n", deployment.SourceServer, deployment.SourceDatabase)
+	fmt.Printf("Target:       %s / %s\n", deployment.TargetServer, deployment.TargetDatabase)
+	fmt.Printf("Applied by:   %s\n", deployment.AppliedBy)
+	fmt.Printf("dbgo version: %s\n", deployment.Version)
+	fmt.Printf("Script:       %s (sha256 %s)\n", deployment.ScriptName, deployment.ScriptHash)
+	fmt.Printf("Started:      %s UTC\n", deployment.StartedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Finished:     %s UTC\n", finishedAt)
+	if deployment.ErrorMessage != "" {
+		color.Red("Error:        %s", deployment.ErrorMessage)
+	}
+
+	fmt.Printf("\nObjects (%d):\n", len(deployment.Objects))
+	for _, obj := range deployment.Objects {
+		fmt.Printf("  %-12s %-12s %s.%s (%s)\n", obj.Action, obj.Risk, obj.Schema, obj.Name, obj.Type)
+	}
+}
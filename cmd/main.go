@@ -1,8 +1,9 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"strings"
@@ -13,58 +14,123 @@ import (
 	"github.com/victorlunam/dbgo/internal/comparator"
 	"github.com/victorlunam/dbgo/internal/config"
 	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/filter"
 	"github.com/victorlunam/dbgo/internal/ui"
 )
 
+// Version is the dbgo version recorded in the deployment history, set at
+// build time with -ldflags "-X main.Version=<version>".
+var Version = "dev"
+
 func main() {
 	fmt.Println("=== Comparator DBGO ===")
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "apply":
+			runApply(os.Args[2:])
+			return
+		case "history":
+			runHistory(os.Args[2:])
+			return
+		case "data":
+			runData(os.Args[2:])
+			return
+		}
+	}
+
 	isLoggingEnabled := checkLoggingEnabled(&os.Args)
 
-	source := readDatabaseConfig("SOURCE DATABASE")
+	appConfig, hasConfigFile := loadConfig()
+	if checkFlag(&os.Args, "--case-sensitive") {
+		appConfig.Compare.CaseSensitivity = config.CaseSensitivitySensitive
+	}
+	if checkFlag(&os.Args, "--case-insensitive") {
+		appConfig.Compare.CaseSensitivity = config.CaseSensitivityInsensitive
+	}
+	if checkFlag(&os.Args, "--allow-target-newer") {
+		appConfig.Compare.AllowTargetNewer = true
+	}
+	if checkFlag(&os.Args, "--allow-data-loss") {
+		appConfig.Compare.AllowDataLoss = true
+	}
+	if checkFlag(&os.Args, "--sqlcmd") {
+		appConfig.Compare.SQLCMDMode = true
+	}
+	if checkFlag(&os.Args, "--idempotent") {
+		appConfig.Compare.Idempotent = true
+	}
+	appConfig.Filters.Include = append(appConfig.Filters.Include, checkFlagValues(&os.Args, "--include")...)
+	appConfig.Filters.Exclude = append(appConfig.Filters.Exclude, checkFlagValues(&os.Args, "--exclude")...)
+
+	objectFilter, err := buildFilter(appConfig.Filters)
+	if err != nil {
+		color.Red("Error reading filters: %v", err)
+		os.Exit(1)
+	}
+
+	source := readDatabaseConfig("SOURCE DATABASE", appConfig, hasConfigFile)
 
 	sourceDB, err := database.Connect(source)
 	if err != nil {
 		color.Red("Error connecting to source database: %v", err)
 		os.Exit(1)
 	}
+	sourceDB.Filter = objectFilter
 	color.Green("Successfully connected to source database")
 	defer sourceDB.Close()
 
-	target := readDatabaseConfig("TARGET DATABASE")
+	target := readDatabaseConfig("TARGET DATABASE", appConfig, hasConfigFile)
+
+	hooks, err := comparator.LoadHooks(appConfig.Hooks, target.Variables)
+	if err != nil {
+		color.Red("Error reading deployment hooks: %v", err)
+		os.Exit(1)
+	}
 
 	targetDB, err := database.Connect(target)
 	if err != nil {
 		color.Red("Error connecting to target database: %v", err)
 		os.Exit(1)
 	}
+	targetDB.Filter = objectFilter
 	color.Green("Successfully connected to target database")
 	defer targetDB.Close()
 
-	objectTypes := selectObjectTypes()
-
-	comp := comparator.NewComparator(sourceDB, targetDB, isLoggingEnabled)
+	comp := comparator.NewComparator(sourceDB, targetDB, appConfig.Compare, isLoggingEnabled)
+	comp.Hooks = hooks
 	timestamp := time.Now().Format("20060102150405")
 
+	// databases of different engines are compared by the structure of their
+	// tables, without a script
+	if comp.IsCrossEngine() {
+		color.Cyan("Comparing the tables of %s with %s by their structure", sourceDB.Product(), targetDB.Product())
+		if err := comp.CompareStructure(timestamp); err != nil {
+			color.Red("Error during comparison: %v", err)
+			os.Exit(1)
+		}
+
+		fileName := fmt.Sprintf("schema-structure-%s-%s-%s.txt", sourceDB.Name(), targetDB.Name(), timestamp)
+		color.Green("Comparison completed. The results are in the '%s' file", fileName)
+		return
+	}
+
+	objectTypes := selectObjectTypes(sourceDB.Dialect.ObjectTypes())
+
 	err = comp.Compare(objectTypes, timestamp)
 	if err != nil {
 		color.Red("Error during comparison: %v", err)
 		os.Exit(1)
 	}
 
-	fileName := fmt.Sprintf("schema-diff-%s-%s-%s-%s.sql", source.Database, target.Database, strings.Join(objectTypes, "-"), timestamp)
+	fileName := fmt.Sprintf("schema-diff-%s-%s-%s-%s.sql", sourceDB.Name(), targetDB.Name(), strings.Join(objectTypes, "-"), timestamp)
+	rollbackFileName := fmt.Sprintf("schema-rollback-%s-%s-%s-%s.sql", sourceDB.Name(), targetDB.Name(), strings.Join(objectTypes, "-"), timestamp)
 	color.Green("Comparison completed. The results are in the '%s' file", fileName)
+	color.Green("The rollback script is in the '%s' file", rollbackFileName)
 }
 
 func checkLoggingEnabled(args *[]string) bool {
-	isLoggingEnabled := false
-	for i, arg := range *args {
-		if arg == "--log" || arg == "-l" {
-			isLoggingEnabled = true
-			*args = append((*args)[:i], (*args)[i+1:]...)
-			break
-		}
-	}
+	isLoggingEnabled := checkFlag(args, "--log", "-l")
 
 	if isLoggingEnabled {
 		color.Green("Logging enabled")
@@ -73,20 +139,74 @@ func checkLoggingEnabled(args *[]string) bool {
 	return isLoggingEnabled
 }
 
-func readDatabaseConfig(dbLabel string) config.DatabaseConfig {
-	// Try to read from config file first
-	if configFile, err := os.ReadFile("dbgo.config.json"); err == nil {
-		var config struct {
-			Source config.DatabaseConfig `json:"source"`
-			Target config.DatabaseConfig `json:"target"`
-		}
-		if err := json.Unmarshal(configFile, &config); err == nil {
-			if dbLabel == "SOURCE DATABASE" {
-				return config.Source
+// checkFlag reports whether any of names is present in args and removes it.
+func checkFlag(args *[]string, names ...string) bool {
+	for i, arg := range *args {
+		for _, name := range names {
+			if arg == name {
+				*args = append((*args)[:i], (*args)[i+1:]...)
+				return true
 			}
-			return config.Target
 		}
 	}
+	return false
+}
+
+// checkFlagValues returns the values given to a repeatable flag, written as
+// "--name value" or "--name=value", and removes them from args.
+func checkFlagValues(args *[]string, name string) []string {
+	var values []string
+	remaining := []string{}
+	for i := 0; i < len(*args); i++ {
+		arg := (*args)[i]
+		switch {
+		case arg == name && i+1 < len(*args):
+			values = append(values, (*args)[i+1])
+			i++
+		case strings.HasPrefix(arg, name+"="):
+			values = append(values, strings.TrimPrefix(arg, name+"="))
+		default:
+			remaining = append(remaining, arg)
+		}
+	}
+	*args = remaining
+	return values
+}
+
+// buildFilter combines the configured filters with the rules in .dbgoignore.
+func buildFilter(filtersConfig config.FiltersConfig) (*filter.Filter, error) {
+	objectFilter, err := filter.New(filtersConfig.Include, filtersConfig.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	if err := objectFilter.LoadIgnoreFile(".dbgoignore"); err != nil {
+		return nil, err
+	}
+	return objectFilter, nil
+}
+
+// loadConfig reads dbgo.config.json, falling back to the defaults when the
+// file does not exist.
+func loadConfig() (config.Config, bool) {
+	appConfig, err := config.Load("dbgo.config.json")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return config.Default(), false
+		}
+		color.Red("Error reading configuration file: %v", err)
+		os.Exit(1)
+	}
+	return appConfig, true
+}
+
+func readDatabaseConfig(dbLabel string, appConfig config.Config, hasConfigFile bool) config.DatabaseConfig {
+	// Use the config file when present
+	if hasConfigFile {
+		if dbLabel == "SOURCE DATABASE" {
+			return appConfig.Source
+		}
+		return appConfig.Target
+	}
 
 	var dbConfig config.DatabaseConfig
 
@@ -119,10 +239,13 @@ func readDatabaseConfig(dbLabel string) config.DatabaseConfig {
 	return dbConfig
 }
 
-func selectObjectTypes() []string {
-	objectTypes := []string{"TABLE", "VIEW", "PROCEDURE", "FUNCTION", "TRIGGER"}
+func selectObjectTypes(objectTypes []string) []string {
+	return selectItems(objectTypes)
+}
 
-	selector := ui.NewSelectorModel(objectTypes)
+// selectItems lets the user pick some of the options in the terminal.
+func selectItems(options []string) []string {
+	selector := ui.NewSelectorModel(options)
 
 	program := tea.NewProgram(selector)
 	m, err := program.Run()
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/deployer"
+)
+
+// TestVersionDefault checks the version that apply records in the history of
+// builds without -ldflags, which dbgo history shows.
+func TestVersionDefault(t *testing.T) {
+	if Version != "dev" {
+		t.Errorf("Version = %q, want dev", Version)
+	}
+
+	script := &deployer.Script{Path: "deploy.sql"}
+	targetDB := &database.Database{Config: config.DatabaseConfig{Server: "localhost", Port: "1433", Database: "Sales"}}
+	if got := script.Deployment(targetDB, Version).Version; got != "dev" {
+		t.Errorf("the deployment records version %q, want dev", got)
+	}
+
+	if schema := config.Default().History.Schema; schema != "dbo" {
+		t.Errorf("the history tables default to schema %q, want dbo", schema)
+	}
+}
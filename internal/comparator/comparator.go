@@ -2,35 +2,66 @@ package comparator
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/config"
 	"github.com/victorlunam/dbgo/internal/database"
 	"github.com/victorlunam/dbgo/internal/models"
+	"github.com/victorlunam/dbgo/internal/tsql"
 )
 
 type Comparator struct {
 	SourceDB         *database.Database
 	TargetDB         *database.Database
+	Config           config.CompareConfig
 	Results          []models.DiffResult
 	ResultsMu        sync.Mutex
 	IsLoggingEnabled bool
+	Hooks            Hooks
+	// SourceSize and TargetSize are the database totals, nil when they
+	// cannot be read
+	SourceSize *models.DatabaseSize
+	TargetSize *models.DatabaseSize
 }
 
-func NewComparator(sourceDB, targetDB *database.Database, isLoggingEnabled bool) *Comparator {
+func NewComparator(sourceDB, targetDB *database.Database, compareConfig config.CompareConfig, isLoggingEnabled bool) *Comparator {
+	scriptOptions := database.ScriptOptionsFromConfig(compareConfig)
+	sourceDB.ScriptOptions = scriptOptions
+	targetDB.ScriptOptions = scriptOptions
+
 	return &Comparator{
 		SourceDB:         sourceDB,
 		TargetDB:         targetDB,
+		Config:           compareConfig,
 		Results:          []models.DiffResult{},
 		IsLoggingEnabled: isLoggingEnabled,
 	}
 }
 
+// IsCaseSensitive reports whether object names must match with exact case.
+// In auto mode names are matched case-insensitively unless both databases use
+// a case-sensitive collation, because a case-insensitive database cannot hold
+// two objects whose names differ only by case.
+func (c *Comparator) IsCaseSensitive() bool {
+	switch c.Config.CaseSensitivity {
+	case config.CaseSensitivitySensitive:
+		return true
+	case config.CaseSensitivityInsensitive:
+		return false
+	}
+	return c.SourceDB.IsCaseSensitive() && c.TargetDB.IsCaseSensitive()
+}
+
 func (c *Comparator) Compare(objectTypes []string, timestamp string) error {
 	if c.IsLoggingEnabled {
-		logsDir := fmt.Sprintf("logs-%s-%s-%s", c.SourceDB.Config.Database, c.TargetDB.Config.Database, timestamp)
+		logsDir := fmt.Sprintf("logs-%s-%s-%s", c.SourceDB.Name(), c.TargetDB.Name(), timestamp)
 		if err := os.MkdirAll(logsDir, 0755); err != nil {
 			return fmt.Errorf("error creating logs directory: %v", err)
 		}
@@ -49,21 +80,51 @@ func (c *Comparator) Compare(objectTypes []string, timestamp string) error {
 	}
 
 	targetObjectsMap := make(map[string]models.SchemaObject)
+	// folded keys map to every target object sharing them, so ambiguous
+	// matches in case-sensitive targets can be detected
+	targetFoldedMap := make(map[string][]models.SchemaObject)
 	for _, obj := range targetObjects {
-		key := fmt.Sprintf("%s.%s.%s", obj.Schema, obj.Name, obj.Type)
-		targetObjectsMap[key] = obj
+		targetObjectsMap[objectKey(obj)] = obj
+		foldedKey := strings.ToLower(objectKey(obj))
+		targetFoldedMap[foldedKey] = append(targetFoldedMap[foldedKey], obj)
+	}
+
+	color.Cyan("Source server: %s", c.SourceDB.Server)
+	color.Cyan("Target server: %s", c.TargetDB.Server)
+
+	var sourceTableSizes, targetTableSizes database.TableSizes
+	for _, obj := range sourceObjects {
+		if obj.Type == "USER_TABLE" {
+			sourceTableSizes, targetTableSizes = c.loadSizes()
+			break
+		}
+	}
+
+	warnedEngine := ""
+	for _, db := range []*database.Database{c.SourceDB, c.TargetDB} {
+		if types := undatedTypes(db, sourceObjects); len(types) > 0 && db.Product() != warnedEngine {
+			color.Yellow("Target-newer detection is not available for %s objects, as %s does not record their modification date: hotfixes in the target are not detected",
+				strings.Join(types, ", "), db.Product())
+			warnedEngine = db.Product()
+		}
+	}
+
+	if c.Config.Idempotent && !c.TargetDB.WritesIdempotentScripts() {
+		color.Yellow("Idempotent scripts are not written for %s, the script drops objects with IF EXISTS", c.TargetDB.Product())
+	}
+
+	isCaseSensitive := c.IsCaseSensitive()
+	if !isCaseSensitive {
+		color.Cyan("Matching object names case-insensitively (source collation: %s, target collation: %s)", c.SourceDB.Collation, c.TargetDB.Collation)
 	}
 
-	fileName := fmt.Sprintf("schema-diff-%s-%s-%s-%s.sql", c.SourceDB.Config.Database, c.TargetDB.Config.Database, strings.Join(objectTypes, "-"), timestamp)
+	fileName := fmt.Sprintf("schema-diff-%s-%s-%s-%s.sql", c.SourceDB.Name(), c.TargetDB.Name(), strings.Join(objectTypes, "-"), timestamp)
 	outputFile, err := os.Create(fileName)
 	if err != nil {
 		return fmt.Errorf("error creating output file: %v", err)
 	}
 	defer outputFile.Close()
 
-	outputFile.WriteString("-- Schema comparison script\n")
-	outputFile.WriteString("-- Differences found between databases\n\n")
-
 	// use WaitGroup for sync goroutines
 	var wg sync.WaitGroup
 	// limit the number of goroutines concurrent
@@ -79,31 +140,60 @@ func (c *Comparator) Compare(objectTypes []string, timestamp string) error {
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			key := fmt.Sprintf("%s.%s.%s", obj.Schema, obj.Name, obj.Type)
-			targetObj, exists := targetObjectsMap[key]
+			// name of the object in the target environment
+			mappedObj := c.mapToTarget(obj)
+
+			targetObj, exists := targetObjectsMap[objectKey(mappedObj)]
+			if !exists && !isCaseSensitive {
+				if candidates := targetFoldedMap[strings.ToLower(objectKey(mappedObj))]; len(candidates) == 1 {
+					targetObj, exists = candidates[0], true
+				}
+			}
 
 			result := models.DiffResult{
-				Object: obj,
-				Exists: exists,
+				Object:       obj,
+				TargetObject: targetObj,
+				Exists:       exists,
 			}
 
+			if obj.Type == "USER_TABLE" {
+				result.SourceSize = sourceTableSizes.Get(obj)
+				if exists {
+					result.TargetSize = targetTableSizes.Get(targetObj)
+				}
+			}
+
+			// sp_rename cannot rename a schema, so a schema that differs
+			// only in case is reported as a warning instead of a rename
+			isCaseRename := exists && targetObj.Name != mappedObj.Name
+			if exists && targetObj.Schema != mappedObj.Schema {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("the schema is named %s in the target, the script does not change the case of schema names", targetObj.Schema))
+			}
+			isTableRecreated := false
+			losesData := false
+
 			if !exists {
 				color.Yellow("The object %s.%s (%s) does not exist in the target database", obj.Schema, obj.Name, obj.Type)
 
+				result.TargetObject = mappedObj
+
 				sourceDefinition, err := c.SourceDB.GetObjectDefinition(obj)
 				if err != nil {
 					color.Red("Error getting definition of %s.%s: %v", obj.Schema, obj.Name, err)
 					return
 				}
+				sourceDefinition = c.mapDefinition(sourceDefinition)
 
+				result.Kind = models.DiffMissing
 				result.HasDifferences = true
-				result.DifferenceScript = sourceDefinition
+				result.DifferenceScript, result.Warnings = adaptToTarget(c.scriptDefinition(sourceDefinition), c.TargetDB.Server)
 			} else {
 				sourceDefinition, err := c.SourceDB.GetObjectDefinition(obj)
 				if err != nil {
 					color.Red("Error getting definition of source for %s.%s: %v", obj.Schema, obj.Name, err)
 					return
 				}
+				sourceDefinition = c.mapDefinition(sourceDefinition)
 
 				targetDefinition, err := c.TargetDB.GetObjectDefinition(targetObj)
 				if err != nil {
@@ -111,36 +201,162 @@ func (c *Comparator) Compare(objectTypes []string, timestamp string) error {
 					return
 				}
 
-				normalizedSource := normalizeDefinition(sourceDefinition)
-				normalizedTarget := normalizeDefinition(targetDefinition)
+				result.TargetDefinition = targetDefinition
+				result.Fingerprint = Fingerprint(c.Config, targetObj, targetDefinition)
+
+				if isCaseRename {
+					// compare the bodies as if the target already had the source name
+					targetDefinition = replaceIdentifier(targetDefinition, targetObj.Name, mappedObj.Name)
+				}
+
+				// variable values differ between environments, compare the
+				// references instead
+				normalizedSource := c.normalizeDefinition(obj, tsql.ParameterizeVariables(sourceDefinition, c.SourceDB.Config.Variables))
+				normalizedTarget := c.normalizeDefinition(obj, tsql.ParameterizeVariables(targetDefinition, c.TargetDB.Config.Variables))
 
 				if c.IsLoggingEnabled {
-					logsDir := fmt.Sprintf("logs-%s-%s-%s", c.SourceDB.Config.Database, c.TargetDB.Config.Database, timestamp)
+					logsDir := fmt.Sprintf("logs-%s-%s-%s", c.SourceDB.Name(), c.TargetDB.Name(), timestamp)
 					sourceFileName := fmt.Sprintf("%s/SOURCE-%s-%s-%s.sql", logsDir, obj.Type, obj.Schema, obj.Name)
 					if err := os.WriteFile(sourceFileName, []byte(normalizedSource), 0644); err != nil {
 						color.Red("Error writing source definition file for %s.%s: %v", obj.Schema, obj.Name, err)
 					}
 				}
 
-				if normalizedSource != normalizedTarget {
-					color.Yellow("Differences found in %s.%s (%s)", obj.Schema, obj.Name, obj.Type)
+				if isCaseRename {
+					color.Yellow("The object %s.%s (%s) is named %s.%s in the target database", obj.Schema, obj.Name, obj.Type, targetObj.Schema, targetObj.Name)
+
+					result.Kind = models.DiffCaseRename
+					result.HasDifferences = true
+
+					if obj.Type == "USER_TABLE" {
+						result.DifferenceScript = c.TargetDB.Dialect.RenameStatement(targetObj, mappedObj.Name)
+					}
+				}
+
+				// modules keep their original name in sys.sql_modules after sp_rename,
+				// so a renamed module is always recreated
+				isModuleRename := isCaseRename && obj.Type != "USER_TABLE"
+
+				if normalizedSource != normalizedTarget || isModuleRename {
+					color.Yellow("Differences found in %s.%s (%s), source modified %s, target modified %s",
+						obj.Schema, obj.Name, obj.Type, formatModifyDate(obj.ModifyDate), formatModifyDate(targetObj.ModifyDate))
 
 					if c.IsLoggingEnabled {
-						logsDir := fmt.Sprintf("logs-%s-%s-%s", c.SourceDB.Config.Database, c.TargetDB.Config.Database, timestamp)
+						logsDir := fmt.Sprintf("logs-%s-%s-%s", c.SourceDB.Name(), c.TargetDB.Name(), timestamp)
 						targetFileName := fmt.Sprintf("%s/TARGET-%s-%s-%s.sql", logsDir, obj.Type, obj.Schema, obj.Name)
 						if err := os.WriteFile(targetFileName, []byte(normalizedTarget), 0644); err != nil {
 							color.Red("Error writing target definition file for %s.%s: %v", obj.Schema, obj.Name, err)
 						}
 					}
 
-					dropStatement, err := generateDropStatement(obj, c.SourceDB)
-					if err != nil {
-						color.Red("Error generating drop statement for %s.%s: %v", obj.Schema, obj.Name, err)
-						return
+					scriptDefinition, warnings := adaptToTarget(c.scriptDefinition(sourceDefinition), c.TargetDB.Server)
+					result.Warnings = append(result.Warnings, warnings...)
+
+					// tables are rebuilt keeping their rows when the target
+					// cannot alter them in place
+					isRebuilt := false
+					var droppedColumns []string
+					if obj.Type == "USER_TABLE" {
+						var rebuild database.TableRebuild
+						rebuild, isRebuilt, err = c.TargetDB.RebuildStatement(targetObj, result.TargetDefinition, scriptDefinition)
+						if err == nil && isRebuilt {
+							var rollback database.TableRebuild
+							rollback, _, err = c.TargetDB.RebuildStatement(targetObj, scriptDefinition, result.TargetDefinition)
+							result.RollbackScript = rollback.Statement
+							// the rebuilt table takes the source name
+							result.DifferenceScript = ""
+							scriptDefinition = rebuild.Statement
+							droppedColumns = rebuild.DroppedColumns
+						}
+						if err != nil {
+							color.Red("Error generating the rebuild of %s.%s: %v", obj.Schema, obj.Name, err)
+							return
+						}
 					}
 
+					// modules are altered in place when the target supports it,
+					// keeping their permissions, unless their name changes
+					dropStatement := ""
+					switch {
+					case isRebuilt:
+						// the rebuild drops the table itself
+					case obj.Type != "USER_TABLE" && !isCaseRename && c.TargetDB.Server.SupportsCreateOrAlter():
+						scriptDefinition = tsql.SetCreateOrAlter(scriptDefinition, true)
+					default:
+						dropStatement, err = c.TargetDB.DropStatement(targetObj)
+						if err != nil {
+							color.Red("Error generating drop statement for %s.%s: %v", obj.Schema, obj.Name, err)
+							return
+						}
+						dropStatement += "\n"
+					}
+
+					// indexes and triggers dropped with the table are created
+					// again, unless they are rebuilt with it
+					if obj.Type == "USER_TABLE" && !isRebuilt {
+						dependents, err := c.TargetDB.TableDependents(targetObj)
+						if err != nil {
+							color.Red("Error reading the indexes and triggers of %s.%s: %v", targetObj.Schema, targetObj.Name, err)
+							return
+						}
+						scriptDefinition = appendDependents(scriptDefinition, dependents, c.TargetDB.Dialect.BatchSeparator())
+					}
+
+					// recreated tables lose their rows, and rebuilt tables the
+					// values of the columns they remove
+					guard := ""
+					if obj.Type == "USER_TABLE" && (!isRebuilt || len(droppedColumns) > 0) {
+						isTableRecreated = !isRebuilt
+						losesData = true
+						if isRebuilt {
+							result.RemovedColumns = droppedColumns
+						} else {
+							result.RemovedColumns = removedColumns(sourceDefinition, targetDefinition)
+						}
+
+						result.TargetRowCount, err = c.TargetDB.GetRowCount(targetObj)
+						if err != nil {
+							color.Red("Error counting rows of %s.%s: %v", targetObj.Schema, targetObj.Name, err)
+							return
+						}
+
+						if !c.Config.AllowDataLoss {
+							var ok bool
+							guard, ok = c.TargetDB.DataLossGuard(targetObj, result.RemovedColumns, c.Config.Idempotent)
+							if !ok {
+								result.Warnings = append(result.Warnings, fmt.Sprintf("the script has no data loss guard on %s, check the rows of the table before running it", c.TargetDB.Product()))
+							}
+						}
+					}
+
+					if result.Kind == "" {
+						result.Kind = models.DiffChanged
+					}
 					result.HasDifferences = true
-					result.DifferenceScript = fmt.Sprintf("%s\n%s", dropStatement, sourceDefinition)
+					result.DifferenceScript += guard + dropStatement + scriptDefinition
+				}
+			}
+
+			for _, warning := range result.Warnings {
+				color.Yellow("%s.%s (%s): %s", obj.Schema, obj.Name, obj.Type, warning)
+			}
+
+			result.Risk = classifyResult(result, losesData)
+			if isTableRecreated {
+				color.Red("The table %s.%s will be dropped and recreated, losing its %d rows in the target", obj.Schema, obj.Name, result.TargetRowCount)
+			} else if losesData {
+				color.Red("The table %s.%s will be rebuilt without the columns %s, losing their values in %d rows in the target",
+					obj.Schema, obj.Name, strings.Join(result.RemovedColumns, ", "), result.TargetRowCount)
+			}
+
+			if result.HasDifferences && exists && targetObj.ModifyDate.After(obj.ModifyDate) {
+				result.TargetNewer = true
+				result.Excluded = !c.Config.AllowTargetNewer
+
+				color.Red("The target version of %s.%s was modified after the source (target: %s, source: %s), it may contain a hotfix",
+					obj.Schema, obj.Name, formatModifyDate(targetObj.ModifyDate), formatModifyDate(obj.ModifyDate))
+				if result.Excluded {
+					color.Red("%s.%s is left out of the script, use --allow-target-newer to include it", obj.Schema, obj.Name)
 				}
 			}
 
@@ -154,56 +370,311 @@ func (c *Comparator) Compare(objectTypes []string, timestamp string) error {
 
 	wg.Wait()
 
-	for _, result := range c.Results {
-		outputFile.WriteString(fmt.Sprintf("-- Object: %s.%s (%s)\n", result.Object.Schema, result.Object.Name, result.Object.Type))
-		outputFile.WriteString(result.DifferenceScript)
-		outputFile.WriteString("GO\n\n")
+	c.sortResults(objectTypes)
+	c.writeScript(outputFile)
+
+	rollbackFileName := fmt.Sprintf("schema-rollback-%s-%s-%s-%s.sql", c.SourceDB.Name(), c.TargetDB.Name(), strings.Join(objectTypes, "-"), timestamp)
+	rollbackFile, err := os.Create(rollbackFileName)
+	if err != nil {
+		return fmt.Errorf("error creating rollback file: %v", err)
 	}
+	defer rollbackFile.Close()
+
+	c.writeRollbackScript(rollbackFile, fileName)
 
 	color.Cyan("Found %d differences in %d objects", len(c.Results), len(sourceObjects))
+	riskCounts := c.riskCounts()
+	color.Cyan("Changes: %d safe, %d risky, %d destructive", riskCounts[models.RiskSafe], riskCounts[models.RiskRisky], riskCounts[models.RiskDestructive])
+	if riskCounts[models.RiskDestructive] > 0 && !c.Config.AllowDataLoss {
+		color.Yellow("Destructive changes are guarded and stop the script if the affected tables contain data")
+	}
+	if excluded := c.excludedCount(); excluded > 0 {
+		color.Yellow("%d objects modified more recently in the target were left out of the script", excluded)
+	}
+	if c.SourceSize != nil {
+		color.Cyan("Source size: %s", c.SourceSize)
+	}
+	if c.TargetSize != nil {
+		color.Cyan("Target size: %s", c.TargetSize)
+	}
 
 	return nil
 }
 
-func normalizeDefinition(definition string) string {
-	result := strings.ReplaceAll(definition, "\t", " ")
+// writeScript writes the header of the deployment script, with the target
+// fingerprints and the drift guard, followed by the pre-deployment hooks, the
+// script of every result and the post-deployment hooks.
+func (c *Comparator) writeScript(w io.Writer) {
+	fmt.Fprint(w, "-- Schema comparison script\n")
+	fmt.Fprint(w, "-- Differences found between databases\n\n")
 
-	for strings.Contains(result, "  ") {
-		result = strings.ReplaceAll(result, "  ", " ")
+	if c.Config.SQLCMDMode {
+		writeSetvars(w, c.sqlcmdVariables())
 	}
 
-	result = strings.ReplaceAll(result, "\r\n", "\n")
+	var fingerprints []ObjectFingerprint
+	for _, result := range c.Results {
+		if !result.Excluded {
+			fingerprints = append(fingerprints, resultFingerprint(result))
+		}
+	}
 
-	lines := strings.Split(result, "\n")
-	for i, line := range lines {
-		lines[i] = strings.TrimSpace(line)
+	fmt.Fprint(w, DatabaseMarkers(c.SourceDB, c.TargetDB))
+	fmt.Fprintf(w, "-- Generated for %s\n", c.TargetDB.Server)
+	if c.SourceSize != nil {
+		fmt.Fprintf(w, "-- Source size: %s\n", c.SourceSize)
 	}
+	if c.TargetSize != nil {
+		fmt.Fprintf(w, "-- Target size: %s\n", c.TargetSize)
+	}
+	fmt.Fprint(w, "\n")
 
-	linesToString := strings.Join(lines, "\n")
+	riskCounts := c.riskCounts()
+	fmt.Fprintf(w, "-- Changes: %d safe, %d risky, %d destructive\n",
+		riskCounts[models.RiskSafe], riskCounts[models.RiskRisky], riskCounts[models.RiskDestructive])
+	for _, result := range c.Results {
+		if !result.Excluded {
+			fmt.Fprintln(w, resultAction(result).String())
+		}
+	}
+	fmt.Fprint(w, "\n")
 
-	return strings.TrimSpace(linesToString)
+	if len(fingerprints) > 0 {
+		fmt.Fprint(w, "-- Target fingerprints, verified by dbgo apply before running the script\n")
+		for _, fingerprint := range fingerprints {
+			fmt.Fprintln(w, fingerprint.String())
+		}
+		fmt.Fprint(w, "\n")
+		// without a guard in the script, dbgo apply checks the fingerprints
+		fmt.Fprint(w, c.TargetDB.DriftGuard(driftGuardObjects(fingerprints)))
+	}
+
+	writeHooks(w, c.Hooks.PreDeploy, c.TargetDB.Dialect.BatchSeparator())
+
+	for _, result := range c.Results {
+		fmt.Fprintf(w, "-- Object: %s.%s (%s)\n", result.Object.Schema, result.Object.Name, result.Object.Type)
+		if !strings.EqualFold(result.TargetObject.Schema, result.Object.Schema) {
+			fmt.Fprintf(w, "-- Mapped to %s.%s in the target database\n", result.TargetObject.Schema, result.TargetObject.Name)
+		}
+		if result.Kind == models.DiffCaseRename {
+			fmt.Fprintf(w, "-- Case-only rename from %s.%s\n", result.TargetObject.Schema, result.TargetObject.Name)
+		}
+
+		targetModifyDate := "does not exist"
+		if result.Exists {
+			targetModifyDate = formatModifyDate(result.TargetObject.ModifyDate)
+		}
+		fmt.Fprintf(w, "-- Source modified: %s, target modified: %s\n", formatModifyDate(result.Object.ModifyDate), targetModifyDate)
+
+		fmt.Fprintf(w, "-- Risk: %s", result.Risk)
+		if result.Risk == models.RiskDestructive {
+			fmt.Fprintf(w, " (%d rows in the target", result.TargetRowCount)
+			if len(result.RemovedColumns) > 0 {
+				fmt.Fprintf(w, ", removed columns: %s", strings.Join(result.RemovedColumns, ", "))
+			}
+			fmt.Fprint(w, ")")
+		}
+		fmt.Fprint(w, "\n")
+		writeTableSizes(w, result)
+
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(w, "-- WARNING: %s\n", warning)
+		}
+		if result.TargetNewer {
+			fmt.Fprint(w, "-- WARNING: the target was modified after the source, it may contain a hotfix\n")
+		}
+		if result.Excluded {
+			fmt.Fprint(w, "-- Skipped, rerun the comparison with --allow-target-newer to include it\n\n")
+			continue
+		}
+
+		differenceScript := result.DifferenceScript
+		if c.Config.Idempotent {
+			if script, ok := c.TargetDB.IdempotentScript(result); ok {
+				differenceScript = script
+			}
+		}
+
+		// module definitions usually end without a line break, which would put
+		// the batch separator on their last line
+		fmt.Fprint(w, endWithNewline(differenceScript))
+		fmt.Fprintf(w, "%s\n\n", c.TargetDB.Dialect.BatchSeparator())
+	}
+
+	writeHooks(w, c.Hooks.PostDeploy, c.TargetDB.Dialect.BatchSeparator())
+}
+
+// appendDependents appends the statements of the indexes and triggers of a
+// table to its definition, each in a batch of its own when the engine uses
+// batch separators.
+func appendDependents(definition string, dependents []string, separator string) string {
+	for _, dependent := range dependents {
+		definition = endWithNewline(definition)
+		if separator != "" {
+			definition += separator + "\n"
+		}
+		definition += dependent
+	}
+	return definition
+}
+
+// sortResults orders the results by the order of the selected object types
+// and then by name, since they are found in any order. Tables come before
+// the objects that depend on them, such as the indexes of a table that is
+// rebuilt.
+func (c *Comparator) sortResults(objectTypes []string) {
+	rank := func(result models.DiffResult) int {
+		category := database.ObjectTypeCategory(result.Object.Type)
+		for i, objectType := range objectTypes {
+			if objectType == category {
+				return i
+			}
+		}
+		return len(objectTypes)
+	}
+
+	sort.SliceStable(c.Results, func(i, j int) bool {
+		a, b := c.Results[i], c.Results[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return strings.ToLower(objectKey(a.Object)) < strings.ToLower(objectKey(b.Object))
+	})
+}
+
+func endWithNewline(script string) string {
+	if script == "" || strings.HasSuffix(script, "\n") {
+		return script
+	}
+	return script + "\n"
+}
+
+// riskCounts counts the results included in the script by risk.
+func (c *Comparator) riskCounts() map[models.ChangeRisk]int {
+	riskCounts := make(map[models.ChangeRisk]int)
+	for _, result := range c.Results {
+		if !result.Excluded {
+			riskCounts[result.Risk]++
+		}
+	}
+	return riskCounts
+}
+
+func (c *Comparator) excludedCount() int {
+	count := 0
+	for _, result := range c.Results {
+		if result.Excluded {
+			count++
+		}
+	}
+	return count
+}
+
+// undatedTypes returns the types of objects whose modification date the
+// database does not record, so a target modified after the source cannot be
+// detected.
+func undatedTypes(db *database.Database, objects []models.SchemaObject) []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, obj := range objects {
+		if !seen[obj.Type] && !db.Dialect.RecordsModifyDate(obj.Type) {
+			types = append(types, obj.Type)
+		}
+		seen[obj.Type] = true
+	}
+	return types
+}
+
+func formatModifyDate(modifyDate time.Time) string {
+	return modifyDate.Format("2006-01-02 15:04:05")
+}
+
+func (c *Comparator) mapToTarget(obj models.SchemaObject) models.SchemaObject {
+	return MapToTarget(c.Config.Mappings, obj)
+}
+
+// MapToTarget returns the source object with the name it has in the target
+// environment according to the schema mappings.
+func MapToTarget(mappings config.MappingsConfig, obj models.SchemaObject) models.SchemaObject {
+	for sourceSchema, targetSchema := range mappings.Schemas {
+		if strings.EqualFold(obj.Schema, sourceSchema) {
+			obj.Schema = targetSchema
+			break
+		}
+	}
+	return obj
+}
+
+// mapDefinition rewrites the schema and database names of a source
+// definition into their target environment counterparts.
+func (c *Comparator) mapDefinition(definition string) string {
+	if len(c.Config.Mappings.Schemas) == 0 && len(c.Config.Mappings.Databases) == 0 {
+		return definition
+	}
+	return tsql.MapNames(definition, c.Config.Mappings.Schemas, c.Config.Mappings.Databases)
+}
+
+func objectKey(obj models.SchemaObject) string {
+	return fmt.Sprintf("%s.%s.%s", obj.Schema, obj.Name, obj.Type)
+}
+
+// replaceIdentifier replaces whole-word occurrences of name in definition,
+// including its bracket-quoted form.
+func replaceIdentifier(definition, name, newName string) string {
+	pattern := regexp.MustCompile(`(^|[^\w@#$])` + regexp.QuoteMeta(name) + `($|[^\w@#$])`)
+	return pattern.ReplaceAllString(definition, "${1}"+strings.ReplaceAll(newName, "$", "$$")+"${2}")
 }
 
-func generateDropStatement(obj models.SchemaObject, db *database.Database) (string, error) {
-	dropStatement := ""
-	var err error
+func (c *Comparator) normalizeDefinition(obj models.SchemaObject, definition string) string {
+	return NormalizeDefinition(c.Config, obj, definition)
+}
 
-	switch obj.Type {
-	case "USER_TABLE":
-		dropStatement, err = db.GetTableDropStatement(obj)
-	case "VIEW":
-		dropStatement = fmt.Sprintf("IF OBJECT_ID('[%s].[%s]', 'V') IS NOT NULL DROP VIEW [%s].[%s];", obj.Schema, obj.Name, obj.Schema, obj.Name)
-	case "SQL_STORED_PROCEDURE":
-		dropStatement = fmt.Sprintf("IF OBJECT_ID('[%s].[%s]', 'P') IS NOT NULL DROP PROCEDURE [%s].[%s];", obj.Schema, obj.Name, obj.Schema, obj.Name)
-	case "SQL_SCALAR_FUNCTION", "SQL_INLINE_TABLE_VALUED_FUNCTION", "SQL_TABLE_VALUED_FUNCTION":
-		dropStatement = fmt.Sprintf("IF OBJECT_ID('[%s].[%s]', 'FN') IS NOT NULL DROP FUNCTION [%s].[%s];", obj.Schema, obj.Name, obj.Schema, obj.Name)
-	case "SQL_TRIGGER":
-		dropStatement = fmt.Sprintf("IF OBJECT_ID('[%s].[%s]', 'TR') IS NOT NULL DROP TRIGGER [%s].[%s];", obj.Schema, obj.Name, obj.Schema, obj.Name)
-	default:
-		dropStatement = fmt.Sprintf("-- Unknown object type: %s. It must be deleted manually.", obj.Type)
+// NormalizeDefinition returns the form of a definition used for comparison,
+// with the configured ignore rules applied.
+func NormalizeDefinition(compareConfig config.CompareConfig, obj models.SchemaObject, definition string) string {
+	if obj.Type == "USER_TABLE" {
+		if compareConfig.IgnoreColumnOrder {
+			definition = sortTableColumns(definition)
+		}
+		if compareConfig.IgnoreIdentitySeed {
+			definition = identitySeedPattern.ReplaceAllString(definition, "IDENTITY(${1}")
+		}
 	}
 
-	dropStatement = fmt.Sprintf("%s\nGO", dropStatement)
+	return tsql.Normalize(definition, tsql.Options{
+		StripComments:            compareConfig.Normalize.StripComments,
+		FoldKeywordCase:          compareConfig.Normalize.FoldKeywordCase,
+		UnquoteIdentifiers:       compareConfig.Normalize.UnquoteIdentifiers,
+		IgnoreTrailingSemicolons: compareConfig.Normalize.IgnoreTrailingSemicolons,
+		IgnoreBlankLines:         compareConfig.Normalize.IgnoreBlankLines,
+		IgnoreWhitespace:         compareConfig.IgnoreWhitespace,
+	})
+}
+
+var identitySeedPattern = regexp.MustCompile(`IDENTITY\(\s*-?\d+\s*(,)`)
+
+// sortTableColumns sorts the column lines of a scripted CREATE TABLE by name.
+// Trailing commas are removed since they depend on the column position.
+func sortTableColumns(definition string) string {
+	lines := strings.Split(definition, "\n")
+
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "CREATE TABLE") {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return definition
+	}
+
+	end := start
+	for end < len(lines) && isColumnLine(lines[end]) {
+		lines[end] = strings.TrimSuffix(lines[end], ",")
+		end++
+	}
+	sort.Strings(lines[start:end])
 
-	return dropStatement, err
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,145 @@
+package comparator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// testDatabase returns a database of an engine that is not connected.
+func testDatabase(t *testing.T, engine string) *database.Database {
+	t.Helper()
+
+	dialect, err := database.DialectFor(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &database.Database{Dialect: dialect}
+}
+
+func TestUndatedTypes(t *testing.T) {
+	objects := []models.SchemaObject{
+		{Name: "orders", Type: "USER_TABLE"},
+		{Name: "customers", Type: "USER_TABLE"},
+		{Name: "active_orders", Type: "VIEW"},
+		{Name: "close_order", Type: "SQL_STORED_PROCEDURE"},
+	}
+
+	tests := []struct {
+		engine string
+		want   []string
+	}{
+		{database.EngineSQLServer, nil},
+		{database.EnginePostgres, []string{"USER_TABLE", "VIEW", "SQL_STORED_PROCEDURE"}},
+		{database.EngineMySQL, []string{"USER_TABLE", "VIEW"}},
+	}
+
+	for _, test := range tests {
+		if got := undatedTypes(testDatabase(t, test.engine), objects); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: undatedTypes = %q, want %q", test.engine, got, test.want)
+		}
+	}
+}
+
+func TestSortTableColumns(t *testing.T) {
+	definition := "CREATE TABLE [dbo].[orders] (\n" +
+		"    [total] decimal(10, 2) NULL,\n" +
+		"    [id] int IDENTITY(1, 1) NOT NULL,\n" +
+		"    [code] nvarchar(20) NOT NULL,\n" +
+		"    CONSTRAINT [PK_orders] PRIMARY KEY CLUSTERED ([id])\n" +
+		");\nGO\n"
+
+	want := "CREATE TABLE [dbo].[orders] (\n" +
+		"    [code] nvarchar(20) NOT NULL\n" +
+		"    [id] int IDENTITY(1, 1) NOT NULL\n" +
+		"    [total] decimal(10, 2) NULL\n" +
+		"    CONSTRAINT [PK_orders] PRIMARY KEY CLUSTERED ([id])\n" +
+		");\nGO\n"
+
+	if got := sortTableColumns(definition); got != want {
+		t.Errorf("sortTableColumns =\n%s\nwant\n%s", got, want)
+	}
+
+	view := "CREATE VIEW [dbo].[v] AS\n    SELECT 1 AS [b],\n    2 AS [a]\n"
+	if got := sortTableColumns(view); got != view {
+		t.Errorf("sortTableColumns changed a definition without CREATE TABLE:\n%s", got)
+	}
+}
+
+func TestNormalizeDefinitionIgnoreRules(t *testing.T) {
+	table := models.SchemaObject{Schema: "dbo", Name: "orders", Type: "USER_TABLE"}
+	source := "CREATE TABLE [dbo].[orders] (\n" +
+		"    [id] int IDENTITY(1, 1) NOT NULL,\n" +
+		"    [code] nvarchar(20) NOT NULL\n" +
+		");\n"
+	// the target was reseeded and had its columns added in another order
+	target := "CREATE TABLE [dbo].[orders] (\n" +
+		"    [code] nvarchar(20) NOT NULL,\n" +
+		"    [id] int IDENTITY(1000, 1) NOT NULL\n" +
+		");\n"
+
+	tests := []struct {
+		config config.CompareConfig
+		equal  bool
+	}{
+		{config.CompareConfig{}, false},
+		{config.CompareConfig{IgnoreColumnOrder: true}, false},
+		{config.CompareConfig{IgnoreIdentitySeed: true}, false},
+		{config.CompareConfig{IgnoreColumnOrder: true, IgnoreIdentitySeed: true}, true},
+	}
+
+	for _, test := range tests {
+		got := NormalizeDefinition(test.config, table, source) == NormalizeDefinition(test.config, table, target)
+		if got != test.equal {
+			t.Errorf("with %+v the definitions are equal: %v, want %v", test.config, got, test.equal)
+		}
+	}
+
+	// the rules apply to the comparison only, the scripted definition keeps
+	// the seed and column order of the source
+	rules := config.CompareConfig{IgnoreColumnOrder: true, IgnoreIdentitySeed: true}
+	if got := NormalizeDefinition(rules, table, target); got == target {
+		t.Error("NormalizeDefinition did not apply the rules to the table")
+	}
+	c := &Comparator{SourceDB: testDatabase(t, database.EngineSQLServer), TargetDB: testDatabase(t, database.EngineSQLServer), Config: rules}
+	if got := c.scriptDefinition(source); got != source {
+		t.Errorf("scriptDefinition applied the ignore rules to the script:\n%s", got)
+	}
+
+	// other objects keep their IDENTITY functions
+	view := models.SchemaObject{Schema: "dbo", Name: "v", Type: "VIEW"}
+	query := "SELECT IDENTITY(5, 1)"
+	if got, want := NormalizeDefinition(rules, view, query), NormalizeDefinition(config.CompareConfig{}, view, query); got != want {
+		t.Errorf("NormalizeDefinition removed the seed of a view: %q, want %q", got, want)
+	}
+}
+
+func TestAppendDependents(t *testing.T) {
+	definition := `CREATE TABLE "public"."orders" (
+    "id" integer NOT NULL,
+    "code" text
+);`
+	// as returned by TableDependents from pg_get_indexdef and
+	// pg_get_triggerdef
+	dependents := []string{
+		"CREATE INDEX ix_orders_code ON public.orders USING btree (code);",
+		"CREATE TRIGGER audit AFTER UPDATE ON public.orders FOR EACH ROW EXECUTE FUNCTION audit_order();",
+	}
+
+	want := definition + "\n" + dependents[0] + "\n" + dependents[1]
+	if got := appendDependents(definition, dependents, testDatabase(t, database.EnginePostgres).Dialect.BatchSeparator()); got != want {
+		t.Errorf("appendDependents for PostgreSQL =\n%s\nwant\n%s", got, want)
+	}
+
+	want = definition + "\nGO\n" + dependents[0] + "\nGO\n" + dependents[1]
+	if got := appendDependents(definition, dependents, "GO"); got != want {
+		t.Errorf("appendDependents with batch separators =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := appendDependents(definition, nil, ""); got != definition {
+		t.Errorf("appendDependents without dependents = %q, want the definition", got)
+	}
+}
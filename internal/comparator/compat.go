@@ -0,0 +1,122 @@
+package comparator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/tsql"
+)
+
+// feature is a syntax element that needs a minimum engine version or
+// database compatibility level.
+type feature struct {
+	name string
+	// words is the sequence of words that uses the feature, "*" matches any
+	// word
+	words []string
+	// function requires the words to be followed by an opening parenthesis
+	function      bool
+	major         int
+	build         int
+	compatibility int
+}
+
+var versionFeatures = []feature{
+	{name: "DROP ... IF EXISTS", words: []string{"DROP", "*", "IF", "EXISTS"}, major: 13},
+	{name: "STRING_SPLIT", words: []string{"STRING_SPLIT"}, function: true, major: 13, compatibility: 130},
+	{name: "OPENJSON", words: []string{"OPENJSON"}, function: true, major: 13, compatibility: 130},
+	{name: "JSON_VALUE", words: []string{"JSON_VALUE"}, function: true, major: 13},
+	{name: "JSON_QUERY", words: []string{"JSON_QUERY"}, function: true, major: 13},
+	{name: "JSON_MODIFY", words: []string{"JSON_MODIFY"}, function: true, major: 13},
+	{name: "ISJSON", words: []string{"ISJSON"}, function: true, major: 13},
+	{name: "STRING_AGG", words: []string{"STRING_AGG"}, function: true, major: 14},
+	{name: "TRIM", words: []string{"TRIM"}, function: true, major: 14},
+	{name: "CONCAT_WS", words: []string{"CONCAT_WS"}, function: true, major: 14},
+	{name: "TRANSLATE", words: []string{"TRANSLATE"}, function: true, major: 14},
+	{name: "APPROX_COUNT_DISTINCT", words: []string{"APPROX_COUNT_DISTINCT"}, function: true, major: 15},
+	{name: "GREATEST", words: []string{"GREATEST"}, function: true, major: 16},
+	{name: "LEAST", words: []string{"LEAST"}, function: true, major: 16},
+	{name: "DATETRUNC", words: []string{"DATETRUNC"}, function: true, major: 16},
+	{name: "DATE_BUCKET", words: []string{"DATE_BUCKET"}, function: true, major: 16},
+	{name: "GENERATE_SERIES", words: []string{"GENERATE_SERIES"}, function: true, major: 16, compatibility: 160},
+	{name: "JSON_OBJECT", words: []string{"JSON_OBJECT"}, function: true, major: 16},
+	{name: "JSON_ARRAY", words: []string{"JSON_ARRAY"}, function: true, major: 16},
+	{name: "IS DISTINCT FROM", words: []string{"IS", "DISTINCT", "FROM"}, major: 16},
+	{name: "IS NOT DISTINCT FROM", words: []string{"IS", "NOT", "DISTINCT", "FROM"}, major: 16},
+}
+
+// unsupportedFeatures describes the features of a definition that the
+// target engine or database compatibility level lacks.
+func unsupportedFeatures(definition string, server database.ServerInfo) []string {
+	var words []string
+	for _, token := range tsql.Tokenize(definition) {
+		switch token.Kind {
+		case tsql.Word:
+			words = append(words, strings.ToUpper(token.Text))
+		case tsql.Symbol, tsql.String, tsql.QuotedIdentifier, tsql.Number:
+			words = append(words, token.Text)
+		}
+	}
+
+	var unsupported []string
+	for _, f := range versionFeatures {
+		if !usesFeature(words, f) {
+			continue
+		}
+		switch {
+		case !server.AtLeast(f.major, f.build):
+			unsupported = append(unsupported, fmt.Sprintf("%s requires %s or later, the target runs %s",
+				f.name, database.ServerInfo{Major: f.major}.ProductName(), server.ProductName()))
+		case server.CompatibilityLevel < f.compatibility:
+			unsupported = append(unsupported, fmt.Sprintf("%s requires compatibility level %d, the target database is at %d",
+				f.name, f.compatibility, server.CompatibilityLevel))
+		}
+	}
+	return unsupported
+}
+
+func usesFeature(words []string, f feature) bool {
+	for i := 0; i+len(f.words) <= len(words); i++ {
+		matches := true
+		for j, word := range f.words {
+			if word != "*" && words[i+j] != word {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		if !f.function || (i+len(f.words) < len(words) && words[i+len(f.words)] == "(") {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptToTarget rewrites a source definition for the version of the target
+// engine and returns the warnings about the changes it made and the
+// features the target lacks.
+func adaptToTarget(definition string, server database.ServerInfo) (string, []string) {
+	var warnings []string
+
+	// definitions of other engines are written as they are
+	if !server.IsSQLServer() {
+		return definition, nil
+	}
+
+	if !server.SupportsCreateOrAlter() {
+		definition = tsql.SetCreateOrAlter(definition, false)
+	}
+
+	if !server.SupportsOptimizeForSequentialKey() {
+		var removed bool
+		definition, removed = tsql.RemoveOption(definition, "OPTIMIZE_FOR_SEQUENTIAL_KEY")
+		if removed {
+			warnings = append(warnings, fmt.Sprintf("OPTIMIZE_FOR_SEQUENTIAL_KEY was removed, the target runs %s", server.ProductName()))
+		}
+	}
+
+	return definition, append(warnings, unsupportedFeatures(definition, server)...)
+}
@@ -0,0 +1,98 @@
+package comparator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/database"
+)
+
+var (
+	sqlServer2014 = database.ServerInfo{ProductVersion: "12.0.6024.0", Major: 12, Build: 6024, CompatibilityLevel: 120}
+	sqlServer2016 = database.ServerInfo{ProductVersion: "13.0.1601.5", Major: 13, Build: 1601, CompatibilityLevel: 130}
+	sqlServer2019 = database.ServerInfo{ProductVersion: "15.0.2000.5", Major: 15, Build: 2000, CompatibilityLevel: 150}
+	azureSQL      = database.ServerInfo{ProductVersion: "12.0.2000.8", Major: 12, EngineEdition: database.EngineEditionAzureSQLDatabase, CompatibilityLevel: 150}
+)
+
+func TestAdaptToTarget(t *testing.T) {
+	procedure := "CREATE OR ALTER PROCEDURE [dbo].[p] AS SELECT STRING_AGG([name], ',') FROM [dbo].[t]"
+	index := "CREATE INDEX [ix] ON [dbo].[t] ([id]) WITH (PAD_INDEX = OFF, OPTIMIZE_FOR_SEQUENTIAL_KEY = OFF)"
+
+	tests := []struct {
+		name         string
+		definition   string
+		server       database.ServerInfo
+		want         string
+		wantWarnings []string
+	}{
+		{
+			"2014 procedure", procedure, sqlServer2014,
+			"CREATE PROCEDURE [dbo].[p] AS SELECT STRING_AGG([name], ',') FROM [dbo].[t]",
+			[]string{"STRING_AGG requires SQL Server 2017 or later, the target runs SQL Server 2014"},
+		},
+		{
+			// CREATE OR ALTER starts with SQL Server 2016 SP1
+			"2016 RTM procedure", "CREATE OR ALTER VIEW [dbo].[v] AS SELECT 1 AS [id]", sqlServer2016,
+			"CREATE VIEW [dbo].[v] AS SELECT 1 AS [id]", nil,
+		},
+		{
+			"2014 index", index, sqlServer2014,
+			"CREATE INDEX [ix] ON [dbo].[t] ([id]) WITH (PAD_INDEX = OFF)",
+			[]string{"OPTIMIZE_FOR_SEQUENTIAL_KEY was removed, the target runs SQL Server 2014"},
+		},
+		{"2019 procedure", procedure, sqlServer2019, procedure, nil},
+		{"2019 index", index, sqlServer2019, index, nil},
+		{"Azure procedure", procedure, azureSQL, procedure, nil},
+		{"PostgreSQL", procedure, database.ServerInfo{Product: "PostgreSQL", ProductVersion: "16.2"}, procedure, nil},
+	}
+
+	for _, test := range tests {
+		got, warnings := adaptToTarget(test.definition, test.server)
+		if got != test.want {
+			t.Errorf("%s: adaptToTarget =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(warnings, test.wantWarnings) {
+			t.Errorf("%s: warnings = %q, want %q", test.name, warnings, test.wantWarnings)
+		}
+	}
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	sqlServer2019At120 := sqlServer2019
+	sqlServer2019At120.CompatibilityLevel = 120
+
+	tests := []struct {
+		name       string
+		definition string
+		server     database.ServerInfo
+		want       []string
+	}{
+		{
+			"version", "SELECT GREATEST(a, b) FROM t", sqlServer2019,
+			[]string{"GREATEST requires SQL Server 2022 or later, the target runs SQL Server 2019"},
+		},
+		{
+			"compatibility level", "SELECT value FROM STRING_SPLIT(@list, ',')", sqlServer2019At120,
+			[]string{"STRING_SPLIT requires compatibility level 130, the target database is at 120"},
+		},
+		{
+			"Azure runs the latest engine", "SELECT GREATEST(a, b) FROM GENERATE_SERIES(1, 10)", azureSQL,
+			[]string{"GENERATE_SERIES requires compatibility level 160, the target database is at 150"},
+		},
+		{
+			"wildcard words", "DROP TABLE IF EXISTS #t", sqlServer2014,
+			[]string{"DROP ... IF EXISTS requires SQL Server 2016 or later, the target runs SQL Server 2014"},
+		},
+		{
+			"names that are not calls", "SELECT [TRIM], TRIM FROM t WHERE note = 'TRIM(x)' -- TRIM(y)", sqlServer2014,
+			nil,
+		},
+		{"supported", "SELECT TRIM(name) FROM t", sqlServer2019, nil},
+	}
+
+	for _, test := range tests {
+		if got := unsupportedFeatures(test.definition, test.server); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unsupportedFeatures = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
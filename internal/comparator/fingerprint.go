@@ -0,0 +1,121 @@
+package comparator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+const (
+	fingerprintPrefix = "-- dbgo:fingerprint "
+	absentMarker      = "absent"
+	// modifyDateLayout keeps the millisecond precision of DATETIME values and
+	// is read back by CONVERT style 126
+	modifyDateLayout = "2006-01-02T15:04:05.000"
+)
+
+// ObjectFingerprint records the state of a target object when a script was
+// generated, so a deployment can detect changes made to it in the meantime.
+type ObjectFingerprint struct {
+	Schema     string
+	Name       string
+	Type       string
+	Exists     bool
+	ModifyDate time.Time
+	Hash       string
+}
+
+// Fingerprint returns the hash of the normalized definition of an object.
+func Fingerprint(compareConfig config.CompareConfig, obj models.SchemaObject, definition string) string {
+	sum := sha256.Sum256([]byte(NormalizeDefinition(compareConfig, obj, definition)))
+	return hex.EncodeToString(sum[:])
+}
+
+// resultFingerprint returns the fingerprint of the target side of a result.
+func resultFingerprint(result models.DiffResult) ObjectFingerprint {
+	return ObjectFingerprint{
+		Schema:     result.TargetObject.Schema,
+		Name:       result.TargetObject.Name,
+		Type:       result.TargetObject.Type,
+		Exists:     result.Exists,
+		ModifyDate: result.TargetObject.ModifyDate,
+		Hash:       result.Fingerprint,
+	}
+}
+
+// String formats the fingerprint as a script comment line:
+// -- dbgo:fingerprint TYPE MODIFY_DATE HASH [schema].[name]
+func (f ObjectFingerprint) String() string {
+	modifyDate, hash := absentMarker, absentMarker
+	if f.Exists {
+		modifyDate, hash = f.ModifyDate.Format(modifyDateLayout), f.Hash
+	}
+	return fmt.Sprintf("%s%s %s %s %s", fingerprintPrefix, f.Type, modifyDate, hash, quoteName(f.Schema, f.Name))
+}
+
+// ParseFingerprints reads the fingerprint comments of a generated script.
+func ParseFingerprints(script string) ([]ObjectFingerprint, error) {
+	var fingerprints []ObjectFingerprint
+
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, fingerprintPrefix) {
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimPrefix(line, fingerprintPrefix), " ", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid fingerprint line: %s", line)
+		}
+
+		schema, name, err := parseQuotedName(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid object name in fingerprint line: %s", line)
+		}
+
+		fingerprint := ObjectFingerprint{Schema: schema, Name: name, Type: fields[0]}
+		if fields[2] != absentMarker {
+			modifyDate, err := time.Parse(modifyDateLayout, fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid modify date in fingerprint line: %s", line)
+			}
+			fingerprint.Exists = true
+			fingerprint.ModifyDate = modifyDate
+			fingerprint.Hash = fields[2]
+		}
+
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	return fingerprints, nil
+}
+
+// driftGuardObjects splits fingerprints into the objects that existed in the
+// target and those that did not.
+func driftGuardObjects(fingerprints []ObjectFingerprint) ([]models.SchemaObject, []models.SchemaObject) {
+	var existing, absent []models.SchemaObject
+	for _, f := range fingerprints {
+		obj := models.SchemaObject{Schema: f.Schema, Name: f.Name, Type: f.Type, ModifyDate: f.ModifyDate}
+		if f.Exists {
+			existing = append(existing, obj)
+		} else {
+			absent = append(absent, obj)
+		}
+	}
+	return existing, absent
+}
+
+// quoteName returns the bracket-quoted two-part name of an object.
+func quoteName(schema, name string) string {
+	return "[" + strings.ReplaceAll(schema, "]", "]]") + "].[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// quoteString returns value as a Unicode string literal.
+func quoteString(value string) string {
+	return "N'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
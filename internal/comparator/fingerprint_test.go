@@ -0,0 +1,72 @@
+package comparator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+func TestFingerprintRoundTrip(t *testing.T) {
+	fingerprints := []ObjectFingerprint{
+		{
+			Schema:     "dbo",
+			Name:       "Order]Lines",
+			Type:       "USER_TABLE",
+			Exists:     true,
+			ModifyDate: time.Date(2024, 5, 1, 13, 45, 30, 123000000, time.UTC),
+			Hash:       "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		},
+		{Schema: "sales", Name: "Get User", Type: "SQL_STORED_PROCEDURE"},
+	}
+
+	var lines []string
+	for _, fingerprint := range fingerprints {
+		lines = append(lines, fingerprint.String())
+	}
+	script := "-- header\r\n" + strings.Join(lines, "\r\n") + "\r\nSELECT 1\r\n"
+
+	if want := "-- dbgo:fingerprint SQL_STORED_PROCEDURE absent absent [sales].[Get User]"; lines[1] != want {
+		t.Errorf("String = %q, want %q", lines[1], want)
+	}
+
+	got, err := ParseFingerprints(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fingerprints) {
+		t.Errorf("ParseFingerprints =\n%+v\nwant\n%+v", got, fingerprints)
+	}
+}
+
+func TestParseFingerprintsErrors(t *testing.T) {
+	tests := map[string]string{
+		"-- dbgo:fingerprint VIEW absent [dbo].[v]":                "invalid fingerprint line",
+		"-- dbgo:fingerprint VIEW absent absent dbo.v":             "invalid object name",
+		"-- dbgo:fingerprint VIEW 2024-05-01 0123abcd [dbo].[v]":   "invalid modify date",
+		"-- dbgo:fingerprint VIEW 2024-05-01T13:45:30 x [dbo].[v]": "invalid modify date",
+		"-- dbgo:fingerprint VIEW absent absent [db].[dbo].[v]":    "invalid object name",
+	}
+
+	for line, want := range tests {
+		if _, err := ParseFingerprints(line); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseFingerprints(%q) error = %v, want %q", line, err, want)
+		}
+	}
+}
+
+func TestFingerprintIgnoresNormalizedDifferences(t *testing.T) {
+	view := models.SchemaObject{Schema: "dbo", Name: "v", Type: "VIEW"}
+	compareConfig := config.CompareConfig{IgnoreWhitespace: true}
+
+	a := Fingerprint(compareConfig, view, "CREATE VIEW v AS SELECT 1")
+	if b := Fingerprint(compareConfig, view, "CREATE VIEW v AS\n    SELECT 1"); a != b {
+		t.Error("the fingerprints differ in whitespace only")
+	}
+	if c := Fingerprint(compareConfig, view, "CREATE VIEW v AS SELECT 2"); a == c {
+		t.Error("the fingerprints of different definitions are equal")
+	}
+}
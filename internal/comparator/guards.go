@@ -0,0 +1,63 @@
+package comparator
+
+import (
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/models"
+	"github.com/victorlunam/dbgo/internal/tsql"
+)
+
+// classifyResult returns the risk of applying a result to the target. Tables
+// that are dropped and recreated lose their rows, and tables rebuilt without
+// some of their columns lose the values of those columns.
+func classifyResult(result models.DiffResult, losesData bool) models.ChangeRisk {
+	switch {
+	case result.Kind == models.DiffMissing:
+		return models.RiskSafe
+	case losesData:
+		return models.RiskDestructive
+	default:
+		// changed modules and renames can break callers, and recreated
+		// modules lose their permissions
+		return models.RiskRisky
+	}
+}
+
+// tableColumns returns the column names of a scripted CREATE TABLE.
+func tableColumns(definition string) []string {
+	var columns []string
+	for _, line := range strings.Split(definition, "\n") {
+		if !isColumnLine(line) {
+			continue
+		}
+		for _, token := range tsql.Tokenize(line) {
+			if token.Kind == tsql.QuotedIdentifier {
+				columns = append(columns, tsql.UnquoteIdentifier(token.Text))
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// isColumnLine reports whether a line of a scripted CREATE TABLE defines a
+// column, which starts with its quoted name.
+func isColumnLine(line string) bool {
+	return strings.HasPrefix(line, "    [") || strings.HasPrefix(line, "    \"")
+}
+
+// removedColumns returns the target columns that the source table lacks.
+func removedColumns(sourceDefinition, targetDefinition string) []string {
+	sourceColumns := make(map[string]bool)
+	for _, column := range tableColumns(sourceDefinition) {
+		sourceColumns[strings.ToLower(column)] = true
+	}
+
+	var removed []string
+	for _, column := range tableColumns(targetDefinition) {
+		if !sourceColumns[strings.ToLower(column)] {
+			removed = append(removed, column)
+		}
+	}
+	return removed
+}
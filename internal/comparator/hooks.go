@@ -0,0 +1,80 @@
+package comparator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/tsql"
+)
+
+const (
+	HookPreDeploy  = "Pre-deployment"
+	HookPostDeploy = "Post-deployment"
+)
+
+// Hook is a custom script embedded in the deployment script, such as a data
+// fix or a seed script, with its variables already substituted.
+type Hook struct {
+	Stage string
+	Path  string
+	SQL   string
+}
+
+// Hooks are the scripts run before and after the generated changes.
+type Hooks struct {
+	PreDeploy  []Hook
+	PostDeploy []Hook
+}
+
+// LoadHooks reads the hook files of the configuration and substitutes their
+// variables, with the values of environment taking precedence.
+func LoadHooks(hooksConfig config.HooksConfig, environment map[string]string) (Hooks, error) {
+	var hooks Hooks
+	var err error
+
+	variables := map[string]string{}
+	for name, value := range hooksConfig.Variables {
+		variables[strings.ToLower(name)] = value
+	}
+	for name, value := range environment {
+		variables[strings.ToLower(name)] = value
+	}
+
+	hooks.PreDeploy, err = loadHookFiles(HookPreDeploy, hooksConfig.PreDeploy, variables)
+	if err != nil {
+		return hooks, err
+	}
+	hooks.PostDeploy, err = loadHookFiles(HookPostDeploy, hooksConfig.PostDeploy, variables)
+	return hooks, err
+}
+
+func loadHookFiles(stage string, paths []string, variables map[string]string) ([]Hook, error) {
+	var hooks []Hook
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s script: %v", strings.ToLower(stage), err)
+		}
+
+		script, err := tsql.SubstituteVariables(string(content), variables)
+		if err != nil {
+			return nil, fmt.Errorf("error in %s script %s: %v", strings.ToLower(stage), path, err)
+		}
+
+		hooks = append(hooks, Hook{Stage: stage, Path: path, SQL: script})
+	}
+	return hooks, nil
+}
+
+// writeHooks embeds hook scripts, ending each one with a batch separator so
+// their batches never merge with the generated changes.
+func writeHooks(w io.Writer, hooks []Hook, separator string) {
+	for _, hook := range hooks {
+		fmt.Fprintf(w, "-- %s script: %s\n", hook.Stage, hook.Path)
+		fmt.Fprint(w, endWithNewline(hook.SQL))
+		fmt.Fprintf(w, "%s\n\n", separator)
+	}
+}
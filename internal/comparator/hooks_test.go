@@ -0,0 +1,109 @@
+package comparator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// writeHookFile writes a hook script to a temporary directory.
+func writeHookFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHooks(t *testing.T) {
+	hooksConfig := config.HooksConfig{
+		PreDeploy:  []string{writeHookFile(t, "pre.sql", "UPDATE [$(Schema)].[settings] SET [env] = N'$(env)'")},
+		PostDeploy: []string{writeHookFile(t, "post.sql", "EXEC dbo.seed\nGO\nEXEC dbo.reindex\n")},
+		Variables:  map[string]string{"Schema": "app", "env": "staging"},
+	}
+
+	// the variables of the target database override those of the hooks
+	hooks, err := LoadHooks(hooksConfig, map[string]string{"ENV": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hooks.PreDeploy) != 1 || len(hooks.PostDeploy) != 1 {
+		t.Fatalf("LoadHooks = %+v, want one hook of each stage", hooks)
+	}
+	pre := hooks.PreDeploy[0]
+	if want := "UPDATE [app].[settings] SET [env] = N'prod'"; pre.SQL != want || pre.Stage != HookPreDeploy {
+		t.Errorf("pre-deployment hook = %+v, want %q", pre, want)
+	}
+	if post := hooks.PostDeploy[0]; post.SQL != "EXEC dbo.seed\nGO\nEXEC dbo.reindex\n" || post.Stage != HookPostDeploy {
+		t.Errorf("post-deployment hook = %+v", post)
+	}
+}
+
+func TestLoadHooksErrors(t *testing.T) {
+	undefined := writeHookFile(t, "undefined.sql", "SELECT '$(missing)'")
+
+	tests := []struct {
+		hooksConfig config.HooksConfig
+		want        string
+	}{
+		{config.HooksConfig{PreDeploy: []string{filepath.Join(t.TempDir(), "absent.sql")}}, "error reading pre-deployment script"},
+		{config.HooksConfig{PostDeploy: []string{undefined}}, "error in post-deployment script " + undefined + ": undefined variables: missing"},
+	}
+
+	for _, test := range tests {
+		if _, err := LoadHooks(test.hooksConfig, nil); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("LoadHooks error = %v, want %q", err, test.want)
+		}
+	}
+}
+
+func TestWriteScriptHooks(t *testing.T) {
+	view := models.SchemaObject{Schema: "dbo", Name: "active_orders", Type: "VIEW"}
+	c := &Comparator{
+		SourceDB: testDatabase(t, database.EngineSQLServer),
+		TargetDB: testDatabase(t, database.EngineSQLServer),
+		Results: []models.DiffResult{{
+			Object:           view,
+			TargetObject:     view,
+			Kind:             models.DiffMissing,
+			Risk:             models.RiskSafe,
+			DifferenceScript: "CREATE VIEW [dbo].[active_orders] AS SELECT 1 AS id",
+		}},
+		Hooks: Hooks{
+			PreDeploy:  []Hook{{Stage: HookPreDeploy, Path: "pre.sql", SQL: "PRINT 'pre'"}},
+			PostDeploy: []Hook{{Stage: HookPostDeploy, Path: "post.sql", SQL: "PRINT 'post'\n"}},
+		},
+	}
+
+	var sb strings.Builder
+	c.writeScript(&sb)
+	script := sb.String()
+
+	// each hook ends with a separator so its batches stay apart from the
+	// generated changes
+	order := []string{
+		"-- Pre-deployment script: pre.sql\nPRINT 'pre'\nGO\n\n",
+		"-- Object: dbo.active_orders (VIEW)\n",
+		"CREATE VIEW [dbo].[active_orders] AS SELECT 1 AS id\nGO\n\n",
+		"-- Post-deployment script: post.sql\nPRINT 'post'\nGO\n\n",
+	}
+	position := -1
+	for _, part := range order {
+		index := strings.Index(script, part)
+		if index < 0 {
+			t.Fatalf("the script has no %q:\n%s", part, script)
+		}
+		if index < position {
+			t.Errorf("%q is out of order:\n%s", part, script)
+		}
+		position = index
+	}
+}
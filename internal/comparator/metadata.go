@@ -0,0 +1,119 @@
+package comparator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+	"github.com/victorlunam/dbgo/internal/tsql"
+)
+
+const (
+	sourcePrefix = "-- dbgo:source "
+	targetPrefix = "-- dbgo:target "
+	actionPrefix = "-- dbgo:action "
+)
+
+// ScriptAction describes the change a script makes to one target object.
+type ScriptAction struct {
+	Kind   models.DiffKind
+	Risk   models.ChangeRisk
+	Type   string
+	Schema string
+	Name   string
+}
+
+// ScriptMetadata is the information a generated script records about the
+// comparison it comes from, in comments that dbgo reads back when applying it.
+type ScriptMetadata struct {
+	SourceServer   string
+	SourceDatabase string
+	TargetServer   string
+	TargetDatabase string
+	Actions        []ScriptAction
+	Fingerprints   []ObjectFingerprint
+	// OwnTransaction is set when statements of the script begin and commit
+	// their own transaction.
+	OwnTransaction bool
+}
+
+// String formats the action as a script comment line:
+// -- dbgo:action KIND RISK TYPE [schema].[name]
+func (a ScriptAction) String() string {
+	return fmt.Sprintf("%s%s %s %s %s", actionPrefix, a.Kind, a.Risk, a.Type, quoteName(a.Schema, a.Name))
+}
+
+func resultAction(result models.DiffResult) ScriptAction {
+	return ScriptAction{
+		Kind:   result.Kind,
+		Risk:   result.Risk,
+		Type:   result.TargetObject.Type,
+		Schema: result.TargetObject.Schema,
+		Name:   result.TargetObject.Name,
+	}
+}
+
+// databaseMarker formats the identity of a database as a script comment line.
+func databaseMarker(prefix, server, database string) string {
+	return prefix + quoteName(server, database)
+}
+
+// DatabaseMarkers returns the comment lines identifying the source and target
+// databases of a script, which dbgo apply records in the deployment history.
+func DatabaseMarkers(sourceDB, targetDB *database.Database) string {
+	return databaseMarker(sourcePrefix, sourceDB.Config.Server+","+sourceDB.Config.Port, sourceDB.Config.Database) + "\n" +
+		databaseMarker(targetPrefix, targetDB.Config.Server+","+targetDB.Config.Port, targetDB.Config.Database) + "\n"
+}
+
+// ParseScriptMetadata reads the metadata comments of a generated script.
+func ParseScriptMetadata(script string) (ScriptMetadata, error) {
+	var metadata ScriptMetadata
+
+	fingerprints, err := ParseFingerprints(script)
+	if err != nil {
+		return metadata, err
+	}
+	metadata.Fingerprints = fingerprints
+
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		switch {
+		case strings.HasPrefix(line, sourcePrefix):
+			metadata.SourceServer, metadata.SourceDatabase, err = parseQuotedName(strings.TrimPrefix(line, sourcePrefix))
+		case strings.HasPrefix(line, targetPrefix):
+			metadata.TargetServer, metadata.TargetDatabase, err = parseQuotedName(strings.TrimPrefix(line, targetPrefix))
+		case line == database.OwnTransactionMarker:
+			metadata.OwnTransaction = true
+		case strings.HasPrefix(line, actionPrefix):
+			fields := strings.SplitN(strings.TrimPrefix(line, actionPrefix), " ", 4)
+			if len(fields) != 4 {
+				return metadata, fmt.Errorf("invalid action line: %s", line)
+			}
+			action := ScriptAction{Kind: models.DiffKind(fields[0]), Risk: models.ChangeRisk(fields[1]), Type: fields[2]}
+			action.Schema, action.Name, err = parseQuotedName(fields[3])
+			metadata.Actions = append(metadata.Actions, action)
+		}
+
+		if err != nil {
+			return metadata, fmt.Errorf("invalid metadata line %q: %v", line, err)
+		}
+	}
+
+	return metadata, nil
+}
+
+// parseQuotedName splits a bracket-quoted two-part name.
+func parseQuotedName(text string) (string, string, error) {
+	var parts []string
+	for _, token := range tsql.Tokenize(text) {
+		if token.Kind == tsql.QuotedIdentifier {
+			parts = append(parts, tsql.UnquoteIdentifier(token.Text))
+		}
+	}
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("expected a two-part name")
+	}
+	return parts[0], parts[1], nil
+}
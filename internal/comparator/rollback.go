@@ -0,0 +1,97 @@
+package comparator
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// writeRollbackScript writes a script that restores the target objects to
+// the definitions fetched during the comparison. Changes are reverted in the
+// reverse order of the deployment script, and the steps that cannot restore
+// the previous state, such as the rows of recreated tables, are marked.
+func (c *Comparator) writeRollbackScript(w io.Writer, scriptFileName string) {
+	fmt.Fprintf(w, "-- Rollback script for %s\n", scriptFileName)
+	fmt.Fprint(w, "-- Restores the target objects to their definitions at comparison time\n\n")
+
+	var irreversible []string
+	for _, result := range c.Results {
+		if !result.Excluded && result.Risk == models.RiskDestructive {
+			irreversible = append(irreversible, fmt.Sprintf("%s.%s", result.TargetObject.Schema, result.TargetObject.Name))
+		}
+	}
+	if len(irreversible) > 0 {
+		fmt.Fprint(w, "-- WARNING: the rows of these tables cannot be restored by this script, restore them from a backup:\n")
+		for _, name := range irreversible {
+			fmt.Fprintf(w, "--   %s\n", name)
+		}
+		fmt.Fprint(w, "\n")
+	}
+
+	for i := len(c.Results) - 1; i >= 0; i-- {
+		result := c.Results[i]
+		if result.Excluded {
+			continue
+		}
+
+		fmt.Fprintf(w, "-- Object: %s.%s (%s)\n", result.TargetObject.Schema, result.TargetObject.Name, result.Object.Type)
+		writeTableSizes(w, result)
+		statement, err := generateRollbackStatement(result, c.TargetDB.Dialect)
+		if err != nil {
+			color.Red("Error generating rollback statement for %s.%s: %v", result.TargetObject.Schema, result.TargetObject.Name, err)
+			// the object is left out of the script, which says so
+			statement = fmt.Sprintf("-- ERROR: the object cannot be reverted by this script, revert it manually: %v\n", err)
+		}
+		fmt.Fprint(w, endWithNewline(statement))
+		fmt.Fprintf(w, "%s\n\n", c.TargetDB.Dialect.BatchSeparator())
+	}
+}
+
+// generateRollbackStatement returns the statements that revert a result.
+func generateRollbackStatement(result models.DiffResult, dialect database.Dialect) (string, error) {
+	targetObj := result.TargetObject
+	// the deployment creates the object with its source name
+	deployedObj := targetObj
+	deployedObj.Name = result.Object.Name
+
+	if result.RollbackScript != "" {
+		return "-- Rebuild the table as it was before the deployment\n" + result.RollbackScript, nil
+	}
+	if result.Kind == models.DiffCaseRename && result.Object.Type == "USER_TABLE" && result.Risk != models.RiskDestructive {
+		return "-- Restore the previous name\n" + dialect.RenameStatement(deployedObj, targetObj.Name), nil
+	}
+
+	dropStatement, err := generateRollbackDrop(deployedObj, dialect)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case result.Kind == models.DiffMissing:
+		comment := "-- Drop the object created by the deployment\n"
+		if result.Object.Type == "USER_TABLE" {
+			comment += "-- WARNING: cannot be undone: rows inserted into the table after the deployment are lost\n"
+		}
+		return comment + dropStatement, nil
+
+	case result.Risk == models.RiskDestructive:
+		return fmt.Sprintf("-- Recreate the table as it was before the deployment\n"+
+			"-- WARNING: cannot be undone: the %d rows the table held are not restored\n%s\n%s",
+			result.TargetRowCount, dropStatement, endWithNewline(result.TargetDefinition)), nil
+
+	default:
+		return fmt.Sprintf("-- Recreate the object as it was before the deployment\n"+
+			"-- WARNING: permissions granted after the deployment are lost\n%s\n%s",
+			dropStatement, endWithNewline(result.TargetDefinition)), nil
+	}
+}
+
+// generateRollbackDrop drops an object created by the deployment. Unlike the
+// drops of the deployment script it cannot read the object from the target,
+// so tables are dropped along with their own constraints only.
+func generateRollbackDrop(obj models.SchemaObject, dialect database.Dialect) (string, error) {
+	return dialect.DropStatement(obj, nil)
+}
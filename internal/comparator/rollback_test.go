@@ -0,0 +1,38 @@
+package comparator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// failingDropDialect is a dialect that cannot drop objects.
+type failingDropDialect struct {
+	database.Dialect
+}
+
+func (failingDropDialect) DropStatement(obj models.SchemaObject, d *database.Database) (string, error) {
+	return "", errors.New("unsupported object")
+}
+
+func TestRollbackDropError(t *testing.T) {
+	target := testDatabase(t, database.EngineSQLServer)
+	target.Dialect = failingDropDialect{target.Dialect}
+
+	view := models.SchemaObject{Schema: "dbo", Name: "active_orders", Type: "VIEW"}
+	result := models.DiffResult{Object: view, TargetObject: view, Kind: models.DiffMissing, Risk: models.RiskSafe}
+
+	if _, err := generateRollbackStatement(result, target.Dialect); err == nil {
+		t.Fatal("generateRollbackStatement returned no error for a failing drop")
+	}
+
+	c := &Comparator{TargetDB: target, Results: []models.DiffResult{result}}
+	var sb strings.Builder
+	c.writeRollbackScript(&sb, "deploy.sql")
+	if !strings.Contains(sb.String(), "-- ERROR: the object cannot be reverted by this script, revert it manually: unsupported object\n") {
+		t.Errorf("the rollback script does not report the failing drop:\n%s", sb.String())
+	}
+}
@@ -0,0 +1,58 @@
+package comparator
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// loadSizes reads the table sizes and the database totals of both databases.
+// Sizes are left out of the reports when they cannot be read, usually for
+// lack of the VIEW DATABASE STATE permission, and for engines that do not
+// report them.
+func (c *Comparator) loadSizes() (database.TableSizes, database.TableSizes) {
+	var sourceTables, targetTables database.TableSizes
+
+	if c.SourceDB.ReportsSizes() {
+		tables, size, err := readSizes(c.SourceDB)
+		if err != nil {
+			color.Yellow("Table sizes of the source database are not reported: %v", err)
+		} else {
+			sourceTables, c.SourceSize = tables, &size
+		}
+	}
+
+	if c.TargetDB.ReportsSizes() {
+		tables, size, err := readSizes(c.TargetDB)
+		if err != nil {
+			color.Yellow("Table sizes of the target database are not reported: %v", err)
+		} else {
+			targetTables, c.TargetSize = tables, &size
+		}
+	}
+
+	return sourceTables, targetTables
+}
+
+func readSizes(db *database.Database) (database.TableSizes, models.DatabaseSize, error) {
+	tables, err := db.GetTableSizes()
+	if err != nil {
+		return nil, models.DatabaseSize{}, err
+	}
+	size, err := db.GetDatabaseSize(tables)
+	return tables, size, err
+}
+
+// writeTableSizes writes the sizes of a table on both sides as script
+// comments.
+func writeTableSizes(w io.Writer, result models.DiffResult) {
+	if result.SourceSize != nil {
+		fmt.Fprintf(w, "-- Source size: %s\n", result.SourceSize)
+	}
+	if result.TargetSize != nil {
+		fmt.Fprintf(w, "-- Target size: %s\n", result.TargetSize)
+	}
+}
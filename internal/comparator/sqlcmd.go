@@ -0,0 +1,54 @@
+package comparator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/tsql"
+)
+
+// scriptDefinition returns a source definition as written in the script: with
+// $(name) references to the variables in SQLCMD mode, or with the variable
+// values of the target otherwise.
+func (c *Comparator) scriptDefinition(definition string) string {
+	if c.Config.SQLCMDMode {
+		return tsql.ParameterizeVariables(definition, c.SourceDB.Config.Variables)
+	}
+	return tsql.ReplaceVariableValues(definition, c.SourceDB.Config.Variables, c.TargetDB.Config.Variables)
+}
+
+// sqlcmdVariables returns the variables defined by a SQLCMD mode script: the
+// target values, and the source values of variables the target lacks.
+func (c *Comparator) sqlcmdVariables() map[string]string {
+	variables := map[string]string{}
+	for name, value := range c.TargetDB.Config.Variables {
+		variables[name] = value
+	}
+	for name, value := range c.SourceDB.Config.Variables {
+		defined := false
+		for targetName := range variables {
+			defined = defined || strings.EqualFold(targetName, name)
+		}
+		if !defined {
+			variables[name] = value
+		}
+	}
+	return variables
+}
+
+// writeSetvars writes the :setvar header of a SQLCMD mode script.
+func writeSetvars(w io.Writer, variables map[string]string) {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprint(w, "-- SQLCMD mode script, run it with sqlcmd or dbgo apply\n")
+	for _, name := range names {
+		fmt.Fprintf(w, ":setvar %s \"%s\"\n", name, strings.ReplaceAll(variables[name], `"`, `""`))
+	}
+	fmt.Fprint(w, "\n")
+}
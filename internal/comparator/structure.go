@@ -0,0 +1,305 @@
+package comparator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// tableMismatches are the structural differences found in a table, empty
+// when both tables have the same structure.
+type tableMismatches struct {
+	Object       models.SchemaObject
+	TargetObject models.SchemaObject
+	Mismatches   []string
+}
+
+// IsCrossEngine reports whether the source and target run different engines,
+// whose definitions cannot be compared as text.
+func (c *Comparator) IsCrossEngine() bool {
+	return c.SourceDB.Dialect.Name() != c.TargetDB.Dialect.Name()
+}
+
+// CompareStructure compares the tables of databases running different
+// engines by their columns, primary keys, indexes and foreign keys. Column
+// types are matched through the type map of the configuration, and column
+// names are matched case-insensitively, since engines fold unquoted names in
+// different ways. The mismatches are written to a report instead of a
+// script, because the definitions of one engine cannot be run on the other.
+func (c *Comparator) CompareStructure(timestamp string) error {
+	// the default type map relates SQL Server types to those of the other
+	// engine
+	engine := c.TargetDB.Config.Engine
+	if engine == "" || engine == database.EngineSQLServer {
+		engine = c.SourceDB.Config.Engine
+	}
+	typeMap := c.Config.TypeMapFor(engine)
+
+	sourceTables, err := c.SourceDB.GetObjectsList([]string{"TABLE"})
+	if err != nil {
+		return fmt.Errorf("error getting tables from source database: %v", err)
+	}
+
+	color.Cyan("Found %d tables to compare in the source database", len(sourceTables))
+
+	targetTables, err := c.TargetDB.GetObjectsList([]string{"TABLE"})
+	if err != nil {
+		return fmt.Errorf("error getting tables from target database: %v", err)
+	}
+
+	color.Cyan("Source server: %s", c.SourceDB.Server)
+	color.Cyan("Target server: %s", c.TargetDB.Server)
+
+	isCaseSensitive := c.IsCaseSensitive()
+	targetTablesMap := make(map[string]models.SchemaObject)
+	for _, obj := range targetTables {
+		targetTablesMap[structureKey(obj, isCaseSensitive)] = obj
+	}
+
+	var results []tableMismatches
+	matched := make(map[string]bool)
+	for _, obj := range sourceTables {
+		key := structureKey(c.mapToTarget(obj), isCaseSensitive)
+		targetObj, exists := targetTablesMap[key]
+		if !exists {
+			color.Yellow("The table %s.%s does not exist in the target database", obj.Schema, obj.Name)
+			results = append(results, tableMismatches{Object: obj, Mismatches: []string{"missing in the target"}})
+			continue
+		}
+		matched[key] = true
+
+		sourceStructure, err := c.SourceDB.GetTableStructure(obj)
+		if err != nil {
+			color.Red("Error reading the structure of source table %s.%s: %v", obj.Schema, obj.Name, err)
+			continue
+		}
+		targetStructure, err := c.TargetDB.GetTableStructure(targetObj)
+		if err != nil {
+			color.Red("Error reading the structure of target table %s.%s: %v", targetObj.Schema, targetObj.Name, err)
+			continue
+		}
+
+		mismatches := c.structureMismatches(sourceStructure, targetStructure, typeMap)
+		if len(mismatches) > 0 {
+			color.Yellow("Structural differences found in %s.%s", obj.Schema, obj.Name)
+		}
+		results = append(results, tableMismatches{Object: obj, TargetObject: targetObj, Mismatches: mismatches})
+	}
+
+	for _, obj := range targetTables {
+		if !matched[structureKey(obj, isCaseSensitive)] {
+			color.Yellow("The table %s.%s only exists in the target database", obj.Schema, obj.Name)
+			results = append(results, tableMismatches{TargetObject: obj, Mismatches: []string{"only in the target"}})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return strings.ToLower(tableLabel(results[i])) < strings.ToLower(tableLabel(results[j]))
+	})
+
+	fileName := fmt.Sprintf("schema-structure-%s-%s-%s.txt", c.SourceDB.Name(), c.TargetDB.Name(), timestamp)
+	outputFile, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	defer outputFile.Close()
+
+	c.writeStructureReport(outputFile, results)
+
+	differing := 0
+	for _, result := range results {
+		if len(result.Mismatches) > 0 {
+			differing++
+		}
+	}
+	color.Cyan("Found structural differences in %d of %d tables", differing, len(results))
+
+	return nil
+}
+
+// structureKey is the key tables are matched by, folded unless names are
+// case sensitive.
+func structureKey(obj models.SchemaObject, isCaseSensitive bool) string {
+	key := obj.Schema + "." + obj.Name
+	if !isCaseSensitive {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+func tableLabel(result tableMismatches) string {
+	if result.Object.Name == "" {
+		return result.TargetObject.Schema + "." + result.TargetObject.Name
+	}
+	return result.Object.Schema + "." + result.Object.Name
+}
+
+// writeStructureReport lists the tables with structural differences, each
+// followed by its mismatches.
+func (c *Comparator) writeStructureReport(w io.Writer, results []tableMismatches) {
+	fmt.Fprintf(w, "Structural comparison of %s (%s) and %s (%s)\n", c.SourceDB.Name(), c.SourceDB.Server, c.TargetDB.Name(), c.TargetDB.Server)
+	fmt.Fprint(w, "Tables are compared by their columns, primary keys, indexes and foreign keys\n\n")
+
+	differing := 0
+	for _, result := range results {
+		if len(result.Mismatches) == 0 {
+			continue
+		}
+		differing++
+
+		label := tableLabel(result)
+		if result.Object.Name != "" && result.TargetObject.Name != "" {
+			label += " -> " + result.TargetObject.Schema + "." + result.TargetObject.Name
+		}
+		fmt.Fprintln(w, label)
+		for _, mismatch := range result.Mismatches {
+			fmt.Fprintf(w, "    %s\n", mismatch)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if differing == 0 {
+		fmt.Fprint(w, "No structural differences found\n")
+	}
+}
+
+// structureMismatches describes the differences between the structure of a
+// source table and its target table.
+func (c *Comparator) structureMismatches(source, target models.TableStructure, typeMap map[string][]string) []string {
+	var mismatches []string
+
+	targetColumns := make(map[string]models.ColumnStructure)
+	for _, column := range target.Columns {
+		targetColumns[strings.ToLower(column.Name)] = column
+	}
+	sourceColumns := make(map[string]bool)
+	for _, column := range source.Columns {
+		sourceColumns[strings.ToLower(column.Name)] = true
+
+		targetColumn, exists := targetColumns[strings.ToLower(column.Name)]
+		switch {
+		case !exists:
+			mismatches = append(mismatches, fmt.Sprintf("column %s: missing in the target", column.Name))
+			continue
+		case !typesMatch(typeMap, column.Type, targetColumn.Type):
+			mismatches = append(mismatches, fmt.Sprintf("column %s: type %s in the source, %s in the target", column.Name, column.Type, targetColumn.Type))
+		case column.Length != 0 && targetColumn.Length != 0 && column.Length != targetColumn.Length:
+			mismatches = append(mismatches, fmt.Sprintf("column %s: length %s in the source, %s in the target", column.Name, describeLength(column.Length), describeLength(targetColumn.Length)))
+		}
+		if column.Nullable != targetColumn.Nullable {
+			mismatches = append(mismatches, fmt.Sprintf("column %s: %s in the source, %s in the target", column.Name, nullability(column.Nullable), nullability(targetColumn.Nullable)))
+		}
+	}
+	for _, column := range target.Columns {
+		if !sourceColumns[strings.ToLower(column.Name)] {
+			mismatches = append(mismatches, fmt.Sprintf("column %s: only in the target", column.Name))
+		}
+	}
+
+	if columnList(source.PrimaryKey) != columnList(target.PrimaryKey) {
+		mismatches = append(mismatches, fmt.Sprintf("primary key: %s in the source, %s in the target", describeKey(source.PrimaryKey), describeKey(target.PrimaryKey)))
+	}
+
+	// indexes are named differently in each engine, so they are matched by
+	// their columns
+	indexKey := func(index models.IndexStructure) string {
+		return fmt.Sprintf("%t %s", index.Unique, columnList(index.Columns))
+	}
+	mismatches = append(mismatches, keyMismatches(source.Indexes, target.Indexes, indexKey, describeIndex)...)
+
+	foreignKeyKey := func(foreignKey models.ForeignKeyStructure) string {
+		referenced := c.mapToTarget(models.SchemaObject{Schema: foreignKey.ReferencedSchema, Name: foreignKey.ReferencedTable})
+		return fmt.Sprintf("%s %s.%s %s", columnList(foreignKey.Columns), strings.ToLower(referenced.Schema), strings.ToLower(referenced.Name), columnList(foreignKey.ReferencedColumns))
+	}
+	mismatches = append(mismatches, keyMismatches(source.ForeignKeys, target.ForeignKeys, foreignKeyKey, describeForeignKey)...)
+
+	return mismatches
+}
+
+// typesMatch reports whether two column types are the same type or are
+// equivalent in the type map, in either direction.
+func typesMatch(typeMap map[string][]string, sourceType, targetType string) bool {
+	sourceType, targetType = strings.ToLower(sourceType), strings.ToLower(targetType)
+	if sourceType == targetType {
+		return true
+	}
+	return containsType(typeMap[sourceType], targetType) || containsType(typeMap[targetType], sourceType)
+}
+
+func containsType(types []string, typeName string) bool {
+	for _, t := range types {
+		if strings.EqualFold(t, typeName) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyMismatches reports the indexes or foreign keys of the source without a
+// target counterpart with the same key, and those only in the target.
+func keyMismatches[T any](source, target []T, key func(T) string, describe func(T) string) []string {
+	var mismatches []string
+
+	targetKeys := make(map[string]bool)
+	for _, item := range target {
+		targetKeys[key(item)] = true
+	}
+	sourceKeys := make(map[string]bool)
+	for _, item := range source {
+		sourceKeys[key(item)] = true
+		if !targetKeys[key(item)] {
+			mismatches = append(mismatches, describe(item)+": missing in the target")
+		}
+	}
+	for _, item := range target {
+		if !sourceKeys[key(item)] {
+			mismatches = append(mismatches, describe(item)+": only in the target")
+		}
+	}
+	return mismatches
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// columnList joins column names for matching, which ignores their case.
+func columnList(columns []string) string {
+	return strings.ToLower(strings.Join(columns, ", "))
+}
+
+func describeLength(length int) string {
+	if length < 0 {
+		return "max"
+	}
+	return fmt.Sprint(length)
+}
+
+func describeKey(columns []string) string {
+	if len(columns) == 0 {
+		return "none"
+	}
+	return "(" + strings.Join(columns, ", ") + ")"
+}
+
+func describeIndex(index models.IndexStructure) string {
+	kind := "index"
+	if index.Unique {
+		kind = "unique index"
+	}
+	return fmt.Sprintf("%s %s %s", kind, index.Name, describeKey(index.Columns))
+}
+
+func describeForeignKey(foreignKey models.ForeignKeyStructure) string {
+	return fmt.Sprintf("foreign key %s %s references %s.%s %s", foreignKey.Name, describeKey(foreignKey.Columns),
+		foreignKey.ReferencedSchema, foreignKey.ReferencedTable, describeKey(foreignKey.ReferencedColumns))
+}
@@ -0,0 +1,66 @@
+package comparator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+func TestStructureMismatchesColumns(t *testing.T) {
+	c := &Comparator{}
+	typeMap := config.CompareConfig{}.TypeMapFor("postgres")
+
+	source := models.TableStructure{Columns: []models.ColumnStructure{
+		{Name: "Id", Type: "bigint"},
+		{Name: "Code", Type: "int"},
+		{Name: "Name", Type: "nvarchar", Length: -1},
+		{Name: "Notes", Type: "nvarchar", Length: 200, Nullable: true},
+		{Name: "Price", Type: "float"},
+		{Name: "Flag", Type: "bit"},
+	}}
+	target := models.TableStructure{Columns: []models.ColumnStructure{
+		{Name: "id", Type: "integer"},
+		{Name: "code", Type: "integer"},
+		{Name: "name", Type: "varchar", Length: 50},
+		{Name: "notes", Type: "text", Length: -1, Nullable: true},
+		{Name: "price", Type: "real"},
+		{Name: "flag", Type: "boolean", Nullable: true},
+	}}
+
+	want := []string{
+		"column Id: type bigint in the source, integer in the target",
+		"column Name: length max in the source, 50 in the target",
+		"column Notes: length 200 in the source, max in the target",
+		"column Price: type float in the source, real in the target",
+		"column Flag: NOT NULL in the source, NULL in the target",
+	}
+	if got := c.structureMismatches(source, target, typeMap); !reflect.DeepEqual(got, want) {
+		t.Errorf("structureMismatches =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestTypeMapFor(t *testing.T) {
+	compareConfig := config.CompareConfig{TypeMap: map[string][]string{"Money": {"decimal"}}}
+
+	tests := []struct {
+		engine, sourceType, targetType string
+		want                           bool
+	}{
+		{"sqlite", "bigint", "integer", true},
+		{"postgres", "bigint", "integer", false},
+		{"postgres", "integer", "int", true},
+		{"mysql", "float", "float", true},
+		{"mariadb", "float", "double", true},
+		{"postgres", "money", "numeric", false},
+		{"postgres", "money", "decimal", true},
+	}
+
+	for _, test := range tests {
+		typeMap := compareConfig.TypeMapFor(test.engine)
+		if got := typesMatch(typeMap, test.sourceType, test.targetType); got != test.want {
+			t.Errorf("%s: typesMatch(%s, %s) = %t, want %t", test.engine, test.sourceType, test.targetType, got, test.want)
+		}
+	}
+}
@@ -1,9 +1,269 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	CaseSensitivityAuto        = "auto"
+	CaseSensitivitySensitive   = "sensitive"
+	CaseSensitivityInsensitive = "insensitive"
+)
+
 type DatabaseConfig struct {
+	// Engine is the database engine, "sqlserver" when empty
+	Engine   string `json:"engine"`
 	Server   string `json:"server"`
 	Port     string `json:"port"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	Database string `json:"database"`
+	// Variables are the SQLCMD variable values of the environment, such as
+	// the DatabaseName or LinkedServer referenced as $(name) in scripts
+	Variables map[string]string `json:"variables"`
+}
+
+type Config struct {
+	Source  DatabaseConfig `json:"source"`
+	Target  DatabaseConfig `json:"target"`
+	Compare CompareConfig  `json:"compare"`
+	Filters FiltersConfig  `json:"filters"`
+	History HistoryConfig  `json:"history"`
+	Hooks   HooksConfig    `json:"hooks"`
+	Data    DataConfig     `json:"data"`
+}
+
+// DataConfig selects the tables whose rows are compared by the data command,
+// with the same rules as the object filters.
+type DataConfig struct {
+	Tables []string `json:"tables"`
+	// BatchSize is the number of rows changed by each batch of a
+	// synchronization script
+	BatchSize int `json:"batchSize"`
+}
+
+// HooksConfig lists the .sql files embedded before and after the generated
+// changes. Their $(name) references are replaced with the variable values,
+// overridden by the variables of the target database.
+type HooksConfig struct {
+	PreDeploy  []string          `json:"preDeploy"`
+	PostDeploy []string          `json:"postDeploy"`
+	Variables  map[string]string `json:"variables"`
+}
+
+// HistoryConfig enables the deployment history tables that dbgo creates in
+// the target database to record every applied script.
+type HistoryConfig struct {
+	Enabled bool   `json:"enabled"`
+	Schema  string `json:"schema"`
+}
+
+// FiltersConfig limits the objects taken into account. Rules are written as
+// [TYPE:][schema.]name with glob or /regex/ patterns.
+type FiltersConfig struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+type CompareConfig struct {
+	// CaseSensitivity controls how object names are matched between databases.
+	// "auto" follows the collation of each database, "sensitive" and
+	// "insensitive" force the matching mode.
+	CaseSensitivity string `json:"caseSensitivity"`
+
+	Normalize NormalizeConfig `json:"normalize"`
+
+	// Ignore rules for differences that are expected between environments
+	IgnoreColumnOrder           bool `json:"ignoreColumnOrder"`
+	IgnoreSystemConstraintNames bool `json:"ignoreSystemConstraintNames"`
+	IgnoreIdentitySeed          bool `json:"ignoreIdentitySeed"`
+	IgnoreCollation             bool `json:"ignoreCollation"`
+	IgnoreFKCheckState          bool `json:"ignoreFkCheckState"`
+	IgnoreTableOptions          bool `json:"ignoreTableOptions"`
+	IgnoreWhitespace            bool `json:"ignoreWhitespace"`
+
+	Mappings MappingsConfig `json:"mappings"`
+
+	// AllowTargetNewer keeps objects modified more recently in the target
+	// than in the source in the generated script
+	AllowTargetNewer bool `json:"allowTargetNewer"`
+
+	// AllowDataLoss generates destructive changes without the guards that
+	// stop the script when the affected tables contain data
+	AllowDataLoss bool `json:"allowDataLoss"`
+
+	// SQLCMDMode writes the script with $(name) references to the variables
+	// of the environments and :setvar headers with the target values
+	SQLCMDMode bool `json:"sqlcmdMode"`
+
+	// Idempotent guards every statement of the script with an existence
+	// check, so a partially applied script can be run again
+	Idempotent bool `json:"idempotent"`
+
+	// TypeMap lists the target types equivalent to each source type when
+	// the source and target run different engines. Entries replace the
+	// default entry of the same type, see TypeMapFor, and types are matched
+	// both ways.
+	TypeMap map[string][]string `json:"typeMap"`
+}
+
+// MappingsConfig maps schema and database names of the source environment to
+// the names used in the target environment.
+type MappingsConfig struct {
+	Schemas   map[string]string `json:"schemas"`
+	Databases map[string]string `json:"databases"`
+}
+
+// NormalizeConfig toggles the rules applied to module definitions before they
+// are compared, so formatting-only changes are not reported as differences.
+type NormalizeConfig struct {
+	StripComments            bool `json:"stripComments"`
+	FoldKeywordCase          bool `json:"foldKeywordCase"`
+	UnquoteIdentifiers       bool `json:"unquoteIdentifiers"`
+	IgnoreTrailingSemicolons bool `json:"ignoreTrailingSemicolons"`
+	IgnoreBlankLines         bool `json:"ignoreBlankLines"`
+}
+
+func Default() Config {
+	return Config{
+		History: HistoryConfig{
+			Schema: "dbo",
+		},
+		Data: DataConfig{
+			BatchSize: 1000,
+		},
+		Compare: CompareConfig{
+			CaseSensitivity: CaseSensitivityAuto,
+			Normalize: NormalizeConfig{
+				StripComments:            true,
+				FoldKeywordCase:          true,
+				UnquoteIdentifiers:       true,
+				IgnoreTrailingSemicolons: true,
+				IgnoreBlankLines:         true,
+			},
+		},
+	}
+}
+
+// defaultTypeMaps are the types of each engine that hold the same values as
+// a SQL Server type. Conversions that narrow the values, such as bigint to a
+// PostgreSQL integer or float to a MySQL float, are left out so they are
+// reported.
+var defaultTypeMaps = map[string]map[string][]string{
+	"postgres": {
+		"bit":              {"boolean"},
+		"datetime":         {"timestamp"},
+		"datetime2":        {"timestamp"},
+		"datetimeoffset":   {"timestamptz"},
+		"decimal":          {"numeric"},
+		"float":            {"double precision"},
+		"image":            {"bytea"},
+		"int":              {"integer"},
+		"money":            {"numeric"},
+		"nchar":            {"char"},
+		"ntext":            {"text"},
+		"nvarchar":         {"varchar", "text"},
+		"smalldatetime":    {"timestamp"},
+		"smallmoney":       {"numeric"},
+		"tinyint":          {"smallint"},
+		"uniqueidentifier": {"uuid"},
+		"varbinary":        {"bytea"},
+		"varchar":          {"text"},
+	},
+	"mysql": {
+		"bit":              {"tinyint"},
+		"datetime2":        {"datetime"},
+		"float":            {"double"},
+		"image":            {"longblob"},
+		"money":            {"decimal"},
+		"nchar":            {"char"},
+		"ntext":            {"longtext"},
+		"nvarchar":         {"varchar", "text", "mediumtext", "longtext"},
+		"real":             {"float"},
+		"smalldatetime":    {"datetime"},
+		"smallmoney":       {"decimal"},
+		"text":             {"longtext"},
+		"tinyint":          {"smallint"},
+		"uniqueidentifier": {"char"},
+		"varbinary":        {"blob", "mediumblob", "longblob"},
+		"varchar":          {"text", "mediumtext", "longtext"},
+		"xml":              {"longtext"},
+	},
+	// SQLite stores integers in up to 8 bytes and reals as 8 byte floats
+	"sqlite": {
+		"bigint":           {"integer"},
+		"binary":           {"blob"},
+		"bit":              {"integer", "boolean"},
+		"char":             {"text"},
+		"date":             {"text"},
+		"datetime":         {"text", "datetime"},
+		"datetime2":        {"text", "datetime"},
+		"datetimeoffset":   {"text"},
+		"decimal":          {"numeric"},
+		"float":            {"real"},
+		"image":            {"blob"},
+		"int":              {"integer"},
+		"money":            {"numeric"},
+		"nchar":            {"text"},
+		"ntext":            {"text"},
+		"nvarchar":         {"text"},
+		"smalldatetime":    {"text", "datetime"},
+		"smallint":         {"integer"},
+		"smallmoney":       {"numeric"},
+		"time":             {"text"},
+		"tinyint":          {"integer"},
+		"uniqueidentifier": {"text"},
+		"varbinary":        {"blob"},
+		"varchar":          {"text"},
+		"xml":              {"text"},
+	},
+}
+
+// DefaultTypeMap returns the usual equivalences between SQL Server types and
+// the types of another engine, "postgres", "mysql", "mariadb" or "sqlite".
+func DefaultTypeMap(engine string) map[string][]string {
+	if engine == "mariadb" {
+		engine = "mysql"
+	}
+
+	typeMap := make(map[string][]string)
+	for sourceType, targetTypes := range defaultTypeMaps[engine] {
+		typeMap[sourceType] = targetTypes
+	}
+	return typeMap
+}
+
+// TypeMapFor returns the default type map of an engine, with the entries of
+// the configuration replacing the default entries of the same type.
+func (c CompareConfig) TypeMapFor(engine string) map[string][]string {
+	typeMap := DefaultTypeMap(engine)
+	for sourceType, targetTypes := range c.TypeMap {
+		typeMap[strings.ToLower(sourceType)] = targetTypes
+	}
+	return typeMap
+}
+
+// Load reads the configuration file at path on top of the default values.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return cfg, err
+	}
+
+	switch cfg.Compare.CaseSensitivity {
+	case CaseSensitivityAuto, CaseSensitivitySensitive, CaseSensitivityInsensitive:
+	default:
+		return cfg, fmt.Errorf("invalid compare.caseSensitivity %q", cfg.Compare.CaseSensitivity)
+	}
+
+	return cfg, nil
 }
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// Column describes a table column as needed to read and compare its data.
+type Column struct {
+	Name       string
+	Type       string
+	Collation  string
+	IsComputed bool
+	IsIdentity bool
+}
+
+// IsCaseInsensitive reports whether the column compares text without regard
+// to case.
+func (c Column) IsCaseInsensitive() bool {
+	return strings.Contains(strings.ToUpper(c.Collation), "_CI")
+}
+
+// IsText reports whether the column holds character data.
+func (c Column) IsText() bool {
+	switch c.Type {
+	case "char", "varchar", "nchar", "nvarchar", "sysname":
+		return true
+	}
+	return false
+}
+
+// IsComparable reports whether the column holds data that can be compared
+// between databases. Computed columns derive from other columns and
+// rowversion values are generated by each database.
+func (c Column) IsComparable() bool {
+	return !c.IsComputed && c.Type != "timestamp"
+}
+
+// DataReader is implemented by dialects whose rows the data command compares
+// and synchronizes.
+type DataReader interface {
+	Columns(d *Database, obj models.SchemaObject) ([]Column, error)
+	PrimaryKey(d *Database, obj models.SchemaObject) ([]string, error)
+	QueryRows(ctx context.Context, d *Database, obj models.SchemaObject, columns []string, orderBy []Column, keyRange *KeyRange) (*sql.Rows, error)
+	RangeChecksum(ctx context.Context, d *Database, obj models.SchemaObject, columns []string, keyRange *KeyRange) (Checksum, error)
+	KeyBounds(ctx context.Context, d *Database, obj models.SchemaObject, column string) (int64, int64, bool, error)
+	TableReferences(d *Database) ([]TableReference, error)
+}
+
+// ComparesData reports whether the rows of the database can be compared by
+// the data command.
+func (d *Database) ComparesData() bool {
+	_, ok := d.Dialect.(DataReader)
+	return ok
+}
+
+func (d *Database) dataReader() (DataReader, error) {
+	reader, ok := d.Dialect.(DataReader)
+	if !ok {
+		return nil, fmt.Errorf("data comparison is not available on %s", d.Product())
+	}
+	return reader, nil
+}
+
+// GetColumns returns the columns of a table in their declaration order.
+func (d *Database) GetColumns(obj models.SchemaObject) ([]Column, error) {
+	reader, err := d.dataReader()
+	if err != nil {
+		return nil, err
+	}
+	return reader.Columns(d, obj)
+}
+
+// GetPrimaryKey returns the primary key columns of a table in key order, or
+// no columns when the table has no primary key.
+func (d *Database) GetPrimaryKey(obj models.SchemaObject) ([]string, error) {
+	reader, err := d.dataReader()
+	if err != nil {
+		return nil, err
+	}
+	return reader.PrimaryKey(d, obj)
+}
+
+// QueryRows selects columns of the rows of a table, ordered by the orderBy
+// columns. Text is ordered by code point, whatever the collation of the
+// column, and columns of a case-insensitive collation by their lowercase
+// text. Every row is selected when keyRange is nil.
+func (d *Database) QueryRows(ctx context.Context, obj models.SchemaObject, columns []string, orderBy []Column, keyRange *KeyRange) (*sql.Rows, error) {
+	reader, err := d.dataReader()
+	if err != nil {
+		return nil, err
+	}
+	return reader.QueryRows(ctx, d, obj, columns, orderBy, keyRange)
+}
+
+// RangeChecksum returns the row count and checksum of the rows of a key
+// range.
+func (d *Database) RangeChecksum(ctx context.Context, obj models.SchemaObject, columns []string, keyRange *KeyRange) (Checksum, error) {
+	reader, err := d.dataReader()
+	if err != nil {
+		return Checksum{}, err
+	}
+	return reader.RangeChecksum(ctx, d, obj, columns, keyRange)
+}
+
+// KeyBounds returns the lowest and highest values of an integer key column,
+// or false when the table has no rows.
+func (d *Database) KeyBounds(ctx context.Context, obj models.SchemaObject, column string) (int64, int64, bool, error) {
+	reader, err := d.dataReader()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return reader.KeyBounds(ctx, d, obj, column)
+}
+
+// GetTableReferences returns the tables referenced by the foreign keys of
+// every table in the database.
+func (d *Database) GetTableReferences() ([]TableReference, error) {
+	reader, err := d.dataReader()
+	if err != nil {
+		return nil, err
+	}
+	return reader.TableReferences(d)
+}
+
+// Columns reads the columns of a table from sys.columns. User types are
+// reported with their base system type.
+func (sqlServerDialect) Columns(d *Database, obj models.SchemaObject) ([]Column, error) {
+	query := `
+	SELECT c.name, TYPE_NAME(c.system_type_id), ISNULL(c.collation_name, ''), c.is_computed, c.is_identity
+	FROM sys.columns c
+	WHERE c.object_id = OBJECT_ID(@name)
+	ORDER BY c.column_id
+	`
+
+	rows, err := d.DB.Query(query, sql.Named("name", quoteName(obj.Schema, obj.Name)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var column Column
+		if err := rows.Scan(&column.Name, &column.Type, &column.Collation, &column.IsComputed, &column.IsIdentity); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+func (sqlServerDialect) PrimaryKey(d *Database, obj models.SchemaObject) ([]string, error) {
+	query := `
+	SELECT c.name
+	FROM sys.indexes i
+	JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+	JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+	WHERE i.object_id = OBJECT_ID(@name) AND i.is_primary_key = 1
+	ORDER BY ic.key_ordinal
+	`
+
+	rows, err := d.DB.Query(query, sql.Named("name", quoteName(obj.Schema, obj.Name)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keyColumns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		keyColumns = append(keyColumns, column)
+	}
+
+	return keyColumns, rows.Err()
+}
+
+// KeyRange limits the rows of a table to those whose integer key column is
+// between Low and High, both included.
+type KeyRange struct {
+	Column string
+	Low    int64
+	High   int64
+}
+
+func (r *KeyRange) condition() string {
+	return fmt.Sprintf(" WHERE %s BETWEEN @low AND @high", quoteColumns([]string{r.Column}))
+}
+
+func (r *KeyRange) args() []any {
+	return []any{sql.Named("low", r.Low), sql.Named("high", r.High)}
+}
+
+func (sqlServerDialect) QueryRows(ctx context.Context, d *Database, obj models.SchemaObject, columns []string, orderBy []Column, keyRange *KeyRange) (*sql.Rows, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", quoteColumns(columns), quoteName(obj.Schema, obj.Name))
+	var args []any
+	if keyRange != nil {
+		query += keyRange.condition()
+		args = keyRange.args()
+	}
+	if len(orderBy) > 0 {
+		query += " ORDER BY " + orderByList(orderBy)
+	}
+	return d.DB.QueryContext(ctx, query, args...)
+}
+
+// orderByList returns the ORDER BY expressions of columns. The binary
+// collation orders text by code point, as Go compares strings, instead of
+// the linguistic order of Windows collations.
+func orderByList(columns []Column) string {
+	expressions := make([]string, len(columns))
+	for i, column := range columns {
+		expression := quoteColumns([]string{column.Name})
+		if column.IsText() {
+			if column.IsCaseInsensitive() {
+				expression = "LOWER(" + expression + ")"
+			}
+			expression += " COLLATE Latin1_General_BIN2"
+		}
+		expressions[i] = expression
+	}
+	return strings.Join(expressions, ", ")
+}
+
+// Checksum is the row count and aggregated checksum of a set of rows.
+type Checksum struct {
+	Rows     int64
+	Checksum int64
+}
+
+// RangeChecksum returns the row count and the CHECKSUM_AGG of the
+// BINARY_CHECKSUM of columns over the rows of a key range. Only the checksum
+// leaves the server, so ranges of any size are cheap to compare.
+func (sqlServerDialect) RangeChecksum(ctx context.Context, d *Database, obj models.SchemaObject, columns []string, keyRange *KeyRange) (Checksum, error) {
+	query := fmt.Sprintf("SELECT COUNT_BIG(*), ISNULL(CHECKSUM_AGG(BINARY_CHECKSUM(%s)), 0) FROM %s%s",
+		quoteColumns(columns), quoteName(obj.Schema, obj.Name), keyRange.condition())
+
+	var checksum Checksum
+	err := d.DB.QueryRowContext(ctx, query, keyRange.args()...).Scan(&checksum.Rows, &checksum.Checksum)
+	return checksum, err
+}
+
+func (sqlServerDialect) KeyBounds(ctx context.Context, d *Database, obj models.SchemaObject, column string) (int64, int64, bool, error) {
+	query := fmt.Sprintf("SELECT MIN(%[1]s), MAX(%[1]s) FROM %[2]s", quoteColumns([]string{column}), quoteName(obj.Schema, obj.Name))
+
+	var low, high sql.NullInt64
+	if err := d.DB.QueryRowContext(ctx, query).Scan(&low, &high); err != nil {
+		return 0, 0, false, err
+	}
+	return low.Int64, high.Int64, low.Valid, nil
+}
+
+func quoteName(schema, name string) string {
+	return "[" + strings.ReplaceAll(schema, "]", "]]") + "].[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = "[" + strings.ReplaceAll(column, "]", "]]") + "]"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// TableReference is a foreign key from a table to the table it references.
+type TableReference struct {
+	Schema           string
+	Name             string
+	ReferencedSchema string
+	ReferencedName   string
+}
+
+// TableReferences reads the foreign keys between the tables from
+// sys.foreign_keys.
+func (sqlServerDialect) TableReferences(d *Database) ([]TableReference, error) {
+	query := `
+	SELECT DISTINCT
+		OBJECT_SCHEMA_NAME(fk.parent_object_id), OBJECT_NAME(fk.parent_object_id),
+		OBJECT_SCHEMA_NAME(fk.referenced_object_id), OBJECT_NAME(fk.referenced_object_id)
+	FROM sys.foreign_keys fk
+	`
+
+	rows, err := d.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var references []TableReference
+	for rows.Next() {
+		var reference TableReference
+		if err := rows.Scan(&reference.Schema, &reference.Name, &reference.ReferencedSchema, &reference.ReferencedName); err != nil {
+			return nil, err
+		}
+		references = append(references, reference)
+	}
+
+	return references, rows.Err()
+}
@@ -0,0 +1,16 @@
+package database
+
+import "testing"
+
+func TestOrderByList(t *testing.T) {
+	columns := []Column{
+		{Name: "code", Type: "nvarchar", Collation: "Latin1_General_CI_AS"},
+		{Name: "region", Type: "char", Collation: "Latin1_General_CS_AS"},
+		{Name: "id", Type: "int"},
+	}
+
+	want := "LOWER([code]) COLLATE Latin1_General_BIN2, [region] COLLATE Latin1_General_BIN2, [id]"
+	if got := orderByList(columns); got != want {
+		t.Errorf("orderByList = %q, want %q", got, want)
+	}
+}
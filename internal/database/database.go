@@ -1,333 +1,170 @@
 package database
 
 import (
-	"context"
 	"database/sql"
-	"fmt"
-	"net/url"
-	"strings"
 
-	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/filter"
 	"github.com/victorlunam/dbgo/internal/models"
 )
 
 type Database struct {
-	Config config.DatabaseConfig
-	DB     *sql.DB
+	Config        config.DatabaseConfig
+	DB            *sql.DB
+	Collation     string
+	Server        ServerInfo
+	ScriptOptions ScriptOptions
+	Filter        *filter.Filter
+	Dialect       Dialect
 }
 
-func Connect(config config.DatabaseConfig) (*Database, error) {
-	query := url.Values{}
-	query.Add("app name", "DBGO")
-
-	connString := fmt.Sprintf("Server=%s,%s;Database=%s;User Id=%s;Password=%s;TrustServerCertificate=true",
-		config.Server, config.Port, config.Database, config.User, config.Password)
+// objectTypeMapping maps the object types shown in the selector to the
+// sys.objects type descriptions they cover, which every dialect uses to
+// describe the objects of its catalog.
+var objectTypeMapping = map[string][]string{
+	"TABLE":     {"USER_TABLE"},
+	"VIEW":      {"VIEW", "MATERIALIZED_VIEW"},
+	"PROCEDURE": {"SQL_STORED_PROCEDURE"},
+	"FUNCTION":  {"SQL_SCALAR_FUNCTION", "SQL_INLINE_TABLE_VALUED_FUNCTION", "SQL_TABLE_VALUED_FUNCTION"},
+	"TRIGGER":   {"SQL_TRIGGER"},
+	"SEQUENCE":  {"SEQUENCE_OBJECT"},
+	"TYPE":      {"USER_DEFINED_TYPE", "DOMAIN"},
+	"INDEX":     {"INDEX"},
+}
 
-	db, err := sql.Open("sqlserver", connString)
-	if err != nil {
-		return nil, err
+// ScriptOptionsFromConfig returns the script options matching the ignore
+// rules of a comparison.
+func ScriptOptionsFromConfig(compareConfig config.CompareConfig) ScriptOptions {
+	return ScriptOptions{
+		IgnoreSystemConstraintNames: compareConfig.IgnoreSystemConstraintNames,
+		IgnoreCollation:             compareConfig.IgnoreCollation,
+		IgnoreFKCheckState:          compareConfig.IgnoreFKCheckState,
+		IgnoreTableOptions:          compareConfig.IgnoreTableOptions,
 	}
+}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, err
+// ObjectTypeCategory returns the selector object type of a sys.objects type
+// description.
+func ObjectTypeCategory(typeDesc string) string {
+	for category, typeDescs := range objectTypeMapping {
+		if containsObjectType(typeDescs, typeDesc) {
+			return category
+		}
 	}
-
-	return &Database{
-		Config: config,
-		DB:     db,
-	}, nil
+	return typeDesc
 }
 
-func (d *Database) Close() error {
-	return d.DB.Close()
+// ScriptOptions leaves environment-specific attributes out of the scripted
+// table definitions, so the target keeps its own defaults for them.
+type ScriptOptions struct {
+	IgnoreSystemConstraintNames bool
+	IgnoreCollation             bool
+	IgnoreFKCheckState          bool
+	IgnoreTableOptions          bool
 }
 
-func (d *Database) GetObjectsList(objectTypes []string) ([]models.SchemaObject, error) {
-	var objects []models.SchemaObject
-	ctx := context.Background()
-
-	query := `
-	SELECT 
-		SCHEMA_NAME(o.schema_id) as schema_name,
-		o.name as object_name, 
-		o.type_desc as object_type 
-	FROM 
-		sys.objects o
-	WHERE 
-		o.type_desc IN (%s)
-		AND o.is_ms_shipped = 0
-	ORDER BY 
-		o.type_desc, o.name
-	`
-
-	typeMapping := map[string]string{
-		"TABLE":     "'USER_TABLE'",
-		"VIEW":      "'VIEW'",
-		"PROCEDURE": "'SQL_STORED_PROCEDURE'",
-		"FUNCTION":  "'SQL_SCALAR_FUNCTION', 'SQL_INLINE_TABLE_VALUED_FUNCTION', 'SQL_TABLE_VALUED_FUNCTION'",
-		"TRIGGER":   "'SQL_TRIGGER'",
+// collateClause returns the COLLATE clause of a scripted column or type, which
+// is left out when collations are ignored.
+func (options ScriptOptions) collateClause(collation string) string {
+	if collation == "" || options.IgnoreCollation {
+		return ""
 	}
+	return " COLLATE " + collation
+}
 
-	var sqlTypes []string
-	for _, objType := range objectTypes {
-		if mappedType, ok := typeMapping[objType]; ok {
-			sqlTypes = append(sqlTypes, mappedType)
-		}
+// Connect opens a connection to a database with the dialect of its engine.
+func Connect(config config.DatabaseConfig) (*Database, error) {
+	dialect, err := DialectFor(config.Engine)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(objectTypes) > 0 {
-		typesStr := strings.Join(sqlTypes, ", ")
-		finalQuery := fmt.Sprintf(query, typesStr)
+	db, err := sql.Open(dialect.DriverName(), dialect.DataSourceName(config))
+	if err != nil {
+		return nil, err
+	}
 
-		rows, err := d.DB.QueryContext(ctx, finalQuery)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
 
-		for rows.Next() {
-			var obj models.SchemaObject
-			if err := rows.Scan(&obj.Schema, &obj.Name, &obj.Type); err != nil {
-				return nil, err
-			}
-			objects = append(objects, obj)
-		}
+	database := &Database{
+		Config:  config,
+		DB:      db,
+		Dialect: dialect,
+	}
+	if err := dialect.Inspect(database); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return objects, nil
+	return database, nil
 }
 
-func (d *Database) GetObjectDefinition(obj models.SchemaObject) (string, error) {
-	ctx := context.Background()
-	var definition string
-
-	switch obj.Type {
-	case "USER_TABLE": // for tables, get the definition through a query to sys.columns
-		tableQuery := `
-		WITH IndexCTE AS (
-			SELECT 
-				ic.object_id,
-				ic.index_id,
-				i.name AS index_name,
-				i.type_desc AS index_type,
-				i.is_primary_key,
-				i.is_unique,
-				i.is_unique_constraint,
-				(
-					SELECT c.name + ',' 
-					FROM sys.index_columns ic2
-					JOIN sys.columns c ON ic2.object_id = c.object_id AND ic2.column_id = c.column_id
-					WHERE ic2.object_id = ic.object_id AND ic2.index_id = ic.index_id
-					ORDER BY ic2.key_ordinal
-					FOR XML PATH('')
-				) AS columns
-			FROM 
-				sys.indexes i
-			JOIN 
-				sys.index_columns ic ON i.object_id = ic.object_id AND i.index_id = ic.index_id
-			WHERE 
-				i.name IS NOT NULL
-			GROUP BY 
-				ic.object_id, ic.index_id, i.name, i.type_desc, i.is_primary_key, i.is_unique, i.is_unique_constraint
-		)
-		SELECT
-			'CREATE TABLE [' + SCHEMA_NAME(t.schema_id) + '].[' + t.name + '] (' + CHAR(10) +
-			(
-				SELECT 
-					'    [' + c.name + '] ' + 
-					CASE 
-						WHEN c.is_computed = 1 THEN 'AS ' + cc.definition 
-						ELSE 
-							'[' + tp.name + ']' + 
-							CASE 
-								WHEN tp.name IN ('varchar', 'nvarchar', 'char', 'nchar') THEN '(' + 
-									CASE WHEN c.max_length = -1 THEN 'MAX' 
-									ELSE 
-										CASE WHEN tp.name IN ('nvarchar', 'nchar') 
-											THEN CAST(c.max_length/2 AS VARCHAR(10)) 
-											ELSE CAST(c.max_length AS VARCHAR(10)) 
-										END 
-									END + ')'
-								WHEN tp.name IN ('decimal', 'numeric') THEN '(' + CAST(c.precision AS VARCHAR(10)) + ', ' + CAST(c.scale AS VARCHAR(10)) + ')'
-								ELSE ''
-							END +
-							-- Add IDENTITY property if column is identity
-							CASE WHEN c.is_identity = 1 
-								THEN ' IDENTITY(' + 
-									CAST(IDENT_SEED(SCHEMA_NAME(t.schema_id) + '.' + t.name) AS VARCHAR(10)) + ',' + 
-									CAST(IDENT_INCR(SCHEMA_NAME(t.schema_id) + '.' + t.name) AS VARCHAR(10)) + ')'
-								ELSE '' 
-							END +
-							CASE WHEN c.is_nullable = 1 THEN ' NULL' ELSE ' NOT NULL' END
-					END +
-					CASE WHEN c.column_id = (SELECT MAX(column_id) FROM sys.columns c2 WHERE c2.object_id = t.object_id) AND 
-						NOT EXISTS (SELECT 1 FROM sys.indexes i WHERE i.object_id = t.object_id AND i.is_primary_key = 1)
-						THEN ''
-						ELSE ','
-					END + CHAR(10)
-				FROM 
-					sys.columns c
-				LEFT JOIN 
-					sys.types tp ON c.user_type_id = tp.user_type_id
-				LEFT JOIN 
-					sys.computed_columns cc ON c.object_id = cc.object_id AND c.column_id = cc.column_id
-				WHERE 
-					c.object_id = t.object_id
-				ORDER BY 
-					c.column_id
-				FOR XML PATH('')
-			) +
-			ISNULL((
-				SELECT 
-					CASE 
-						WHEN i.is_primary_key = 1 THEN '    CONSTRAINT [' + i.index_name + '] PRIMARY KEY ' + 
-							CASE WHEN i.index_type LIKE '%CLUSTER%' THEN 'CLUSTERED' ELSE 'NONCLUSTERED' END +
-							' (' + ISNULL(STUFF(i.columns, LEN(i.columns), 1, ''), '') + ')' + CHAR(10)
-						WHEN i.is_unique_constraint = 1 THEN '    CONSTRAINT [' + i.index_name + '] UNIQUE ' + 
-							CASE WHEN i.index_type LIKE '%CLUSTER%' THEN 'CLUSTERED' ELSE 'NONCLUSTERED' END +
-							' (' + ISNULL(STUFF(i.columns, LEN(i.columns), 1, ''), '') + ')' + CHAR(10)
-						ELSE ''
-					END
-				FROM 
-					IndexCTE i
-				WHERE 
-					i.object_id = t.object_id
-				AND
-					(i.is_primary_key = 1 OR i.is_unique_constraint = 1)
-				FOR XML PATH('')
-			), '') +
-			');' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10) +
-			-- 'SET ANSI_PADDING OFF' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10) +
-			-- Generate default constraints as separate ALTER TABLE statements
-			ISNULL((
-				SELECT 
-					'ALTER TABLE [' + SCHEMA_NAME(t.schema_id) + '].[' + t.name + '] ADD CONSTRAINT [' + 
-					dc.name + '] DEFAULT ' + dc.definition + ' FOR [' + c.name + '];' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10)
-				FROM 
-					sys.columns c
-				JOIN 
-					sys.default_constraints dc ON c.default_object_id = dc.object_id
-				WHERE 
-					c.object_id = t.object_id
-				FOR XML PATH('')
-			), '')
-		FROM 
-			sys.tables t
-		WHERE 
-			t.name = @name AND SCHEMA_NAME(t.schema_id) = @schema
-		`
-
-		err := d.DB.QueryRowContext(ctx, tableQuery, sql.Named("name", obj.Name), sql.Named("schema", obj.Schema)).Scan(&definition)
-		if err != nil {
-			return "", err
-		}
+// IsCaseSensitive reports whether identifiers are case sensitive under the
+// database collation.
+func (d *Database) IsCaseSensitive() bool {
+	return d.Dialect.IsCaseSensitive(d.Collation)
+}
 
-		// get foreign key constraints separately
-		fkQuery := `
-		SELECT 
-			'ALTER TABLE [' + SCHEMA_NAME(tab.schema_id) + '].[' + tab.name + ']  WITH CHECK ADD  CONSTRAINT [' + 
-			fk.name + '] FOREIGN KEY([' + 
-			ISNULL(STUFF((
-				SELECT ',' + COL_NAME(fkc.parent_object_id, fkc.parent_column_id)
-				FROM sys.foreign_key_columns fkc
-				WHERE fkc.constraint_object_id = fk.object_id
-				ORDER BY fkc.constraint_column_id
-				FOR XML PATH('')
-			), 1, 1, ''), '') + '])' + 
-			CHAR(10) + 'REFERENCES [' + SCHEMA_NAME(ref_tab.schema_id) + '].[' + ref_tab.name + '] ([' +
-			ISNULL(STUFF((
-				SELECT ',' + COL_NAME(fkc.referenced_object_id, fkc.referenced_column_id)
-				FROM sys.foreign_key_columns fkc
-				WHERE fkc.constraint_object_id = fk.object_id
-				ORDER BY fkc.constraint_column_id
-				FOR XML PATH('')
-			), 1, 1, ''), '') + '])' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10) +
-			'ALTER TABLE [' + SCHEMA_NAME(tab.schema_id) + '].[' + tab.name + '] CHECK CONSTRAINT [' + 
-			fk.name + ']' + CHAR(10)
-		FROM 
-			sys.foreign_keys fk
-		JOIN 
-			sys.tables tab ON fk.parent_object_id = tab.object_id
-		JOIN 
-			sys.tables ref_tab ON fk.referenced_object_id = ref_tab.object_id
-		WHERE 
-			tab.name = @name AND SCHEMA_NAME(tab.schema_id) = @schema
-		ORDER BY fk.name;
-		`
+// Name returns the name of the database used in file names.
+func (d *Database) Name() string {
+	return d.Dialect.DatabaseName(d.Config)
+}
 
-		fkRows, err := d.DB.QueryContext(ctx, fkQuery, sql.Named("name", obj.Name), sql.Named("schema", obj.Schema))
-		if err != nil {
-			return "", err
-		}
-		defer fkRows.Close()
+// Product returns the product name of the server, such as MariaDB for a
+// server connected with the MySQL dialect, for messages to the user.
+func (d *Database) Product() string {
+	if d.Server.Product != "" {
+		return d.Server.Product
+	}
+	return d.Dialect.Name()
+}
 
-		var fkConstraints []string
-		for fkRows.Next() {
-			var fkStatement string
-			if err := fkRows.Scan(&fkStatement); err != nil {
-				return "", err
-			}
-			fkConstraints = append(fkConstraints, fkStatement)
-		}
+func (d *Database) Close() error {
+	return d.DB.Close()
+}
 
-		if len(fkConstraints) > 0 {
-			definition += "\n" + strings.Join(fkConstraints, "\n")
-		}
+func (d *Database) GetObjectsList(objectTypes []string) ([]models.SchemaObject, error) {
+	var typeDescs []string
+	for _, objType := range objectTypes {
+		typeDescs = append(typeDescs, objectTypeMapping[objType]...)
+	}
 
-	default: // for views, procedures, functions and triggers, use sys.sql_modules
-		query := `
-		SELECT definition
-		FROM sys.sql_modules m
-		JOIN sys.objects o ON m.object_id = o.object_id
-		WHERE o.name = @name AND SCHEMA_NAME(o.schema_id) = @schema
-		`
+	objects, err := d.Dialect.ListObjects(d, typeDescs)
+	if err != nil {
+		return nil, err
+	}
 
-		err := d.DB.QueryRowContext(ctx, query, sql.Named("name", obj.Name), sql.Named("schema", obj.Schema)).Scan(&definition)
-		if err != nil {
-			return "", err
+	// filtered objects are skipped here so their definitions are never fetched
+	var allowed []models.SchemaObject
+	for _, obj := range objects {
+		if d.Filter.Allows(obj.Schema, obj.Name, ObjectTypeCategory(obj.Type), obj.Type) {
+			allowed = append(allowed, obj)
 		}
 	}
-
-	return definition, nil
+	return allowed, nil
 }
 
-func (d *Database) GetTableDropStatement(obj models.SchemaObject) (string, error) {
-	ctx := context.Background()
-	var dropStatement string
+// FindObject looks up a single object by schema and name.
+func (d *Database) FindObject(schema, name string) (models.SchemaObject, bool, error) {
+	return d.Dialect.FindObject(d, schema, name)
+}
 
-	query := `
-	SELECT 
-		ISNULL(STUFF((
-			-- Get Foreign Key constraint drops
-			SELECT CHAR(10) + 'ALTER TABLE [' + SCHEMA_NAME(tab.schema_id) + '].[' + tab.name + '] DROP CONSTRAINT [' + fk.name + ']' + CHAR(10) + 'GO' + CHAR(10)
-			FROM sys.foreign_keys fk
-			JOIN sys.tables tab ON fk.parent_object_id = tab.object_id
-			WHERE tab.name = @name AND SCHEMA_NAME(tab.schema_id) = @schema
-			FOR XML PATH('')
-		), 1, 1, ''), '') +
-		ISNULL(STUFF((
-			-- Get Default constraint drops
-			SELECT CHAR(10) + 'ALTER TABLE [' + SCHEMA_NAME(t.schema_id) + '].[' + t.name + '] DROP CONSTRAINT [' + dc.name + ']' + CHAR(10) + 'GO' + CHAR(10)
-			FROM sys.tables t
-			JOIN sys.default_constraints dc ON t.object_id = dc.parent_object_id
-			WHERE t.name = @name AND SCHEMA_NAME(t.schema_id) = @schema
-			FOR XML PATH('')
-		), 1, 1, ''), '') +
-		-- Add final table drop
-		CHAR(10) + 'IF OBJECT_ID(''[' + @schema + '].[' + @name + ']'', ''U'') IS NOT NULL' + CHAR(10) +
-		'DROP TABLE [' + @schema + '].[' + @name + ']' + CHAR(10)
-	`
+func (d *Database) GetObjectDefinition(obj models.SchemaObject) (string, error) {
+	return d.Dialect.ObjectDefinition(d, obj)
+}
 
-	err := d.DB.QueryRowContext(ctx, query,
-		sql.Named("name", obj.Name),
-		sql.Named("schema", obj.Schema)).Scan(&dropStatement)
-	if err != nil {
-		return "", err
-	}
+// GetRowCount returns the number of rows of a table.
+func (d *Database) GetRowCount(obj models.SchemaObject) (int64, error) {
+	return d.Dialect.RowCount(d, obj)
+}
 
-	return dropStatement, nil
+// DropStatement returns the statement that drops an object from the
+// database.
+func (d *Database) DropStatement(obj models.SchemaObject) (string, error) {
+	return d.Dialect.DropStatement(obj, d)
 }
 
 func containsObjectType(slice []string, item string) bool {
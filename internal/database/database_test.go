@@ -0,0 +1,33 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+)
+
+func TestScriptOptionsFromConfig(t *testing.T) {
+	options := ScriptOptionsFromConfig(config.CompareConfig{IgnoreCollation: true, IgnoreTableOptions: true})
+	want := ScriptOptions{IgnoreCollation: true, IgnoreTableOptions: true}
+	if options != want {
+		t.Errorf("ScriptOptionsFromConfig = %+v, want %+v", options, want)
+	}
+}
+
+func TestCollateClause(t *testing.T) {
+	tests := []struct {
+		options   ScriptOptions
+		collation string
+		want      string
+	}{
+		{ScriptOptions{}, `"C"`, ` COLLATE "C"`},
+		{ScriptOptions{}, "", ""},
+		{ScriptOptions{IgnoreCollation: true}, `"C"`, ""},
+	}
+
+	for _, test := range tests {
+		if got := test.options.collateClause(test.collation); got != test.want {
+			t.Errorf("collateClause(%q) with %+v = %q, want %q", test.collation, test.options, got, test.want)
+		}
+	}
+}
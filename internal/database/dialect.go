@@ -0,0 +1,215 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// Dialect is the engine-specific part of a database: how to connect to it,
+// read its catalog and write the statements of a script. Objects are
+// described with the sys.objects type descriptions of SQL Server, such as
+// USER_TABLE or SQL_STORED_PROCEDURE, whatever the engine.
+type Dialect interface {
+	// Name is the name of the engine, which names MySQL for MariaDB servers
+	// as well; Database.Product names the product of the server
+	Name() string
+	// DriverName is the database/sql driver that opens the connections
+	DriverName() string
+	DataSourceName(config config.DatabaseConfig) string
+	// DatabaseName is the name of the database used in file names
+	DatabaseName(config config.DatabaseConfig) string
+	// Inspect reads the collation and server information of a new connection
+	Inspect(d *Database) error
+	IsCaseSensitive(collation string) bool
+	// ObjectTypes are the object types of the selector the engine supports
+	ObjectTypes() []string
+	// RecordsModifyDate reports whether objects of a type carry the date of
+	// their last change, which hotfix protection compares
+	RecordsModifyDate(objectType string) bool
+
+	// ListObjects returns the user objects of the given types
+	ListObjects(d *Database, typeDescs []string) ([]models.SchemaObject, error)
+	FindObject(d *Database, schema, name string) (models.SchemaObject, bool, error)
+	// ObjectDefinition returns the script that creates an object
+	ObjectDefinition(d *Database, obj models.SchemaObject) (string, error)
+	// DropStatement returns the batch that drops an object from d. Without a
+	// database the statement must work on every version of the engine.
+	DropStatement(obj models.SchemaObject, d *Database) (string, error)
+	RenameStatement(obj models.SchemaObject, newName string) string
+	// RowCount returns the number of rows of a table, which may be an
+	// estimate read from the statistics of the engine
+	RowCount(d *Database, obj models.SchemaObject) (int64, error)
+	// TableStructure returns the columns, keys, indexes and foreign keys of
+	// a table, which are compared when the databases run different engines
+	TableStructure(d *Database, obj models.SchemaObject) (models.TableStructure, error)
+
+	QuoteIdentifier(name string) string
+	// BatchSeparator is the line written between the batches of a script
+	BatchSeparator() string
+	// Transaction returns the statements that wrap a script in a transaction
+	Transaction() Transaction
+}
+
+// Transaction holds the statements run by apply around a script. Setup runs
+// on the connection first, whether the script is wrapped or not.
+type Transaction struct {
+	Setup    string
+	Begin    string
+	Commit   string
+	Rollback string
+}
+
+// TableRebuilder is implemented by dialects that change tables by copying
+// their rows into a table with the new definition, since they cannot alter
+// most of a table in place.
+type TableRebuilder interface {
+	RebuildStatement(d *Database, obj models.SchemaObject, fromDefinition, toDefinition string) (TableRebuild, error)
+}
+
+// TableRebuild is the statement that rebuilds a table, with the columns whose
+// values it does not copy.
+type TableRebuild struct {
+	Statement      string
+	DroppedColumns []string
+}
+
+// OwnTransactionMarker is the comment line of statements that begin and
+// commit their own transaction, which cannot run inside the transaction of
+// dbgo apply --transaction.
+const OwnTransactionMarker = "-- dbgo:own-transaction"
+
+// DataLossGuarder is implemented by dialects whose scripts can stop before a
+// table holding rows is dropped.
+type DataLossGuarder interface {
+	// DataLossGuard returns the statements that stop the script when the
+	// table contains rows, naming the removed columns in the error. The
+	// guard of an idempotent script must not fail if the table is missing.
+	DataLossGuard(obj models.SchemaObject, removed []string, idempotent bool) string
+}
+
+// IdempotentScripter is implemented by dialects that can guard every
+// statement of a script with an existence check.
+type IdempotentScripter interface {
+	IdempotentScript(result models.DiffResult) string
+}
+
+// DriftGuarder is implemented by dialects whose scripts can check that the
+// target objects did not change since the comparison, so the script stops
+// when run outside dbgo apply.
+type DriftGuarder interface {
+	// DriftGuard returns a batch starting with DriftGuardHeader. Existing
+	// objects must keep their modify date and absent ones must not exist.
+	DriftGuard(existing, absent []models.SchemaObject) string
+}
+
+// DriftGuardHeader is the first line of a drift guard batch.
+const DriftGuardHeader = "-- Drift guard: stop if the target changed since the comparison"
+
+// SizeReader is implemented by dialects that report the space used by the
+// tables of a database.
+type SizeReader interface {
+	TableSizes(d *Database) (TableSizes, error)
+	DatabaseSize(d *Database, tables TableSizes) (models.DatabaseSize, error)
+}
+
+// DependentScripter is implemented by dialects that drop the indexes and
+// triggers of a table along with it while scripting them as objects of their
+// own, so a table that is dropped and created again must get them back.
+type DependentScripter interface {
+	TableDependents(d *Database, obj models.SchemaObject) ([]string, error)
+}
+
+var dialects = make(map[string]Dialect)
+
+// TableDependents returns the statements that create the objects dropped
+// along with a table, none when the table script creates them.
+func (d *Database) TableDependents(obj models.SchemaObject) ([]string, error) {
+	scripter, ok := d.Dialect.(DependentScripter)
+	if !ok {
+		return nil, nil
+	}
+	return scripter.TableDependents(d, obj)
+}
+
+// DataLossGuard returns the guard that stops a script before a table holding
+// rows is dropped, and false when the dialect cannot write one.
+func (d *Database) DataLossGuard(obj models.SchemaObject, removed []string, idempotent bool) (string, bool) {
+	guarder, ok := d.Dialect.(DataLossGuarder)
+	if !ok {
+		return "", false
+	}
+	return guarder.DataLossGuard(obj, removed, idempotent), true
+}
+
+// WritesIdempotentScripts reports whether the dialect can guard the
+// statements of a script.
+func (d *Database) WritesIdempotentScripts() bool {
+	_, ok := d.Dialect.(IdempotentScripter)
+	return ok
+}
+
+// IdempotentScript returns the script of a result with every statement
+// guarded, and false when the dialect cannot guard its statements.
+func (d *Database) IdempotentScript(result models.DiffResult) (string, bool) {
+	scripter, ok := d.Dialect.(IdempotentScripter)
+	if !ok {
+		return "", false
+	}
+	return scripter.IdempotentScript(result), true
+}
+
+// DriftGuard returns the drift guard batch of a script, empty when the
+// dialect cannot write one and dbgo apply is left to check the objects.
+func (d *Database) DriftGuard(existing, absent []models.SchemaObject) string {
+	guarder, ok := d.Dialect.(DriftGuarder)
+	if !ok {
+		return ""
+	}
+	return guarder.DriftGuard(existing, absent)
+}
+
+// IsDriftGuard reports whether a batch is the drift guard of a script.
+func IsDriftGuard(batch string) bool {
+	return strings.HasPrefix(strings.TrimSpace(batch), DriftGuardHeader)
+}
+
+// RebuildStatement returns the statements that change a table of the
+// database from one definition to another keeping its rows, and false when
+// the dialect drops and recreates changed tables instead.
+func (d *Database) RebuildStatement(obj models.SchemaObject, fromDefinition, toDefinition string) (TableRebuild, bool, error) {
+	rebuilder, ok := d.Dialect.(TableRebuilder)
+	if !ok {
+		return TableRebuild{}, false, nil
+	}
+	rebuild, err := rebuilder.RebuildStatement(d, obj, fromDefinition, toDefinition)
+	return rebuild, true, err
+}
+
+// RegisterDialect makes a dialect available under the engine name used in
+// the configuration file.
+func RegisterDialect(engine string, dialect Dialect) {
+	dialects[engine] = dialect
+}
+
+// DialectFor returns the dialect of an engine, SQL Server when no engine is
+// given.
+func DialectFor(engine string) (Dialect, error) {
+	if engine == "" {
+		engine = EngineSQLServer
+	}
+
+	dialect, exists := dialects[strings.ToLower(engine)]
+	if !exists {
+		var engines []string
+		for name := range dialects {
+			engines = append(engines, name)
+		}
+		sort.Strings(engines)
+		return nil, fmt.Errorf("unknown database engine %q, expected one of %s", engine, strings.Join(engines, ", "))
+	}
+	return dialect, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// sqlServerDateLayout keeps the millisecond precision of DATETIME values and
+// is read back by CONVERT style 126.
+const sqlServerDateLayout = "2006-01-02T15:04:05.000"
+
+// DataLossGuard returns a batch that stops the script if a table that is
+// about to be dropped contains data. Idempotent scripts count the rows from
+// the partitions, since a previous run may have dropped the table already.
+func (sqlServerDialect) DataLossGuard(obj models.SchemaObject, removed []string, idempotent bool) string {
+	name := quoteName(obj.Schema, obj.Name)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("-- Data loss guard: stop if %s contains data\n", name))
+	if idempotent {
+		sb.WriteString(fmt.Sprintf("IF EXISTS (SELECT 1 FROM sys.partitions WHERE object_id = OBJECT_ID(%s) AND index_id IN (0, 1) AND rows > 0)\n", unicodeLiteral(name)))
+	} else {
+		sb.WriteString(fmt.Sprintf("IF EXISTS (SELECT 1 FROM %s)\n", name))
+	}
+	sb.WriteString("BEGIN\n")
+	sb.WriteString(fmt.Sprintf("    RAISERROR(%s, 16, 1);\n", unicodeLiteral(strings.ReplaceAll(dataLossMessage(name, removed), "%", "%%"))))
+	sb.WriteString("    SET NOEXEC ON;\n")
+	sb.WriteString("END\n")
+	sb.WriteString("GO\n\n")
+
+	return sb.String()
+}
+
+// DriftGuard returns a batch that stops the script when a target object was
+// created, dropped or modified after the comparison. SET NOEXEC ON keeps the
+// following batches from running when the script is executed outside dbgo.
+func (sqlServerDialect) DriftGuard(existing, absent []models.SchemaObject) string {
+	if len(existing) == 0 && len(absent) == 0 {
+		return ""
+	}
+
+	raise := func(sb *strings.Builder, name string) {
+		sb.WriteString("BEGIN\n")
+		sb.WriteString(fmt.Sprintf("    RAISERROR(%s, 16, 1);\n", unicodeLiteral(strings.ReplaceAll(name+" changed in the target database since the comparison", "%", "%%"))))
+		sb.WriteString("    SET NOEXEC ON;\n")
+		sb.WriteString("END\n")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(DriftGuardHeader + "\n")
+	for _, obj := range existing {
+		name := quoteName(obj.Schema, obj.Name)
+		sb.WriteString(fmt.Sprintf("IF NOT EXISTS (SELECT 1 FROM sys.objects WHERE object_id = OBJECT_ID(%s) AND modify_date = CONVERT(DATETIME, '%s', 126))\n",
+			unicodeLiteral(name), obj.ModifyDate.Format(sqlServerDateLayout)))
+		raise(&sb, name)
+	}
+	for _, obj := range absent {
+		name := quoteName(obj.Schema, obj.Name)
+		sb.WriteString(fmt.Sprintf("IF OBJECT_ID(%s) IS NOT NULL\n", unicodeLiteral(name)))
+		raise(&sb, name)
+	}
+	sb.WriteString("GO\n\n")
+
+	return sb.String()
+}
+
+// dataLossMessage is the error raised by a data loss guard.
+func dataLossMessage(name string, removed []string) string {
+	message := name + " contains data and would be dropped"
+	if len(removed) > 0 {
+		message += fmt.Sprintf(", losing the columns %s", strings.Join(removed, ", "))
+	}
+	return message + ". Rerun the comparison with --allow-data-loss to generate it without this guard"
+}
+
+// unicodeLiteral returns value as a T-SQL Unicode string literal.
+func unicodeLiteral(value string) string {
+	return "N'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func endWithNewline(script string) string {
+	if script == "" || strings.HasSuffix(script, "\n") {
+		return script
+	}
+	return script + "\n"
+}
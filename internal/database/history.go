@@ -0,0 +1,134 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HistoryStatements are the statements of the deployment history tables. The
+// parameters of each statement are positional:
+//
+//   - InsertDeployment: run id, source server and database, target server and
+//     database, dbgo version, script name, script hash and outcome
+//   - InsertObject: run id, schema, name, type, action and risk
+//   - FinishDeployment: run id, outcome and error message
+//   - ListDeployments: the number of deployments
+//   - GetDeployment and GetObjects: run id
+//
+// Deployments are selected with the columns run id, source server and
+// database, target server and database, dbgo version, script name, script
+// hash, start and finish times, outcome, error message and applied by.
+type HistoryStatements struct {
+	// CreateTables creates the history tables when they do not exist
+	CreateTables string
+	// TablesExist returns whether the history tables exist
+	TablesExist      string
+	InsertDeployment string
+	InsertObject     string
+	FinishDeployment string
+	ListDeployments  string
+	GetDeployment    string
+	GetObjects       string
+}
+
+// HistoryRecorder is implemented by dialects that can record the applied
+// scripts in history tables of the target database.
+type HistoryRecorder interface {
+	HistoryStatements(schema, historyTable, objectsTable string) HistoryStatements
+}
+
+// RecordsHistory reports whether the deployment history can be recorded in
+// the database.
+func (d *Database) RecordsHistory() bool {
+	_, ok := d.Dialect.(HistoryRecorder)
+	return ok
+}
+
+// HistoryStatements returns the statements of the history tables in a schema,
+// and false when the dialect cannot record the history.
+func (d *Database) HistoryStatements(schema, historyTable, objectsTable string) (HistoryStatements, bool) {
+	recorder, ok := d.Dialect.(HistoryRecorder)
+	if !ok {
+		return HistoryStatements{}, false
+	}
+	return recorder.HistoryStatements(schema, historyTable, objectsTable), true
+}
+
+func (sqlServerDialect) HistoryStatements(schema, historyTable, objectsTable string) HistoryStatements {
+	history := quoteName(schema, historyTable)
+	objects := quoteName(schema, objectsTable)
+
+	deploymentColumns := `
+	CONVERT(NVARCHAR(36), run_id), source_server, source_database, target_server, target_database,
+	dbgo_version, script_name, script_hash, started_at, finished_at, outcome, ISNULL(error_message, ''), applied_by
+	`
+
+	return HistoryStatements{
+		CreateTables: fmt.Sprintf(`
+		IF OBJECT_ID(%[1]s, 'U') IS NULL
+		CREATE TABLE %[2]s (
+			run_id UNIQUEIDENTIFIER NOT NULL CONSTRAINT [PK_%[5]s] PRIMARY KEY,
+			source_server NVARCHAR(256) NOT NULL,
+			source_database NVARCHAR(128) NOT NULL,
+			target_server NVARCHAR(256) NOT NULL,
+			target_database NVARCHAR(128) NOT NULL,
+			dbgo_version NVARCHAR(50) NOT NULL,
+			script_name NVARCHAR(260) NOT NULL,
+			script_hash CHAR(64) NOT NULL,
+			started_at DATETIME2 NOT NULL,
+			finished_at DATETIME2 NULL,
+			outcome NVARCHAR(20) NOT NULL,
+			error_message NVARCHAR(MAX) NULL,
+			applied_by NVARCHAR(128) NOT NULL CONSTRAINT [DF_%[5]s_applied_by] DEFAULT SUSER_SNAME()
+		);
+
+		IF OBJECT_ID(%[3]s, 'U') IS NULL
+		CREATE TABLE %[4]s (
+			run_id UNIQUEIDENTIFIER NOT NULL CONSTRAINT [FK_%[6]s_run_id] REFERENCES %[2]s (run_id),
+			object_schema NVARCHAR(128) NOT NULL,
+			object_name NVARCHAR(128) NOT NULL,
+			object_type NVARCHAR(60) NOT NULL,
+			action NVARCHAR(20) NOT NULL,
+			risk NVARCHAR(20) NOT NULL
+		);
+		`, unicodeLiteral(history), history, unicodeLiteral(objects), objects,
+			strings.ReplaceAll(historyTable, "]", "]]"), strings.ReplaceAll(objectsTable, "]", "]]")),
+
+		TablesExist: fmt.Sprintf("SELECT CAST(CASE WHEN OBJECT_ID(%s, 'U') IS NULL THEN 0 ELSE 1 END AS BIT)", unicodeLiteral(history)),
+
+		InsertDeployment: fmt.Sprintf(`
+		INSERT INTO %s (run_id, source_server, source_database, target_server, target_database, dbgo_version, script_name, script_hash, started_at, outcome)
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8, SYSUTCDATETIME(), @p9)
+		`, history),
+
+		InsertObject: fmt.Sprintf(`
+		INSERT INTO %s (run_id, object_schema, object_name, object_type, action, risk)
+		VALUES (@p1, @p2, @p3, @p4, @p5, @p6)
+		`, objects),
+
+		FinishDeployment: fmt.Sprintf(`
+		UPDATE %s
+		SET finished_at = SYSUTCDATETIME(), outcome = @p2, error_message = NULLIF(@p3, '')
+		WHERE run_id = @p1
+		`, history),
+
+		ListDeployments: fmt.Sprintf(`
+		SELECT TOP (@p1) %s
+		FROM %s
+		ORDER BY started_at DESC
+		`, deploymentColumns, history),
+
+		GetDeployment: fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE run_id = @p1
+		`, deploymentColumns, history),
+
+		GetObjects: fmt.Sprintf(`
+		SELECT object_schema, object_name, object_type, action, risk
+		FROM %s
+		WHERE run_id = @p1
+		ORDER BY object_schema, object_name
+		`, objects),
+	}
+}
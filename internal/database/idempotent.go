@@ -0,0 +1,314 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/models"
+	"github.com/victorlunam/dbgo/internal/tsql"
+)
+
+// Statements of the generated table scripts that fail when they are run a
+// second time. The names are written by ObjectDefinition as [schema].[name].
+var (
+	scriptedTablePattern  = regexp.MustCompile(`^CREATE TABLE (\[[^\]]*\]\.\[[^\]]*\])`)
+	addDefaultPattern     = regexp.MustCompile(`(?s)^ALTER TABLE (\[[^\]]*\]\.\[[^\]]*\]) ADD (?:CONSTRAINT \[[^\]]*\] )?DEFAULT .* FOR \[([^\]]*)\];?\s*$`)
+	addForeignKeyPattern  = regexp.MustCompile(`(?s)^ALTER TABLE (\[[^\]]*\]\.\[[^\]]*\])\s+WITH (?:NO)?CHECK ADD\s+(?:CONSTRAINT \[([^\]]*)\] )?FOREIGN KEY\(\[([^\],]*)[^)]*\)\s*REFERENCES (\[[^\]]*\]\.\[[^\]]*\])`)
+	dropConstraintPattern = regexp.MustCompile(`^ALTER TABLE \[([^\]]*)\]\.\[[^\]]*\] DROP CONSTRAINT \[([^\]]*)\]`)
+	renamePattern         = regexp.MustCompile(`^EXEC sp_rename N'(\[(?:[^\]]|\]\])*\]\.\[((?:[^\]]|\]\])*)\])'[,;\s]`)
+	createIndexPattern    = regexp.MustCompile(`(?i)^CREATE (?:UNIQUE )?(?:(?:NON)?CLUSTERED )?(?:COLUMNSTORE )?INDEX \[([^\]]*)\] ON (\[[^\]]*\]\.\[[^\]]*\])`)
+	dropIndexPattern      = regexp.MustCompile(`(?i)^DROP INDEX \[([^\]]*)\] ON (\[[^\]]*\]\.\[[^\]]*\])`)
+)
+
+// IdempotentScript returns the script of a result with every statement
+// guarded by an existence check, so a partially applied script can be run
+// again. Statements that are already guarded or can be repeated, such as
+// module drops and CHECK CONSTRAINT, are left unchanged. A changed table is
+// only dropped while it keeps the shape it had at comparison time.
+func (dialect sqlServerDialect) IdempotentScript(result models.DiffResult) string {
+	script := result.DifferenceScript
+
+	// CREATE must be the first statement of a module batch, so new modules
+	// are dropped if a previous run created them
+	if result.Kind == models.DiffMissing && result.Object.Type != "USER_TABLE" {
+		dropStatement, _ := dialect.DropStatement(result.TargetObject, nil)
+		script = dropStatement + "\n" + script
+	}
+
+	// the statements that drop a changed table only run while it still has
+	// its old shape, so a rerun keeps the recreated table
+	oldShape := ""
+	if result.Kind == models.DiffChanged && result.Object.Type == "USER_TABLE" {
+		oldShape = oldShapeCondition(result)
+	}
+
+	var sb strings.Builder
+	isTableCreated := oldShape == ""
+	for _, batch := range tsql.SplitBatches(script) {
+		guarded := guardStatement(batch.SQL)
+		if !isTableCreated {
+			var drop, create string
+			drop, create, isTableCreated = cutScriptedTable(batch.SQL)
+			guarded = guardBlock(oldShape, guardStatement(drop))
+			if isTableCreated {
+				guarded = endWithNewline(guarded) + guardStatement(create)
+			}
+		}
+		sb.WriteString(endWithNewline(guarded))
+		sb.WriteString("GO\n")
+	}
+	return strings.TrimSuffix(sb.String(), "GO\n")
+}
+
+// cutScriptedTable splits a batch at its CREATE TABLE statement, reporting
+// whether it has one.
+func cutScriptedTable(batch string) (string, string, bool) {
+	lines := strings.Split(batch, "\n")
+	for i, line := range lines {
+		if scriptedTablePattern.MatchString(line) {
+			return strings.Join(lines[:i], "\n"), strings.Join(lines[i:], "\n"), true
+		}
+	}
+	return batch, "", false
+}
+
+// guardBlock runs the statements of a batch, after its leading comments, in
+// a block that only runs under condition.
+func guardBlock(condition, batch string) string {
+	lines := strings.Split(strings.TrimRight(batch, "\r\n"), "\n")
+
+	start := 0
+	for start < len(lines) && (strings.TrimSpace(lines[start]) == "" || strings.HasPrefix(strings.TrimSpace(lines[start]), "--")) {
+		start++
+	}
+	if start == len(lines) {
+		return batch
+	}
+
+	guarded := append([]string{}, lines[:start]...)
+	guarded = append(guarded, "IF "+condition, "BEGIN")
+	guarded = append(guarded, lines[start:]...)
+	guarded = append(guarded, "END")
+	return strings.Join(guarded, "\n") + "\n"
+}
+
+// scriptedColumn is a column line of a table scripted by ObjectDefinition,
+// such as [Name] [nvarchar](50) NOT NULL.
+type scriptedColumn struct {
+	Name string
+	Line string
+	// Type and Size are empty for computed columns
+	Type     string
+	Size     []string
+	Nullable bool
+}
+
+// scriptedColumns returns the columns of the first CREATE TABLE statement of
+// a script.
+func scriptedColumns(script string) []scriptedColumn {
+	var columns []scriptedColumn
+	isTable := false
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !isTable {
+			isTable = scriptedTablePattern.MatchString(line)
+			continue
+		}
+		if !strings.HasPrefix(line, "    ") {
+			break
+		}
+		if !strings.HasPrefix(line, "    [") {
+			continue
+		}
+
+		line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+		column := scriptedColumn{Line: line, Nullable: !strings.HasSuffix(line, " NOT NULL")}
+
+		var significant []tsql.Token
+		for _, token := range tsql.Tokenize(line) {
+			if token.Kind != tsql.Whitespace {
+				significant = append(significant, token)
+			}
+		}
+		column.Name = tsql.UnquoteIdentifier(significant[0].Text)
+		if len(significant) > 1 && significant[1].Kind == tsql.QuotedIdentifier {
+			column.Type = tsql.UnquoteIdentifier(significant[1].Text)
+			if len(significant) > 2 && significant[2].Text == "(" {
+				for _, token := range significant[3:] {
+					if token.Text == ")" {
+						break
+					}
+					if token.Kind == tsql.Number || token.Kind == tsql.Word {
+						column.Size = append(column.Size, token.Text)
+					}
+				}
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// oldShapeCondition returns the condition under which a changed table still
+// has the shape of its target definition, recognized by a column that the
+// change removes, adds or alters. It is empty when no column reveals the
+// change, as when only a constraint changes.
+func oldShapeCondition(result models.DiffResult) string {
+	name := unicodeLiteral(quoteName(result.TargetObject.Schema, result.TargetObject.Name))
+	columnExists := func(column string, conditions ...string) string {
+		where := append([]string{fmt.Sprintf("object_id = OBJECT_ID(%s)", name), "name = " + unicodeLiteral(column)}, conditions...)
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM sys.columns WHERE %s)", strings.Join(where, " AND "))
+	}
+
+	targetColumns := scriptedColumns(result.TargetDefinition)
+	sourceColumns := scriptedColumns(result.DifferenceScript)
+	findColumn := func(columns []scriptedColumn, name string) (scriptedColumn, bool) {
+		for _, column := range columns {
+			if strings.EqualFold(column.Name, name) {
+				return column, true
+			}
+		}
+		return scriptedColumn{}, false
+	}
+
+	for _, column := range targetColumns {
+		if _, ok := findColumn(sourceColumns, column.Name); !ok {
+			return columnExists(column.Name)
+		}
+	}
+	for _, column := range sourceColumns {
+		if _, ok := findColumn(targetColumns, column.Name); !ok {
+			return fmt.Sprintf("OBJECT_ID(%s, 'U') IS NOT NULL AND NOT %s", name, columnExists(column.Name))
+		}
+	}
+
+	for _, column := range targetColumns {
+		source, _ := findColumn(sourceColumns, column.Name)
+		if source.Line == column.Line || column.Type == "" || source.Type == "" {
+			continue
+		}
+
+		var conditions []string
+		if !strings.EqualFold(source.Type, column.Type) {
+			conditions = append(conditions, "TYPE_NAME(user_type_id) = "+unicodeLiteral(column.Type))
+		} else {
+			properties := []string{"Precision", "Scale"}
+			for i := 0; i < len(column.Size) && i < len(properties); i++ {
+				if i < len(source.Size) && strings.EqualFold(source.Size[i], column.Size[i]) {
+					continue
+				}
+				size := column.Size[i]
+				if strings.EqualFold(size, "MAX") {
+					size = "-1"
+				}
+				conditions = append(conditions, fmt.Sprintf("COLUMNPROPERTY(object_id, name, '%s') = %s", properties[i], size))
+			}
+		}
+		if source.Nullable != column.Nullable {
+			isNullable := 0
+			if column.Nullable {
+				isNullable = 1
+			}
+			conditions = append(conditions, fmt.Sprintf("is_nullable = %d", isNullable))
+		}
+
+		if len(conditions) > 0 {
+			return columnExists(column.Name, conditions...)
+		}
+	}
+
+	return ""
+}
+
+// guardStatement adds an existence check before the statements of a batch,
+// after its leading comments. The foreign keys of a table are scripted
+// without separators between them, so batches of ALTER TABLE statements are
+// guarded statement by statement. Other batches hold a single statement or a
+// module, whose body is never changed.
+func guardStatement(batch string) string {
+	lines := strings.Split(strings.TrimLeft(batch, "\r\n"), "\n")
+
+	start := 0
+	for start < len(lines) && (strings.TrimSpace(lines[start]) == "" || strings.HasPrefix(strings.TrimSpace(lines[start]), "--")) {
+		start++
+	}
+	if start == len(lines) {
+		return batch
+	}
+
+	isTableBatch := strings.HasPrefix(lines[start], "ALTER TABLE ")
+
+	guarded := append([]string{}, lines[:start]...)
+	statementStart := start
+	for i := start + 1; i <= len(lines); i++ {
+		if i < len(lines) && !(isTableBatch && strings.HasPrefix(lines[i], "ALTER TABLE ")) {
+			continue
+		}
+		if condition := statementGuard(strings.Join(lines[statementStart:i], "\n")); condition != "" {
+			guarded = append(guarded, "IF "+condition)
+		}
+		guarded = append(guarded, lines[statementStart:i]...)
+		statementStart = i
+	}
+
+	return strings.Join(guarded, "\n")
+}
+
+// statementGuard returns the condition under which a statement still has to
+// run, or an empty string if it can be repeated as is.
+func statementGuard(statement string) string {
+	statement = strings.TrimSpace(statement)
+
+	if m := scriptedTablePattern.FindStringSubmatch(statement); m != nil {
+		return fmt.Sprintf("OBJECT_ID(%s, 'U') IS NULL", unicodeLiteral(m[1]))
+	}
+
+	if m := addDefaultPattern.FindStringSubmatch(statement); m != nil {
+		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM sys.columns WHERE object_id = OBJECT_ID(%s) AND name = %s AND default_object_id <> 0)",
+			unicodeLiteral(m[1]), unicodeLiteral(m[2]))
+	}
+
+	if m := addForeignKeyPattern.FindStringSubmatch(statement); m != nil {
+		if m[2] != "" {
+			return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM sys.foreign_keys WHERE parent_object_id = OBJECT_ID(%s) AND name = %s)",
+				unicodeLiteral(m[1]), unicodeLiteral(m[2]))
+		}
+		// unnamed keys are recognized by their first column and referenced table
+		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM sys.foreign_keys fk JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id "+
+			"WHERE fk.parent_object_id = OBJECT_ID(%s) AND fk.referenced_object_id = OBJECT_ID(%s) AND fkc.constraint_column_id = 1 "+
+			"AND COL_NAME(fkc.parent_object_id, fkc.parent_column_id) = %s)",
+			unicodeLiteral(m[1]), unicodeLiteral(m[4]), unicodeLiteral(m[3]))
+	}
+
+	if m := dropConstraintPattern.FindStringSubmatch(statement); m != nil {
+		return fmt.Sprintf("OBJECT_ID(%s) IS NOT NULL", unicodeLiteral(quoteName(m[1], m[2])))
+	}
+
+	if m := createIndexPattern.FindStringSubmatch(statement); m != nil {
+		return "NOT " + indexExists(m[2], m[1])
+	}
+
+	if m := dropIndexPattern.FindStringSubmatch(statement); m != nil {
+		return indexExists(m[2], m[1])
+	}
+
+	if m := renamePattern.FindStringSubmatch(statement); m != nil {
+		// the old and new names are equal in case-insensitive databases, so
+		// the exact name is checked. The name is read from a literal of the
+		// quoted name, whose quotes and brackets are doubled.
+		oldName := strings.ReplaceAll(m[1], "''", "'")
+		name := strings.ReplaceAll(strings.ReplaceAll(m[2], "''", "'"), "]]", "]")
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM sys.objects WHERE object_id = OBJECT_ID(%s) AND name COLLATE Latin1_General_BIN2 = %s)",
+			unicodeLiteral(oldName), unicodeLiteral(name))
+	}
+
+	return ""
+}
+
+// indexExists returns the condition that a table has an index of the given
+// name.
+func indexExists(table, name string) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = OBJECT_ID(%s) AND name = %s)",
+		unicodeLiteral(table), unicodeLiteral(name))
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+func TestGuardStatement(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch string
+		want  string
+	}{
+		{
+			name:  "table",
+			batch: "-- Table\nCREATE TABLE [dbo].[orders](\n    [id] [int] NOT NULL\n)\n",
+			want:  "-- Table\nIF OBJECT_ID(N'[dbo].[orders]', 'U') IS NULL\nCREATE TABLE [dbo].[orders](\n    [id] [int] NOT NULL\n)\n",
+		},
+		{
+			name:  "default",
+			batch: "ALTER TABLE [dbo].[orders] ADD CONSTRAINT [DF_orders_total] DEFAULT ((0)) FOR [total]\n",
+			want: "IF NOT EXISTS (SELECT 1 FROM sys.columns WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'total' AND default_object_id <> 0)\n" +
+				"ALTER TABLE [dbo].[orders] ADD CONSTRAINT [DF_orders_total] DEFAULT ((0)) FOR [total]\n",
+		},
+		{
+			name:  "drop constraint",
+			batch: "ALTER TABLE [dbo].[orders] DROP CONSTRAINT [FK_orders_customers]",
+			want:  "IF OBJECT_ID(N'[dbo].[FK_orders_customers]') IS NOT NULL\nALTER TABLE [dbo].[orders] DROP CONSTRAINT [FK_orders_customers]",
+		},
+		{
+			name:  "rename",
+			batch: "EXEC sp_rename N'[dbo].[Orders]', N'orders'",
+			want: "IF EXISTS (SELECT 1 FROM sys.objects WHERE object_id = OBJECT_ID(N'[dbo].[Orders]') AND name COLLATE Latin1_General_BIN2 = N'Orders')\n" +
+				"EXEC sp_rename N'[dbo].[Orders]', N'orders'",
+		},
+		{
+			name:  "create index",
+			batch: "CREATE NONCLUSTERED INDEX [IX_orders_customer] ON [dbo].[orders]\n(\n    [customer_id] ASC\n)\n",
+			want: "IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'IX_orders_customer')\n" +
+				"CREATE NONCLUSTERED INDEX [IX_orders_customer] ON [dbo].[orders]\n(\n    [customer_id] ASC\n)\n",
+		},
+		{
+			name:  "create unique index",
+			batch: "CREATE UNIQUE INDEX [UX_o'rders] ON [dbo].[orders] ([code])",
+			want: "IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'UX_o''rders')\n" +
+				"CREATE UNIQUE INDEX [UX_o'rders] ON [dbo].[orders] ([code])",
+		},
+		{
+			name:  "drop index",
+			batch: "DROP INDEX [IX_orders_customer] ON [dbo].[orders]",
+			want: "IF EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'IX_orders_customer')\n" +
+				"DROP INDEX [IX_orders_customer] ON [dbo].[orders]",
+		},
+		{
+			name:  "drop index if exists",
+			batch: "DROP INDEX IF EXISTS [IX_orders_customer] ON [dbo].[orders]",
+			want:  "DROP INDEX IF EXISTS [IX_orders_customer] ON [dbo].[orders]",
+		},
+		{
+			name:  "rename with quotes and brackets",
+			batch: "EXEC sp_rename N'[dbo].[O''Brien]]s]', N'o''brien]s'",
+			want: "IF EXISTS (SELECT 1 FROM sys.objects WHERE object_id = OBJECT_ID(N'[dbo].[O''Brien]]s]') AND name COLLATE Latin1_General_BIN2 = N'O''Brien]s')\n" +
+				"EXEC sp_rename N'[dbo].[O''Brien]]s]', N'o''brien]s'",
+		},
+		{
+			name:  "module",
+			batch: "CREATE VIEW [dbo].[v] AS\nSELECT 1 AS one\n",
+			want:  "CREATE VIEW [dbo].[v] AS\nSELECT 1 AS one\n",
+		},
+		{
+			name:  "comments only",
+			batch: "-- nothing to run\n",
+			want:  "-- nothing to run\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := guardStatement(test.batch); got != test.want {
+			t.Errorf("%s: guardStatement =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGuardStatementForeignKeys(t *testing.T) {
+	batch := "ALTER TABLE [dbo].[orders]  WITH CHECK ADD  CONSTRAINT [FK_orders_customers] FOREIGN KEY([customer_id])\n" +
+		"REFERENCES [dbo].[customers] ([id])\n" +
+		"ALTER TABLE [dbo].[orders] CHECK CONSTRAINT [FK_orders_customers]\n" +
+		"ALTER TABLE [dbo].[orders]  WITH NOCHECK ADD FOREIGN KEY([region_id], [country_id])\n" +
+		"REFERENCES [geo].[regions] ([id], [country_id])\n"
+
+	lines := strings.Split(guardStatement(batch), "\n")
+	want := []string{
+		"IF NOT EXISTS (SELECT 1 FROM sys.foreign_keys WHERE parent_object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'FK_orders_customers')",
+		"ALTER TABLE [dbo].[orders]  WITH CHECK ADD  CONSTRAINT [FK_orders_customers] FOREIGN KEY([customer_id])",
+		"REFERENCES [dbo].[customers] ([id])",
+		"ALTER TABLE [dbo].[orders] CHECK CONSTRAINT [FK_orders_customers]",
+		"IF NOT EXISTS (SELECT 1 FROM sys.foreign_keys fk JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id " +
+			"WHERE fk.parent_object_id = OBJECT_ID(N'[dbo].[orders]') AND fk.referenced_object_id = OBJECT_ID(N'[geo].[regions]') " +
+			"AND fkc.constraint_column_id = 1 AND COL_NAME(fkc.parent_object_id, fkc.parent_column_id) = N'region_id')",
+		"ALTER TABLE [dbo].[orders]  WITH NOCHECK ADD FOREIGN KEY([region_id], [country_id])",
+		"REFERENCES [geo].[regions] ([id], [country_id])",
+		"",
+	}
+
+	if len(lines) != len(want) {
+		t.Fatalf("guardStatement returned %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d =\n%s\nwant\n%s", i+1, lines[i], want[i])
+		}
+	}
+}
+
+func changedTableResult(targetColumns, sourceColumns string) models.DiffResult {
+	obj := models.SchemaObject{Schema: "dbo", Name: "orders", Type: "USER_TABLE"}
+	dialect := sqlServerDialect{}
+
+	script := dialect.DataLossGuard(obj, nil, true) +
+		"ALTER TABLE [dbo].[orders] DROP CONSTRAINT [FK_orders_customers]\nGO\n" +
+		"\nIF OBJECT_ID('[dbo].[orders]', 'U') IS NOT NULL\nDROP TABLE [dbo].[orders]\n\n" +
+		"CREATE TABLE [dbo].[orders] (\n" + sourceColumns + "    CONSTRAINT [PK_orders] PRIMARY KEY CLUSTERED ([id])\n)\n"
+
+	return models.DiffResult{
+		Object:           obj,
+		TargetObject:     obj,
+		Kind:             models.DiffChanged,
+		DifferenceScript: script,
+		TargetDefinition: "CREATE TABLE [dbo].[orders] (\n" + targetColumns + "    CONSTRAINT [PK_orders] PRIMARY KEY CLUSTERED ([id])\n)\n",
+	}
+}
+
+func TestIdempotentScriptChangedTable(t *testing.T) {
+	result := changedTableResult(
+		"    [id] [int] NOT NULL,\n    [note] [nvarchar](50) NULL,\n",
+		"    [id] [int] NOT NULL,\n    [total] [decimal](18, 2) NOT NULL,\n",
+	)
+
+	oldShape := "IF EXISTS (SELECT 1 FROM sys.columns WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'note')"
+	script := sqlServerDialect{}.IdempotentScript(result)
+
+	// the data loss guard, the constraint drop and the table drop only run
+	// while the removed column exists, and the table is only created once
+	batches := strings.Split(script, "GO\n")
+	if len(batches) != 3 {
+		t.Fatalf("IdempotentScript returned %d batches:\n%s", len(batches), script)
+	}
+	for i, batch := range batches {
+		if !strings.Contains(batch, oldShape+"\nBEGIN\n") || !strings.Contains(batch, "\nEND\n") {
+			t.Errorf("batch %d is not guarded by the old shape of the table:\n%s", i+1, batch)
+		}
+	}
+	if !strings.Contains(batches[2], "END\nIF OBJECT_ID(N'[dbo].[orders]', 'U') IS NULL\nCREATE TABLE [dbo].[orders] (") {
+		t.Errorf("the table creation is not guarded:\n%s", batches[2])
+	}
+	if !strings.Contains(batches[1], "BEGIN\nIF OBJECT_ID(N'[dbo].[FK_orders_customers]') IS NOT NULL\nALTER TABLE") {
+		t.Errorf("the constraint drop lost its own guard:\n%s", batches[1])
+	}
+}
+
+func TestOldShapeCondition(t *testing.T) {
+	tests := []struct {
+		name                         string
+		targetColumns, sourceColumns string
+		want                         string
+	}{
+		{
+			name:          "added column",
+			targetColumns: "    [id] [int] NOT NULL,\n",
+			sourceColumns: "    [id] [int] NOT NULL,\n    [total] [money] NULL,\n",
+			want: "OBJECT_ID(N'[dbo].[orders]', 'U') IS NOT NULL AND NOT EXISTS (SELECT 1 FROM sys.columns " +
+				"WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'total')",
+		},
+		{
+			name:          "changed type",
+			targetColumns: "    [id] [int] NOT NULL,\n",
+			sourceColumns: "    [id] [bigint] NOT NULL,\n",
+			want:          "EXISTS (SELECT 1 FROM sys.columns WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'id' AND TYPE_NAME(user_type_id) = N'int')",
+		},
+		{
+			name:          "changed length and nullability",
+			targetColumns: "    [id] [int] NOT NULL,\n    [note] [nvarchar](MAX) COLLATE Latin1_General_CI_AS NULL,\n",
+			sourceColumns: "    [id] [int] NOT NULL,\n    [note] [nvarchar](50) COLLATE Latin1_General_CI_AS NOT NULL,\n",
+			want: "EXISTS (SELECT 1 FROM sys.columns WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'note' " +
+				"AND COLUMNPROPERTY(object_id, name, 'Precision') = -1 AND is_nullable = 1)",
+		},
+		{
+			name:          "changed scale",
+			targetColumns: "    [id] [int] NOT NULL,\n    [total] [decimal](18, 2) NULL,\n",
+			sourceColumns: "    [id] [int] NOT NULL,\n    [total] [decimal](18, 4) NULL,\n",
+			want: "EXISTS (SELECT 1 FROM sys.columns WHERE object_id = OBJECT_ID(N'[dbo].[orders]') AND name = N'total' " +
+				"AND COLUMNPROPERTY(object_id, name, 'Scale') = 2)",
+		},
+		{
+			name:          "constraint only",
+			targetColumns: "    [id] [int] NOT NULL,\n",
+			sourceColumns: "    [id] [int] NOT NULL,\n",
+			want:          "",
+		},
+	}
+
+	for _, test := range tests {
+		if got := oldShapeCondition(changedTableResult(test.targetColumns, test.sourceColumns)); got != test.want {
+			t.Errorf("%s: oldShapeCondition =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
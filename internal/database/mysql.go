@@ -0,0 +1,361 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+const (
+	EngineMySQL   = "mysql"
+	EngineMariaDB = "mariadb"
+)
+
+// mysqlSchema is the schema given to the objects of a MySQL database. A
+// MySQL schema is the database itself, so databases with different names
+// are compared as if they shared this one.
+const mysqlSchema = "default"
+
+func init() {
+	RegisterDialect(EngineMySQL, mysqlDialect{})
+	RegisterDialect(EngineMariaDB, mysqlDialect{})
+}
+
+// mysqlDialect reads the information_schema of MySQL and MariaDB and writes
+// statements ending with semicolons, without batch separators.
+type mysqlDialect struct{}
+
+// mysqlObjects lists the tables, views, routines and triggers of the current
+// database with the type descriptions of sys.objects. Tables and views have
+// no modification date: CREATE_TIME only changes when ALTER TABLE copies the
+// table, so it would hide most changes.
+const mysqlObjects = `
+SELECT name, object_type, modify_date
+FROM (
+	SELECT TABLE_NAME AS name,
+		CASE TABLE_TYPE WHEN 'VIEW' THEN 'VIEW' ELSE 'USER_TABLE' END AS object_type,
+		CAST(NULL AS DATETIME) AS modify_date
+	FROM information_schema.TABLES
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE IN ('BASE TABLE', 'SYSTEM VERSIONED', 'VIEW')
+
+	UNION ALL
+
+	SELECT ROUTINE_NAME,
+		CASE ROUTINE_TYPE WHEN 'PROCEDURE' THEN 'SQL_STORED_PROCEDURE' ELSE 'SQL_SCALAR_FUNCTION' END,
+		LAST_ALTERED
+	FROM information_schema.ROUTINES
+	WHERE ROUTINE_SCHEMA = DATABASE() AND ROUTINE_TYPE IN ('PROCEDURE', 'FUNCTION')
+
+	UNION ALL
+
+	SELECT TRIGGER_NAME, 'SQL_TRIGGER', CREATED
+	FROM information_schema.TRIGGERS
+	WHERE TRIGGER_SCHEMA = DATABASE()
+) o
+`
+
+func (mysqlDialect) Name() string {
+	return "MySQL"
+}
+
+func (mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+// DataSourceName allows several statements in a query, so a script runs as
+// a single batch.
+func (mysqlDialect) DataSourceName(config config.DatabaseConfig) string {
+	port := config.Port
+	if port == "" {
+		port = "3306"
+	}
+
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.User = config.User
+	mysqlConfig.Passwd = config.Password
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = net.JoinHostPort(config.Server, port)
+	mysqlConfig.DBName = config.Database
+	mysqlConfig.ParseTime = true
+	mysqlConfig.MultiStatements = true
+	return mysqlConfig.FormatDSN()
+}
+
+func (mysqlDialect) DatabaseName(config config.DatabaseConfig) string {
+	return config.Database
+}
+
+func (mysqlDialect) Inspect(d *Database) error {
+	var version string
+	if err := d.DB.QueryRow("SELECT VERSION(), @@collation_database").Scan(&version, &d.Collation); err != nil {
+		return err
+	}
+
+	// MariaDB versions read like 10.11.6-MariaDB-log
+	product := "MySQL"
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		product = "MariaDB"
+	}
+	d.Server = ServerInfo{
+		Product:        product,
+		ProductVersion: strings.SplitN(version, "-", 2)[0],
+	}
+	fmt.Sscanf(version, "%d", &d.Server.Major)
+	return nil
+}
+
+// IsCaseSensitive reports whether names are case sensitive under a
+// collation, which is the case for _bin and _cs collations.
+// RecordsModifyDate reports the routines and triggers, as mysqlObjects
+// leaves the dates of tables and views unset.
+func (mysqlDialect) RecordsModifyDate(objectType string) bool {
+	return objectType != "USER_TABLE" && objectType != "VIEW"
+}
+
+func (mysqlDialect) IsCaseSensitive(collation string) bool {
+	collation = strings.ToLower(collation)
+	return strings.HasSuffix(collation, "_bin") || strings.HasSuffix(collation, "_cs")
+}
+
+func (mysqlDialect) ObjectTypes() []string {
+	return []string{"TABLE", "VIEW", "FUNCTION", "PROCEDURE", "TRIGGER"}
+}
+
+func (mysqlDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (mysqlDialect) BatchSeparator() string {
+	return ""
+}
+
+func (mysqlDialect) Transaction() Transaction {
+	return Transaction{Begin: "START TRANSACTION", Commit: "COMMIT", Rollback: "ROLLBACK"}
+}
+
+func (mysqlDialect) ListObjects(d *Database, typeDescs []string) ([]models.SchemaObject, error) {
+	if len(typeDescs) == 0 {
+		return nil, nil
+	}
+
+	var sqlTypes []string
+	for _, typeDesc := range typeDescs {
+		sqlTypes = append(sqlTypes, "'"+typeDesc+"'")
+	}
+	query := mysqlObjects + fmt.Sprintf("WHERE object_type IN (%s)\nORDER BY object_type, name", strings.Join(sqlTypes, ", "))
+
+	rows, err := d.DB.QueryContext(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []models.SchemaObject
+	for rows.Next() {
+		obj := models.SchemaObject{Schema: mysqlSchema}
+		var modifyDate sql.NullTime
+		if err := rows.Scan(&obj.Name, &obj.Type, &modifyDate); err != nil {
+			return nil, err
+		}
+		obj.ModifyDate = modifyDate.Time
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+func (mysqlDialect) FindObject(d *Database, schema, name string) (models.SchemaObject, bool, error) {
+	obj := models.SchemaObject{Schema: schema, Name: name}
+	if schema != mysqlSchema {
+		return obj, false, nil
+	}
+
+	var modifyDate sql.NullTime
+	err := d.DB.QueryRow(mysqlObjects+"WHERE name = ?\nLIMIT 1", name).Scan(&obj.Name, &obj.Type, &modifyDate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return obj, false, nil
+	}
+	if err != nil {
+		return obj, false, err
+	}
+
+	obj.ModifyDate = modifyDate.Time
+	return obj, true, nil
+}
+
+// mysqlShowKeywords maps object types to the keyword of their SHOW CREATE
+// and DROP statements.
+var mysqlShowKeywords = map[string]string{
+	"USER_TABLE":           "TABLE",
+	"VIEW":                 "VIEW",
+	"SQL_STORED_PROCEDURE": "PROCEDURE",
+	"SQL_SCALAR_FUNCTION":  "FUNCTION",
+	"SQL_TRIGGER":          "TRIGGER",
+}
+
+var (
+	// autoIncrementPattern matches the table option holding the next value
+	// of the AUTO_INCREMENT column, not the column attribute
+	autoIncrementPattern = regexp.MustCompile(`(?i)\s+AUTO_INCREMENT\s*=\s*\d+`)
+	definerPattern       = regexp.MustCompile("(?i)\\s+DEFINER\\s*=\\s*(?:`(?:[^`]|``)*`|'(?:[^']|'')*'|[^\\s@]+)(?:@(?:`(?:[^`]|``)*`|'(?:[^']|'')*'|[^\\s]+))?")
+)
+
+// ObjectDefinition returns the statement of SHOW CREATE without the counter
+// of AUTO_INCREMENT columns and the DEFINER clause, which differ between
+// servers holding the same schema. Objects without a definer are created
+// with the user running the script as their definer. The bodies of routines
+// and triggers hold semicolons, so they are written between DELIMITER
+// commands as the mysql client expects them.
+func (dialect mysqlDialect) ObjectDefinition(d *Database, obj models.SchemaObject) (string, error) {
+	keyword, ok := mysqlShowKeywords[obj.Type]
+	if !ok {
+		return "", fmt.Errorf("unsupported object type %s", obj.Type)
+	}
+
+	rows, err := d.DB.QueryContext(context.Background(), fmt.Sprintf("SHOW CREATE %s %s", keyword, dialect.QuoteIdentifier(obj.Name)))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", sql.ErrNoRows
+	}
+
+	// the statement is in the Create Table, Create View, Create Procedure,
+	// Create Function or SQL Original Statement column
+	values := make([]sql.NullString, len(columns))
+	targets := make([]any, len(columns))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return "", err
+	}
+
+	for i, column := range columns {
+		if strings.HasPrefix(column, "Create ") || column == "SQL Original Statement" {
+			if !values[i].Valid {
+				return "", fmt.Errorf("the definition of %s is not visible to the user", obj.Name)
+			}
+			definition := autoIncrementPattern.ReplaceAllString(values[i].String, "")
+			definition = definerPattern.ReplaceAllString(definition, "")
+			if obj.Type == "USER_TABLE" || obj.Type == "VIEW" {
+				return definition + ";", nil
+			}
+			return "DELIMITER " + mysqlDelimiter + "\n" + definition + mysqlDelimiter + "\nDELIMITER ;", nil
+		}
+	}
+	return "", fmt.Errorf("no definition returned for %s", obj.Name)
+}
+
+// IsMySQL reports whether engine is MySQL or MariaDB, whose scripts end
+// routines and triggers with DELIMITER commands.
+func IsMySQL(engine string) bool {
+	return strings.EqualFold(engine, EngineMySQL) || strings.EqualFold(engine, EngineMariaDB)
+}
+
+// mysqlDelimiter ends the routines and triggers of a script.
+const mysqlDelimiter = "$$"
+
+// StripDelimiters removes the DELIMITER commands of a script written for the
+// mysql client and ends the statements they delimit with semicolons, as the
+// server reads them when it runs several statements at once. Command lines
+// are blanked so line numbers are kept, and scripts without DELIMITER
+// commands are returned unchanged.
+func StripDelimiters(script string) string {
+	delimiter := ";"
+	lines := strings.Split(script, "\n")
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) == 2 && strings.EqualFold(fields[0], "DELIMITER") {
+			delimiter = fields[1]
+			lines[i] = line[len(strings.TrimRight(line, "\r")):]
+			continue
+		}
+		content := strings.TrimRight(line, " \t\r")
+		if delimiter != ";" && strings.HasSuffix(content, delimiter) {
+			lines[i] = strings.TrimSuffix(content, delimiter) + ";" + line[len(content):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (dialect mysqlDialect) DropStatement(obj models.SchemaObject, d *Database) (string, error) {
+	keyword, ok := mysqlShowKeywords[obj.Type]
+	if !ok {
+		return fmt.Sprintf("-- Unknown object type: %s. It must be deleted manually.", obj.Type), nil
+	}
+	return fmt.Sprintf("DROP %s IF EXISTS %s;", keyword, dialect.QuoteIdentifier(obj.Name)), nil
+}
+
+func (dialect mysqlDialect) RenameStatement(obj models.SchemaObject, newName string) string {
+	return fmt.Sprintf("RENAME TABLE %s TO %s;\n", dialect.QuoteIdentifier(obj.Name), dialect.QuoteIdentifier(newName))
+}
+
+// RowCount reads the estimated number of rows of a table from the
+// information_schema, so large tables are not scanned. InnoDB estimates can
+// differ from the actual count by 40 to 50 percent.
+func (dialect mysqlDialect) RowCount(d *Database, obj models.SchemaObject) (int64, error) {
+	var rowCount int64
+
+	query := `
+	SELECT COALESCE(TABLE_ROWS, 0)
+	FROM information_schema.TABLES
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+	`
+
+	err := d.DB.QueryRow(query, obj.Name).Scan(&rowCount)
+	if err != nil {
+		return 0, err
+	}
+	return rowCount, nil
+}
+
+// mysqlStructure reads the structure of a table from the information_schema.
+// Tables referenced in other databases keep the name of their database as
+// schema.
+var mysqlStructure = structureQueries{
+	columns: `
+	SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE = 'YES',
+		CASE
+			WHEN CHARACTER_MAXIMUM_LENGTH IS NULL THEN 0
+			WHEN CHARACTER_MAXIMUM_LENGTH >= 4294967295 THEN -1
+			ELSE CHARACTER_MAXIMUM_LENGTH
+		END
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+	ORDER BY ORDINAL_POSITION
+	`,
+	keys: `
+	SELECT INDEX_NAME, INDEX_NAME = 'PRIMARY', NON_UNIQUE = 0, COALESCE(COLUMN_NAME, '')
+	FROM information_schema.STATISTICS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+	ORDER BY INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX
+	`,
+	foreignKeys: `
+	SELECT CONSTRAINT_NAME, COLUMN_NAME,
+		CASE WHEN REFERENCED_TABLE_SCHEMA = DATABASE() THEN '` + mysqlSchema + `' ELSE REFERENCED_TABLE_SCHEMA END,
+		REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME
+	FROM information_schema.KEY_COLUMN_USAGE
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL
+	ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION
+	`,
+}
+
+func (mysqlDialect) TableStructure(d *Database, obj models.SchemaObject) (models.TableStructure, error) {
+	return readTableStructure(d, mysqlStructure, obj.Name)
+}
@@ -0,0 +1,45 @@
+package database
+
+import "testing"
+
+func TestStripDelimiters(t *testing.T) {
+	script := "DROP PROCEDURE IF EXISTS `p`;\n" +
+		"DELIMITER $$\r\n" +
+		"CREATE PROCEDURE `p`()\n" +
+		"BEGIN\n" +
+		"    SELECT 1;\n" +
+		"END$$  \n" +
+		"DELIMITER ;\n" +
+		"CREATE TABLE `t` (`id` int);\n"
+
+	want := "DROP PROCEDURE IF EXISTS `p`;\n" +
+		"\r\n" +
+		"CREATE PROCEDURE `p`()\n" +
+		"BEGIN\n" +
+		"    SELECT 1;\n" +
+		"END;  \n" +
+		"\n" +
+		"CREATE TABLE `t` (`id` int);\n"
+
+	if got := StripDelimiters(script); got != want {
+		t.Errorf("StripDelimiters =\n%q\nwant\n%q", got, want)
+	}
+
+	plain := "CREATE TABLE [t] ([id] int)\nGO\n"
+	if got := StripDelimiters(plain); got != plain {
+		t.Errorf("StripDelimiters changed a script without DELIMITER commands: %q", got)
+	}
+}
+
+func TestProduct(t *testing.T) {
+	mariaDB := &Database{Dialect: mysqlDialect{}, Server: ServerInfo{Product: "MariaDB"}}
+	if got := mariaDB.Product(); got != "MariaDB" {
+		t.Errorf("Product of a MariaDB server = %q, want MariaDB", got)
+	}
+
+	// SQL Server leaves the product of its ServerInfo empty
+	sqlServer := &Database{Dialect: sqlServerDialect{}}
+	if got := sqlServer.Product(); got != "SQL Server" {
+		t.Errorf("Product of a SQL Server database = %q, want SQL Server", got)
+	}
+}
@@ -0,0 +1,726 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+const EnginePostgres = "postgres"
+
+func init() {
+	RegisterDialect(EnginePostgres, postgresDialect{})
+}
+
+// postgresDialect reads the pg_catalog of PostgreSQL and writes statements
+// ending with semicolons, without batch separators.
+type postgresDialect struct{}
+
+// postgresObjects lists the user objects of a database with the type
+// descriptions of sys.objects. Objects of extensions, identity sequences and
+// the indexes of constraints, which are created with their owner, are left
+// out. Functions and procedures are named with their identity arguments, as
+// overloads share a name, and triggers with their table.
+const postgresObjects = `
+SELECT schema_name, object_name, object_type
+FROM (
+	SELECT n.nspname AS schema_name, c.relname AS object_name,
+		CASE c.relkind
+			WHEN 'r' THEN 'USER_TABLE'
+			WHEN 'p' THEN 'USER_TABLE'
+			WHEN 'v' THEN 'VIEW'
+			WHEN 'm' THEN 'MATERIALIZED_VIEW'
+			WHEN 'S' THEN 'SEQUENCE_OBJECT'
+			ELSE 'INDEX'
+		END AS object_type
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'p', 'v', 'm', 'S', 'i', 'I')
+		AND NOT c.relispartition
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend d
+			WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype IN ('e', 'i')
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_constraint con
+			WHERE con.conindid = c.oid AND con.contype IN ('p', 'u', 'x')
+		)
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+
+	UNION ALL
+
+	SELECT n.nspname, p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+		CASE
+			WHEN p.prokind = 'p' THEN 'SQL_STORED_PROCEDURE'
+			WHEN p.proretset THEN 'SQL_TABLE_VALUED_FUNCTION'
+			ELSE 'SQL_SCALAR_FUNCTION'
+		END
+	FROM pg_proc p
+	JOIN pg_namespace n ON n.oid = p.pronamespace
+	WHERE p.prokind IN ('f', 'p')
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend d
+			WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e'
+		)
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+
+	UNION ALL
+
+	SELECT n.nspname, c.relname || '.' || t.tgname, 'SQL_TRIGGER'
+	FROM pg_trigger t
+	JOIN pg_class c ON c.oid = t.tgrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE NOT t.tgisinternal
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+
+	UNION ALL
+
+	SELECT n.nspname, t.typname, CASE t.typtype WHEN 'd' THEN 'DOMAIN' ELSE 'USER_DEFINED_TYPE' END
+	FROM pg_type t
+	JOIN pg_namespace n ON n.oid = t.typnamespace
+	LEFT JOIN pg_class c ON c.oid = t.typrelid
+	WHERE (t.typtype IN ('e', 'r', 'd') OR (t.typtype = 'c' AND c.relkind = 'c'))
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend d
+			WHERE d.classid = 'pg_type'::regclass AND d.objid = t.oid AND d.deptype = 'e'
+		)
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'
+) o
+`
+
+func (postgresDialect) Name() string {
+	return "PostgreSQL"
+}
+
+func (postgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (postgresDialect) DataSourceName(config config.DatabaseConfig) string {
+	port := config.Port
+	if port == "" {
+		port = "5432"
+	}
+	return fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=disable",
+		connectionValue(config.Server), connectionValue(port), connectionValue(config.Database),
+		connectionValue(config.User), connectionValue(config.Password))
+}
+
+func (postgresDialect) DatabaseName(config config.DatabaseConfig) string {
+	return config.Database
+}
+
+// connectionValue quotes a value of a key=value connection string.
+func connectionValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+func (postgresDialect) Inspect(d *Database) error {
+	var version string
+	var versionNum int
+	err := d.DB.QueryRow(`
+	SELECT current_setting('server_version'), current_setting('server_version_num')::int, datcollate
+	FROM pg_database
+	WHERE datname = current_database()
+	`).Scan(&version, &versionNum, &d.Collation)
+	if err != nil {
+		return err
+	}
+
+	// server_version may be followed by the distribution, as in 16.2 (Debian 16.2-1)
+	d.Server = ServerInfo{
+		Product:        "PostgreSQL",
+		ProductVersion: strings.Fields(version)[0],
+		Major:          versionNum / 10000,
+	}
+	return nil
+}
+
+// IsCaseSensitive reports true whatever the collation, since the catalog
+// keeps quoted identifiers with their exact case.
+func (postgresDialect) RecordsModifyDate(objectType string) bool {
+	return false
+}
+
+func (postgresDialect) IsCaseSensitive(collation string) bool {
+	return true
+}
+
+func (postgresDialect) ObjectTypes() []string {
+	return []string{"SEQUENCE", "TYPE", "TABLE", "VIEW", "FUNCTION", "PROCEDURE", "TRIGGER", "INDEX"}
+}
+
+func (postgresDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (postgresDialect) BatchSeparator() string {
+	return ""
+}
+
+func (postgresDialect) Transaction() Transaction {
+	return Transaction{Begin: "BEGIN", Commit: "COMMIT", Rollback: "ROLLBACK"}
+}
+
+func (postgresDialect) ListObjects(d *Database, typeDescs []string) ([]models.SchemaObject, error) {
+	if len(typeDescs) == 0 {
+		return nil, nil
+	}
+
+	var sqlTypes []string
+	for _, typeDesc := range typeDescs {
+		sqlTypes = append(sqlTypes, "'"+typeDesc+"'")
+	}
+	query := postgresObjects + fmt.Sprintf("WHERE object_type IN (%s)\nORDER BY object_type, object_name", strings.Join(sqlTypes, ", "))
+
+	rows, err := d.DB.QueryContext(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []models.SchemaObject
+	for rows.Next() {
+		var obj models.SchemaObject
+		if err := rows.Scan(&obj.Schema, &obj.Name, &obj.Type); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// FindObject looks up an object by the schema and name ListObjects gives it.
+// PostgreSQL does not record modification dates, so ModifyDate is left zero.
+func (postgresDialect) FindObject(d *Database, schema, name string) (models.SchemaObject, bool, error) {
+	obj := models.SchemaObject{Schema: schema, Name: name}
+
+	query := postgresObjects + "WHERE schema_name = $1 AND object_name = $2\nLIMIT 1"
+	err := d.DB.QueryRow(query, schema, name).Scan(&obj.Schema, &obj.Name, &obj.Type)
+	if errors.Is(err, sql.ErrNoRows) {
+		return obj, false, nil
+	}
+	if err != nil {
+		return obj, false, err
+	}
+
+	return obj, true, nil
+}
+
+func (dialect postgresDialect) ObjectDefinition(d *Database, obj models.SchemaObject) (string, error) {
+	ctx := context.Background()
+	name := dialect.qualifiedName(obj.Schema, obj.Name)
+
+	switch obj.Type {
+	case "USER_TABLE":
+		oid, err := relationOID(ctx, d, obj.Schema, obj.Name)
+		if err != nil {
+			return "", err
+		}
+		return dialect.tableDefinition(ctx, d, oid, name)
+
+	case "VIEW", "MATERIALIZED_VIEW":
+		oid, err := relationOID(ctx, d, obj.Schema, obj.Name)
+		if err != nil {
+			return "", err
+		}
+		var query string
+		if err := d.DB.QueryRowContext(ctx, "SELECT pg_get_viewdef($1, true)", oid).Scan(&query); err != nil {
+			return "", err
+		}
+		create := "CREATE OR REPLACE VIEW"
+		if obj.Type == "MATERIALIZED_VIEW" {
+			create = "CREATE MATERIALIZED VIEW"
+		}
+		return fmt.Sprintf("%s %s AS\n%s", create, name, query), nil
+
+	case "SQL_STORED_PROCEDURE", "SQL_SCALAR_FUNCTION", "SQL_TABLE_VALUED_FUNCTION":
+		var definition string
+		err := d.DB.QueryRowContext(ctx, `
+		SELECT pg_get_functiondef(p.oid)
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = $1 AND p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')' = $2
+		`, obj.Schema, obj.Name).Scan(&definition)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(definition, "\n") + ";", nil
+
+	case "SQL_TRIGGER":
+		table, trigger, _ := strings.Cut(obj.Name, ".")
+		var definition string
+		err := d.DB.QueryRowContext(ctx, `
+		SELECT pg_get_triggerdef(t.oid, true)
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2 AND t.tgname = $3
+		`, obj.Schema, table, trigger).Scan(&definition)
+		if err != nil {
+			return "", err
+		}
+		return definition + ";", nil
+
+	case "INDEX":
+		oid, err := relationOID(ctx, d, obj.Schema, obj.Name)
+		if err != nil {
+			return "", err
+		}
+		var definition string
+		if err := d.DB.QueryRowContext(ctx, "SELECT pg_get_indexdef($1)", oid).Scan(&definition); err != nil {
+			return "", err
+		}
+		return definition + ";", nil
+
+	case "SEQUENCE_OBJECT":
+		var dataType string
+		var start, increment, minValue, maxValue, cache int64
+		var cycle bool
+		err := d.DB.QueryRowContext(ctx, `
+		SELECT format_type(s.seqtypid, NULL), s.seqstart, s.seqincrement, s.seqmin, s.seqmax, s.seqcache, s.seqcycle
+		FROM pg_sequence s
+		JOIN pg_class c ON c.oid = s.seqrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2
+		`, obj.Schema, obj.Name).Scan(&dataType, &start, &increment, &minValue, &maxValue, &cache, &cycle)
+		if err != nil {
+			return "", err
+		}
+		cycleOption := "NO CYCLE"
+		if cycle {
+			cycleOption = "CYCLE"
+		}
+		return fmt.Sprintf("CREATE SEQUENCE %s\n    AS %s\n    INCREMENT BY %d\n    MINVALUE %d\n    MAXVALUE %d\n    START WITH %d\n    CACHE %d\n    %s;",
+			name, dataType, increment, minValue, maxValue, start, cache, cycleOption), nil
+
+	case "USER_DEFINED_TYPE", "DOMAIN":
+		return dialect.typeDefinition(ctx, d, obj, name)
+	}
+
+	return "", fmt.Errorf("unsupported object type %s", obj.Type)
+}
+
+// tableDefinition scripts a table with its columns, defaults, identity and
+// generated columns and its constraints. Foreign keys are added after the
+// table, and indexes and triggers are separate objects.
+func (dialect postgresDialect) tableDefinition(ctx context.Context, d *Database, oid int64, name string) (string, error) {
+	rows, err := d.DB.QueryContext(ctx, `
+	SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+		COALESCE(pg_get_expr(ad.adbin, ad.adrelid), ''), a.attidentity::text, a.attgenerated::text,
+		COALESCE(CASE WHEN a.attcollation <> t.typcollation THEN quote_ident(co.collname) END, '')
+	FROM pg_attribute a
+	JOIN pg_type t ON t.oid = a.atttypid
+	LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+	LEFT JOIN pg_collation co ON co.oid = a.attcollation
+	WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY a.attnum
+	`, oid)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var column, dataType, expression, identity, generated, collation string
+		var notNull bool
+		if err := rows.Scan(&column, &dataType, &notNull, &expression, &identity, &generated, &collation); err != nil {
+			return "", err
+		}
+
+		line := "    " + dialect.QuoteIdentifier(column) + " " + dataType
+		line += d.ScriptOptions.collateClause(collation)
+		switch {
+		case generated == "s":
+			line += " GENERATED ALWAYS AS (" + expression + ") STORED"
+		case identity == "a":
+			line += " GENERATED ALWAYS AS IDENTITY"
+		case identity == "d":
+			line += " GENERATED BY DEFAULT AS IDENTITY"
+		case expression != "":
+			line += " DEFAULT " + expression
+		}
+		if notNull && identity == "" {
+			line += " NOT NULL"
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	constraints, err := d.DB.QueryContext(ctx, `
+	SELECT conname, contype = 'f', pg_get_constraintdef(oid, true)
+	FROM pg_constraint
+	WHERE conrelid = $1 AND contype IN ('p', 'u', 'c', 'x', 'f')
+	ORDER BY contype = 'f', conname
+	`, oid)
+	if err != nil {
+		return "", err
+	}
+	defer constraints.Close()
+
+	var foreignKeys []string
+	for constraints.Next() {
+		var constraintName, definition string
+		var isForeignKey bool
+		if err := constraints.Scan(&constraintName, &isForeignKey, &definition); err != nil {
+			return "", err
+		}
+		constraint := "CONSTRAINT " + dialect.QuoteIdentifier(constraintName) + " " + definition
+		if isForeignKey {
+			foreignKeys = append(foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD %s;", name, constraint))
+			continue
+		}
+		lines = append(lines, "    "+constraint)
+	}
+	if err := constraints.Err(); err != nil {
+		return "", err
+	}
+
+	var partitionKey string
+	if err := d.DB.QueryRowContext(ctx, "SELECT COALESCE(pg_get_partkeydef($1), '')", oid).Scan(&partitionKey); err != nil {
+		return "", err
+	}
+
+	definition := fmt.Sprintf("CREATE TABLE %s (\n%s\n)", name, strings.Join(lines, ",\n"))
+	if partitionKey != "" {
+		definition += " PARTITION BY " + partitionKey
+	}
+	definition += ";"
+	for _, foreignKey := range foreignKeys {
+		definition += "\n" + foreignKey
+	}
+	return definition, nil
+}
+
+// typeDefinition scripts an enum, composite or range type, or a domain with
+// its check constraints.
+func (dialect postgresDialect) typeDefinition(ctx context.Context, d *Database, obj models.SchemaObject, name string) (string, error) {
+	var oid, relationID int64
+	var typeKind, baseType, defaultValue, collation string
+	var notNull bool
+	err := d.DB.QueryRowContext(ctx, `
+	SELECT t.oid, t.typtype::text, t.typrelid,
+		CASE WHEN t.typbasetype <> 0 THEN format_type(t.typbasetype, t.typtypmod) ELSE '' END,
+		COALESCE(t.typdefault, ''), t.typnotnull,
+		COALESCE(CASE WHEN t.typcollation <> bt.typcollation THEN quote_ident(co.collname) END, '')
+	FROM pg_type t
+	JOIN pg_namespace n ON n.oid = t.typnamespace
+	LEFT JOIN pg_type bt ON bt.oid = t.typbasetype
+	LEFT JOIN pg_collation co ON co.oid = t.typcollation
+	WHERE n.nspname = $1 AND t.typname = $2
+	`, obj.Schema, obj.Name).Scan(&oid, &typeKind, &relationID, &baseType, &defaultValue, &notNull, &collation)
+	if err != nil {
+		return "", err
+	}
+
+	switch typeKind {
+	case "e":
+		var labels string
+		err := d.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(string_agg(quote_literal(enumlabel), ', ' ORDER BY enumsortorder), '')
+		FROM pg_enum
+		WHERE enumtypid = $1
+		`, oid).Scan(&labels)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", name, labels), nil
+
+	case "r":
+		var subtype string
+		err := d.DB.QueryRowContext(ctx, "SELECT format_type(rngsubtype, NULL) FROM pg_range WHERE rngtypid = $1", oid).Scan(&subtype)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("CREATE TYPE %s AS RANGE (SUBTYPE = %s);", name, subtype), nil
+
+	case "c":
+		rows, err := d.DB.QueryContext(ctx, `
+		SELECT attname, format_type(atttypid, atttypmod)
+		FROM pg_attribute
+		WHERE attrelid = $1 AND attnum > 0 AND NOT attisdropped
+		ORDER BY attnum
+		`, relationID)
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+
+		var attributes []string
+		for rows.Next() {
+			var attribute, dataType string
+			if err := rows.Scan(&attribute, &dataType); err != nil {
+				return "", err
+			}
+			attributes = append(attributes, "    "+dialect.QuoteIdentifier(attribute)+" "+dataType)
+		}
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("CREATE TYPE %s AS (\n%s\n);", name, strings.Join(attributes, ",\n")), nil
+	}
+
+	definition := fmt.Sprintf("CREATE DOMAIN %s AS %s", name, baseType)
+	definition += d.ScriptOptions.collateClause(collation)
+	if defaultValue != "" {
+		definition += " DEFAULT " + defaultValue
+	}
+	if notNull {
+		definition += " NOT NULL"
+	}
+
+	rows, err := d.DB.QueryContext(ctx, `
+	SELECT conname, pg_get_constraintdef(oid, true)
+	FROM pg_constraint
+	WHERE contypid = $1 AND contype = 'c'
+	ORDER BY conname
+	`, oid)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var constraintName, check string
+		if err := rows.Scan(&constraintName, &check); err != nil {
+			return "", err
+		}
+		definition += "\n    CONSTRAINT " + dialect.QuoteIdentifier(constraintName) + " " + check
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return definition + ";", nil
+}
+
+// DropStatement uses DROP ... IF EXISTS, which every supported version has.
+// Tables are dropped without CASCADE, so objects that depend on them stop
+// the script instead of being dropped with them.
+func (dialect postgresDialect) DropStatement(obj models.SchemaObject, d *Database) (string, error) {
+	name := dialect.qualifiedName(obj.Schema, obj.Name)
+
+	switch obj.Type {
+	case "USER_TABLE":
+		return fmt.Sprintf("DROP TABLE IF EXISTS %s;", name), nil
+	case "VIEW":
+		return fmt.Sprintf("DROP VIEW IF EXISTS %s;", name), nil
+	case "MATERIALIZED_VIEW":
+		return fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s;", name), nil
+	case "SQL_STORED_PROCEDURE":
+		return fmt.Sprintf("DROP PROCEDURE IF EXISTS %s;", dialect.routineName(obj)), nil
+	case "SQL_SCALAR_FUNCTION", "SQL_TABLE_VALUED_FUNCTION":
+		return fmt.Sprintf("DROP FUNCTION IF EXISTS %s;", dialect.routineName(obj)), nil
+	case "SQL_TRIGGER":
+		table, trigger, _ := strings.Cut(obj.Name, ".")
+		return fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s;", dialect.QuoteIdentifier(trigger), dialect.qualifiedName(obj.Schema, table)), nil
+	case "SEQUENCE_OBJECT":
+		return fmt.Sprintf("DROP SEQUENCE IF EXISTS %s;", name), nil
+	case "USER_DEFINED_TYPE":
+		return fmt.Sprintf("DROP TYPE IF EXISTS %s;", name), nil
+	case "DOMAIN":
+		return fmt.Sprintf("DROP DOMAIN IF EXISTS %s;", name), nil
+	case "INDEX":
+		return fmt.Sprintf("DROP INDEX IF EXISTS %s;", name), nil
+	}
+
+	return fmt.Sprintf("-- Unknown object type: %s. It must be deleted manually.", obj.Type), nil
+}
+
+func (dialect postgresDialect) RenameStatement(obj models.SchemaObject, newName string) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME TO %s;\n", dialect.qualifiedName(obj.Schema, obj.Name), dialect.QuoteIdentifier(newName))
+}
+
+// RowCount reads the estimated number of rows of a table from pg_class, so
+// large tables are not scanned. Tables that were never vacuumed or analyzed
+// have no estimate, and their rows are counted.
+func (dialect postgresDialect) RowCount(d *Database, obj models.SchemaObject) (int64, error) {
+	var rowCount int64
+
+	query := `
+	SELECT c.reltuples::bigint
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname = $2
+	`
+
+	err := d.DB.QueryRow(query, obj.Schema, obj.Name).Scan(&rowCount)
+	if err != nil {
+		return 0, err
+	}
+	if rowCount >= 0 {
+		return rowCount, nil
+	}
+
+	err = d.DB.QueryRow("SELECT count(*) FROM " + dialect.qualifiedName(obj.Schema, obj.Name)).Scan(&rowCount)
+	if err != nil {
+		return 0, err
+	}
+	return rowCount, nil
+}
+
+// postgresStructure reads the structure of a table from pg_catalog, given
+// the oid of the table. Unique constraints are read as their indexes.
+var postgresStructure = structureQueries{
+	columns: `
+	SELECT a.attname, format_type(a.atttypid, NULL), NOT a.attnotnull,
+		CASE
+			WHEN a.atttypid IN ('varchar'::regtype, 'bpchar'::regtype) AND a.atttypmod > 0 THEN a.atttypmod - 4
+			WHEN a.atttypid IN ('varchar'::regtype, 'text'::regtype, 'bytea'::regtype) THEN -1
+			ELSE 0
+		END
+	FROM pg_attribute a
+	WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY a.attnum
+	`,
+	keys: `
+	SELECT ic.relname, i.indisprimary, i.indisunique, COALESCE(a.attname, '')
+	FROM pg_index i
+	JOIN pg_class ic ON ic.oid = i.indexrelid
+	CROSS JOIN LATERAL unnest(i.indkey[0:i.indnkeyatts - 1]) WITH ORDINALITY AS k(attnum, position)
+	LEFT JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+	WHERE i.indrelid = $1
+	ORDER BY ic.relname, k.position
+	`,
+	foreignKeys: `
+	SELECT c.conname, a.attname, rn.nspname, rc.relname, ra.attname
+	FROM pg_constraint c
+	CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, refattnum, position)
+	JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+	JOIN pg_class rc ON rc.oid = c.confrelid
+	JOIN pg_namespace rn ON rn.oid = rc.relnamespace
+	JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = k.refattnum
+	WHERE c.conrelid = $1 AND c.contype = 'f'
+	ORDER BY c.conname, k.position
+	`,
+}
+
+// postgresTypeNames maps the SQL standard type names of format_type to the
+// names usually written in scripts.
+var postgresTypeNames = map[string]string{
+	"character varying":           "varchar",
+	"character":                   "char",
+	"bit varying":                 "varbit",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"time with time zone":         "timetz",
+}
+
+func (postgresDialect) TableStructure(d *Database, obj models.SchemaObject) (models.TableStructure, error) {
+	oid, err := relationOID(context.Background(), d, obj.Schema, obj.Name)
+	if err != nil {
+		return models.TableStructure{}, err
+	}
+
+	structure, err := readTableStructure(d, postgresStructure, oid)
+	for i, column := range structure.Columns {
+		structure.Columns[i].Type = postgresTypeName(column.Type)
+	}
+	return structure, err
+}
+
+// postgresTypeName returns the script name of a type read with format_type.
+// Arrays keep the [] suffix after the element type.
+func postgresTypeName(formatted string) string {
+	elementType, isArray := strings.CutSuffix(formatted, "[]")
+	name, ok := postgresTypeNames[elementType]
+	if !ok {
+		return formatted
+	}
+	if isArray {
+		name += "[]"
+	}
+	return name
+}
+
+// TableDependents returns the indexes and triggers of a table, which DROP
+// TABLE removes. Indexes of constraints are created by the table itself.
+func (postgresDialect) TableDependents(d *Database, obj models.SchemaObject) ([]string, error) {
+	ctx := context.Background()
+	oid, err := relationOID(ctx, d, obj.Schema, obj.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.DB.QueryContext(ctx, `
+	SELECT pg_get_indexdef(i.indexrelid) || ';'
+	FROM pg_index i
+	WHERE i.indrelid = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_constraint con
+			WHERE con.conindid = i.indexrelid AND con.contype IN ('p', 'u', 'x')
+		)
+
+	UNION ALL
+
+	SELECT pg_get_triggerdef(t.oid, true) || ';'
+	FROM pg_trigger t
+	WHERE t.tgrelid = $1 AND NOT t.tgisinternal
+	`, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dependents []string
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, statement)
+	}
+	return dependents, rows.Err()
+}
+
+// DataLossGuard returns a block that raises an error when the table contains
+// rows, which rolls back a script applied in a transaction and stops one
+// that is not. Scripts for PostgreSQL are never idempotent, so the table is
+// always expected to exist.
+func (dialect postgresDialect) DataLossGuard(obj models.SchemaObject, removed []string, idempotent bool) string {
+	name := dialect.qualifiedName(obj.Schema, obj.Name)
+	message := strings.ReplaceAll(dataLossMessage(name, removed), "'", "''")
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("-- Data loss guard: stop if %s contains data\n", name))
+	sb.WriteString("DO $dbgo$\n")
+	sb.WriteString("BEGIN\n")
+	sb.WriteString(fmt.Sprintf("    IF EXISTS (SELECT 1 FROM %s) THEN\n", name))
+	sb.WriteString(fmt.Sprintf("        RAISE EXCEPTION USING MESSAGE = '%s';\n", message))
+	sb.WriteString("    END IF;\n")
+	sb.WriteString("END\n")
+	sb.WriteString("$dbgo$;\n")
+	return sb.String()
+}
+
+func (dialect postgresDialect) qualifiedName(schema, name string) string {
+	return dialect.QuoteIdentifier(schema) + "." + dialect.QuoteIdentifier(name)
+}
+
+// routineName quotes the name of a function or procedure and keeps its
+// identity arguments, which select the overload to drop.
+func (dialect postgresDialect) routineName(obj models.SchemaObject) string {
+	name, arguments, _ := strings.Cut(obj.Name, "(")
+	return dialect.qualifiedName(obj.Schema, name) + "(" + arguments
+}
+
+func relationOID(ctx context.Context, d *Database, schema, name string) (int64, error) {
+	var oid int64
+	err := d.DB.QueryRowContext(ctx, `
+	SELECT c.oid
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname = $2
+	`, schema, name).Scan(&oid)
+	return oid, err
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+func TestPostgresTypeName(t *testing.T) {
+	tests := map[string]string{
+		"character varying":           "varchar",
+		"character":                   "char",
+		"timestamp without time zone": "timestamp",
+		"timestamp with time zone":    "timestamptz",
+		"character varying[]":         "varchar[]",
+		"integer":                     "integer",
+		"double precision":            "double precision",
+	}
+
+	for formatted, want := range tests {
+		if got := postgresTypeName(formatted); got != want {
+			t.Errorf("postgresTypeName(%q) = %q, want %q", formatted, got, want)
+		}
+	}
+}
+
+// TestPostgresCatalogTypesInTypeMap checks the SQL Server types of the
+// default map against the type names format_type returns.
+func TestPostgresCatalogTypesInTypeMap(t *testing.T) {
+	typeMap := config.DefaultTypeMap("postgres")
+
+	tests := []struct {
+		sourceType, catalogType string
+	}{
+		{"datetime2", "timestamp without time zone"},
+		{"datetime", "timestamp without time zone"},
+		{"datetimeoffset", "timestamp with time zone"},
+		{"nvarchar", "character varying"},
+		{"nchar", "character"},
+		{"int", "integer"},
+		{"float", "double precision"},
+		{"bit", "boolean"},
+		{"uniqueidentifier", "uuid"},
+		{"varbinary", "bytea"},
+	}
+
+	for _, test := range tests {
+		targetType := postgresTypeName(test.catalogType)
+		found := false
+		for _, mapped := range typeMap[test.sourceType] {
+			if mapped == targetType {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s maps to %q, which does not hold %q read as %q", test.sourceType, typeMap[test.sourceType], test.catalogType, targetType)
+		}
+	}
+}
+
+func TestPostgresDropStatement(t *testing.T) {
+	tests := []struct {
+		obj  models.SchemaObject
+		want string
+	}{
+		{models.SchemaObject{Schema: "public", Name: "orders", Type: "USER_TABLE"}, `DROP TABLE IF EXISTS "public"."orders";`},
+		{models.SchemaObject{Schema: "sales", Name: `big "orders"`, Type: "VIEW"}, `DROP VIEW IF EXISTS "sales"."big ""orders""";`},
+		{models.SchemaObject{Schema: "public", Name: "totals", Type: "MATERIALIZED_VIEW"}, `DROP MATERIALIZED VIEW IF EXISTS "public"."totals";`},
+		{models.SchemaObject{Schema: "public", Name: "close_order(integer, text)", Type: "SQL_STORED_PROCEDURE"}, `DROP PROCEDURE IF EXISTS "public"."close_order"(integer, text);`},
+		{models.SchemaObject{Schema: "public", Name: "total()", Type: "SQL_SCALAR_FUNCTION"}, `DROP FUNCTION IF EXISTS "public"."total"();`},
+		{models.SchemaObject{Schema: "public", Name: "orders.audit", Type: "SQL_TRIGGER"}, `DROP TRIGGER IF EXISTS "audit" ON "public"."orders";`},
+		{models.SchemaObject{Schema: "public", Name: "order_ids", Type: "SEQUENCE_OBJECT"}, `DROP SEQUENCE IF EXISTS "public"."order_ids";`},
+		{models.SchemaObject{Schema: "public", Name: "money", Type: "DOMAIN"}, `DROP DOMAIN IF EXISTS "public"."money";`},
+		{models.SchemaObject{Schema: "public", Name: "ix_orders_code", Type: "INDEX"}, `DROP INDEX IF EXISTS "public"."ix_orders_code";`},
+	}
+
+	for _, test := range tests {
+		got, err := postgresDialect{}.DropStatement(test.obj, nil)
+		if err != nil || got != test.want {
+			t.Errorf("DropStatement(%s %s) = %q, %v, want %q", test.obj.Type, test.obj.Name, got, err, test.want)
+		}
+	}
+}
+
+func TestPostgresDataLossGuard(t *testing.T) {
+	table := models.SchemaObject{Schema: "public", Name: `O'Brien "orders"`, Type: "USER_TABLE"}
+
+	got := postgresDialect{}.DataLossGuard(table, []string{"note"}, false)
+	want := `-- Data loss guard: stop if "public"."O'Brien ""orders""" contains data
+DO $dbgo$
+BEGIN
+    IF EXISTS (SELECT 1 FROM "public"."O'Brien ""orders""") THEN
+        RAISE EXCEPTION USING MESSAGE = '"public"."O''Brien ""orders""" contains data and would be dropped, losing the columns note. Rerun the comparison with --allow-data-loss to generate it without this guard';
+    END IF;
+END
+$dbgo$;
+`
+	if got != want {
+		t.Errorf("DataLossGuard =\n%s\nwant\n%s", got, want)
+	}
+}
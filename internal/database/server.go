@@ -0,0 +1,127 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Engine editions of SERVERPROPERTY('EngineEdition') that run the latest
+// database engine whatever their product version says.
+const (
+	EngineEditionAzureSQLDatabase     = 5
+	EngineEditionAzureManagedInstance = 8
+)
+
+// productNames maps major product versions to SQL Server release names.
+var productNames = map[int]string{
+	11: "SQL Server 2012",
+	12: "SQL Server 2014",
+	13: "SQL Server 2016",
+	14: "SQL Server 2017",
+	15: "SQL Server 2019",
+	16: "SQL Server 2022",
+	17: "SQL Server 2025",
+}
+
+// ServerInfo describes the engine hosting a database, which decides the
+// syntax the generated scripts can use.
+type ServerInfo struct {
+	// Product is set for engines other than SQL Server, such as PostgreSQL
+	Product            string
+	ProductVersion     string
+	Major              int
+	Build              int
+	EngineEdition      int
+	CompatibilityLevel int
+}
+
+// queryServerInfo reads the product version, engine edition and compatibility
+// level of the current database.
+func queryServerInfo(db *sql.DB) (ServerInfo, error) {
+	var info ServerInfo
+
+	err := db.QueryRow(`
+	SELECT
+		CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128)),
+		CAST(SERVERPROPERTY('EngineEdition') AS INT),
+		(SELECT CAST(compatibility_level AS INT) FROM sys.databases WHERE name = DB_NAME())
+	`).Scan(&info.ProductVersion, &info.EngineEdition, &info.CompatibilityLevel)
+	if err != nil {
+		return info, err
+	}
+
+	// ProductVersion is major.minor.build.revision
+	parts := strings.Split(info.ProductVersion, ".")
+	info.Major, _ = strconv.Atoi(parts[0])
+	if len(parts) > 2 {
+		info.Build, _ = strconv.Atoi(parts[2])
+	}
+
+	return info, nil
+}
+
+// IsAzure reports whether the database runs on Azure SQL Database or Azure
+// SQL Managed Instance.
+func (s ServerInfo) IsAzure() bool {
+	return s.EngineEdition == EngineEditionAzureSQLDatabase || s.EngineEdition == EngineEditionAzureManagedInstance
+}
+
+// IsSQLServer reports whether the engine is SQL Server or Azure SQL.
+func (s ServerInfo) IsSQLServer() bool {
+	return s.Product == ""
+}
+
+// AtLeast reports whether the engine is the given SQL Server version or
+// later. Azure always runs the latest engine, other engines never match.
+func (s ServerInfo) AtLeast(major, build int) bool {
+	if !s.IsSQLServer() {
+		return false
+	}
+	if s.IsAzure() {
+		return true
+	}
+	return s.Major > major || (s.Major == major && s.Build >= build)
+}
+
+// ProductName returns the release name of the engine, such as SQL Server 2019.
+func (s ServerInfo) ProductName() string {
+	switch {
+	case s.Product != "":
+		return s.Product + " " + s.ProductVersion
+	case s.EngineEdition == EngineEditionAzureSQLDatabase:
+		return "Azure SQL Database"
+	case s.EngineEdition == EngineEditionAzureManagedInstance:
+		return "Azure SQL Managed Instance"
+	case productNames[s.Major] != "":
+		return productNames[s.Major]
+	}
+	return "SQL Server " + s.ProductVersion
+}
+
+func (s ServerInfo) String() string {
+	if !s.IsSQLServer() {
+		return s.ProductName()
+	}
+	return fmt.Sprintf("%s (%s), compatibility level %d", s.ProductName(), s.ProductVersion, s.CompatibilityLevel)
+}
+
+// SupportsDropIfExists reports whether DROP ... IF EXISTS is available,
+// starting with SQL Server 2016.
+func (s ServerInfo) SupportsDropIfExists() bool {
+	return s.AtLeast(13, 0)
+}
+
+// SupportsCreateOrAlter reports whether CREATE OR ALTER is available,
+// starting with SQL Server 2016 SP1.
+func (s ServerInfo) SupportsCreateOrAlter() bool {
+	return s.AtLeast(13, 4001)
+}
+
+// SupportsOptimizeForSequentialKey reports whether the
+// OPTIMIZE_FOR_SEQUENTIAL_KEY index option is available, starting with SQL
+// Server 2019.
+func (s ServerInfo) SupportsOptimizeForSequentialKey() bool {
+	return s.AtLeast(15, 0)
+}
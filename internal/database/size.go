@@ -0,0 +1,104 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// TableSizes holds the sizes of the user tables of a database by schema and
+// name.
+type TableSizes map[string]models.TableSize
+
+// Get returns the size of a table, or nil when it is unknown.
+func (s TableSizes) Get(obj models.SchemaObject) *models.TableSize {
+	size, exists := s[obj.Schema+"."+obj.Name]
+	if !exists {
+		return nil
+	}
+	return &size
+}
+
+// ReportsSizes reports whether the dialect of the database can read the
+// sizes of its tables.
+func (d *Database) ReportsSizes() bool {
+	_, ok := d.Dialect.(SizeReader)
+	return ok
+}
+
+// GetTableSizes returns the row count and the reserved and used space of
+// every user table.
+func (d *Database) GetTableSizes() (TableSizes, error) {
+	reader, ok := d.Dialect.(SizeReader)
+	if !ok {
+		return nil, fmt.Errorf("table sizes are not available on %s", d.Product())
+	}
+	return reader.TableSizes(d)
+}
+
+// GetDatabaseSize returns the space allocated to the files of the database,
+// with the totals of the table sizes.
+func (d *Database) GetDatabaseSize(tables TableSizes) (models.DatabaseSize, error) {
+	reader, ok := d.Dialect.(SizeReader)
+	if !ok {
+		return models.DatabaseSize{}, fmt.Errorf("database sizes are not available on %s", d.Product())
+	}
+	return reader.DatabaseSize(d, tables)
+}
+
+// TableSizes reads the sizes of the user tables from
+// sys.dm_db_partition_stats, which requires the VIEW DATABASE STATE
+// permission.
+func (sqlServerDialect) TableSizes(d *Database) (TableSizes, error) {
+	query := `
+	SELECT
+		OBJECT_SCHEMA_NAME(ps.object_id), OBJECT_NAME(ps.object_id),
+		SUM(CASE WHEN ps.index_id IN (0, 1) THEN ps.row_count ELSE 0 END),
+		SUM(ps.reserved_page_count) * 8,
+		SUM(ps.used_page_count) * 8
+	FROM sys.dm_db_partition_stats ps
+	JOIN sys.tables t ON t.object_id = ps.object_id
+	WHERE t.is_ms_shipped = 0
+	GROUP BY ps.object_id
+	`
+
+	rows, err := d.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(TableSizes)
+	for rows.Next() {
+		var schema, name string
+		var size models.TableSize
+		if err := rows.Scan(&schema, &name, &size.Rows, &size.ReservedKB, &size.UsedKB); err != nil {
+			return nil, err
+		}
+		sizes[schema+"."+name] = size
+	}
+
+	return sizes, rows.Err()
+}
+
+// DatabaseSize reads the space allocated to the data and log files.
+func (sqlServerDialect) DatabaseSize(d *Database, tables TableSizes) (models.DatabaseSize, error) {
+	query := `
+	SELECT
+		ISNULL(SUM(CASE WHEN type_desc = 'LOG' THEN 0 ELSE CAST(size AS bigint) END), 0) * 8,
+		ISNULL(SUM(CASE WHEN type_desc = 'LOG' THEN CAST(size AS bigint) ELSE 0 END), 0) * 8
+	FROM sys.database_files
+	`
+
+	var size models.DatabaseSize
+	if err := d.DB.QueryRow(query).Scan(&size.DataKB, &size.LogKB); err != nil {
+		return size, err
+	}
+
+	for _, table := range tables {
+		size.Tables.Rows += table.Rows
+		size.Tables.ReservedKB += table.ReservedKB
+		size.Tables.UsedKB += table.UsedKB
+	}
+	return size, nil
+}
@@ -0,0 +1,448 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+const EngineSQLite = "sqlite"
+
+// sqliteSchema is the schema of the objects of the main database file.
+const sqliteSchema = "main"
+
+func init() {
+	RegisterDialect(EngineSQLite, sqliteDialect{})
+}
+
+// sqliteDialect reads the sqlite_master table of a SQLite database file and
+// writes statements ending with semicolons, without batch separators.
+// Definitions are the statements SQLite keeps for each object.
+type sqliteDialect struct{}
+
+// sqliteObjects lists the objects of sqlite_master with the type
+// descriptions of sys.objects. Internal objects and the indexes SQLite
+// creates for constraints, which have no statement, are left out.
+const sqliteObjects = `
+SELECT name, object_type
+FROM (
+	SELECT name,
+		CASE type
+			WHEN 'table' THEN 'USER_TABLE'
+			WHEN 'view' THEN 'VIEW'
+			WHEN 'trigger' THEN 'SQL_TRIGGER'
+			ELSE 'INDEX'
+		END AS object_type
+	FROM sqlite_master
+	WHERE type IN ('table', 'view', 'trigger', 'index')
+		AND sql IS NOT NULL
+		AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+) o
+`
+
+func (sqliteDialect) Name() string {
+	return "SQLite"
+}
+
+func (sqliteDialect) DriverName() string {
+	return "sqlite3"
+}
+
+// DataSourceName opens the file named by the database of the configuration.
+// The file must exist, so a mistyped path is not created as an empty
+// database.
+func (sqliteDialect) DataSourceName(config config.DatabaseConfig) string {
+	return "file:" + config.Database + "?mode=rw"
+}
+
+// DatabaseName is the name of the database file without its directory and
+// extension.
+func (sqliteDialect) DatabaseName(config config.DatabaseConfig) string {
+	base := filepath.Base(config.Database)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func (sqliteDialect) Inspect(d *Database) error {
+	var version string
+	if err := d.DB.QueryRow("SELECT sqlite_version()").Scan(&version); err != nil {
+		return err
+	}
+
+	d.Collation = "BINARY"
+	d.Server = ServerInfo{
+		Product:        "SQLite",
+		ProductVersion: version,
+	}
+	fmt.Sscanf(version, "%d", &d.Server.Major)
+	return nil
+}
+
+// IsCaseSensitive reports false, since SQLite matches identifiers without
+// regard to case.
+func (sqliteDialect) RecordsModifyDate(objectType string) bool {
+	return false
+}
+
+func (sqliteDialect) IsCaseSensitive(collation string) bool {
+	return false
+}
+
+func (sqliteDialect) ObjectTypes() []string {
+	return []string{"TABLE", "VIEW", "TRIGGER", "INDEX"}
+}
+
+func (sqliteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) BatchSeparator() string {
+	return ""
+}
+
+func (sqliteDialect) Transaction() Transaction {
+	return Transaction{Begin: "BEGIN", Commit: "COMMIT", Rollback: "ROLLBACK"}
+}
+
+func (sqliteDialect) ListObjects(d *Database, typeDescs []string) ([]models.SchemaObject, error) {
+	if len(typeDescs) == 0 {
+		return nil, nil
+	}
+
+	var sqlTypes []string
+	for _, typeDesc := range typeDescs {
+		sqlTypes = append(sqlTypes, "'"+typeDesc+"'")
+	}
+	query := sqliteObjects + fmt.Sprintf("WHERE object_type IN (%s)\nORDER BY object_type, name", strings.Join(sqlTypes, ", "))
+
+	rows, err := d.DB.QueryContext(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []models.SchemaObject
+	for rows.Next() {
+		obj := models.SchemaObject{Schema: sqliteSchema}
+		if err := rows.Scan(&obj.Name, &obj.Type); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// FindObject looks up an object of the main database by name. SQLite does
+// not record modification dates, so ModifyDate is left zero.
+func (sqliteDialect) FindObject(d *Database, schema, name string) (models.SchemaObject, bool, error) {
+	obj := models.SchemaObject{Schema: schema, Name: name}
+	if !strings.EqualFold(schema, sqliteSchema) {
+		return obj, false, nil
+	}
+
+	query := sqliteObjects + "WHERE name = ? COLLATE NOCASE\nLIMIT 1"
+	err := d.DB.QueryRow(query, name).Scan(&obj.Name, &obj.Type)
+	if errors.Is(err, sql.ErrNoRows) {
+		return obj, false, nil
+	}
+	if err != nil {
+		return obj, false, err
+	}
+
+	return obj, true, nil
+}
+
+func (sqliteDialect) ObjectDefinition(d *Database, obj models.SchemaObject) (string, error) {
+	var definition string
+	err := d.DB.QueryRowContext(context.Background(), "SELECT sql FROM sqlite_master WHERE name = ? COLLATE NOCASE AND sql IS NOT NULL", obj.Name).Scan(&definition)
+	if err != nil {
+		return "", err
+	}
+	return definition + ";", nil
+}
+
+func (dialect sqliteDialect) DropStatement(obj models.SchemaObject, d *Database) (string, error) {
+	keyword, ok := sqliteKeywords[obj.Type]
+	if !ok {
+		return fmt.Sprintf("-- Unknown object type: %s. It must be deleted manually.", obj.Type), nil
+	}
+	return fmt.Sprintf("DROP %s IF EXISTS %s;", keyword, dialect.QuoteIdentifier(obj.Name)), nil
+}
+
+// sqliteKeywords maps object types to the keyword of their DROP statement.
+var sqliteKeywords = map[string]string{
+	"USER_TABLE":  "TABLE",
+	"VIEW":        "VIEW",
+	"SQL_TRIGGER": "TRIGGER",
+	"INDEX":       "INDEX",
+}
+
+// RenameStatement renames a table, through a temporary name when only the
+// case changes, which SQLite takes as a name that is already used.
+func (dialect sqliteDialect) RenameStatement(obj models.SchemaObject, newName string) string {
+	name := dialect.QuoteIdentifier(obj.Name)
+	if strings.EqualFold(obj.Name, newName) {
+		temporaryName := dialect.QuoteIdentifier("dbgo_rename_" + obj.Name)
+		return fmt.Sprintf("ALTER TABLE %s RENAME TO %s;\nALTER TABLE %s RENAME TO %s;\n", name, temporaryName, temporaryName, dialect.QuoteIdentifier(newName))
+	}
+	return fmt.Sprintf("ALTER TABLE %s RENAME TO %s;\n", name, dialect.QuoteIdentifier(newName))
+}
+
+// RowCount counts the rows of a table, which reads the whole table, as
+// SQLite keeps no row count statistics unless ANALYZE was run.
+func (dialect sqliteDialect) RowCount(d *Database, obj models.SchemaObject) (int64, error) {
+	var rowCount int64
+	err := d.DB.QueryRow("SELECT count(*) FROM " + dialect.QuoteIdentifier(obj.Name)).Scan(&rowCount)
+	if err != nil {
+		return 0, err
+	}
+	return rowCount, nil
+}
+
+// sqliteStructure reads the structure of a table from the pragma functions.
+// The primary key comes from the columns, since a rowid key has no index,
+// and primary key columns are reported as NOT NULL as in other engines.
+// Foreign keys have no names and are named by their position, and a
+// reference without columns is to the primary key of the referenced table.
+var sqliteStructure = structureQueries{
+	columns: `
+	SELECT name, type, "notnull" = 0 AND pk = 0, 0
+	FROM pragma_table_info(?1)
+	ORDER BY cid
+	`,
+	keys: `
+	SELECT index_name, is_primary_key, is_unique, column_name
+	FROM (
+		SELECT '' AS index_name, 1 AS is_primary_key, 1 AS is_unique, name AS column_name, pk AS position
+		FROM pragma_table_info(?1)
+		WHERE pk > 0
+
+		UNION ALL
+
+		SELECT il.name, 0, il."unique", COALESCE(ii.name, ''), ii.seqno
+		FROM pragma_index_list(?1) il
+		JOIN pragma_index_info(il.name) ii
+		WHERE il.origin <> 'pk'
+	)
+	ORDER BY is_primary_key DESC, index_name, position
+	`,
+	foreignKeys: `
+	SELECT CAST(fk.id AS TEXT), fk."from", '` + sqliteSchema + `', fk."table",
+		COALESCE(fk."to", (SELECT name FROM pragma_table_info(fk."table") WHERE pk = fk.seq + 1), '')
+	FROM pragma_foreign_key_list(?1) fk
+	ORDER BY fk.id, fk.seq
+	`,
+}
+
+// TableStructure reads the declared column types without their length, which
+// SQLite does not enforce, so no column reports a length.
+func (sqliteDialect) TableStructure(d *Database, obj models.SchemaObject) (models.TableStructure, error) {
+	structure, err := readTableStructure(d, sqliteStructure, obj.Name)
+	for i, column := range structure.Columns {
+		typeName, _, _ := strings.Cut(column.Type, "(")
+		structure.Columns[i].Type = strings.TrimSpace(typeName)
+	}
+	return structure, err
+}
+
+// RebuildStatement changes a table the way SQLite documents for the changes
+// ALTER TABLE cannot make: a table with the new definition is created, the
+// columns both definitions have are copied into it, the old table is dropped
+// and the new one takes the name of the new definition. The indexes and
+// triggers of the table, which are dropped with it, are created again from
+// their current statements. Foreign keys can only be switched off outside a
+// transaction, so the rebuild begins and commits its own, and rolls it back
+// when the copied rows break a foreign key. The legacy rename keeps views
+// that refer to the table from stopping it.
+func (dialect sqliteDialect) RebuildStatement(d *Database, obj models.SchemaObject, fromDefinition, toDefinition string) (TableRebuild, error) {
+	var rebuild TableRebuild
+
+	fromColumns, err := definitionColumns(fromDefinition)
+	if err != nil {
+		return rebuild, fmt.Errorf("error reading the columns of %s: %v", obj.Name, err)
+	}
+	toColumns, err := definitionColumns(toDefinition)
+	if err != nil {
+		return rebuild, fmt.Errorf("error reading the columns of %s: %v", obj.Name, err)
+	}
+
+	var copied []string
+	for _, column := range fromColumns {
+		kept := false
+		for _, toColumn := range toColumns {
+			kept = kept || strings.EqualFold(column, toColumn)
+		}
+		if kept {
+			copied = append(copied, dialect.QuoteIdentifier(column))
+		} else {
+			rebuild.DroppedColumns = append(rebuild.DroppedColumns, column)
+		}
+	}
+
+	dependents, err := tableDependents(d, obj.Name)
+	if err != nil {
+		return rebuild, err
+	}
+
+	name := dialect.QuoteIdentifier(obj.Name)
+	rebuildName := dialect.QuoteIdentifier("dbgo_rebuild_" + obj.Name)
+	newName := name
+	if match := createTablePattern.FindStringSubmatch(toDefinition); match != nil {
+		newName = dialect.QuoteIdentifier(unquoteName(match[2]))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(OwnTransactionMarker + "\n")
+	sb.WriteString(fmt.Sprintf("-- Rebuild %s, keeping the rows of the columns both versions have\n", name))
+	sb.WriteString("PRAGMA foreign_keys = OFF;\n")
+	sb.WriteString("PRAGMA legacy_alter_table = ON;\n")
+	sb.WriteString("BEGIN;\n")
+	sb.WriteString(strings.TrimRight(renameTable(toDefinition, rebuildName), ";\n") + ";\n")
+	if len(copied) > 0 {
+		columns := strings.Join(copied, ", ")
+		sb.WriteString(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s;\n", rebuildName, columns, columns, name))
+	}
+	sb.WriteString(fmt.Sprintf("DROP TABLE %s;\n", name))
+	sb.WriteString(fmt.Sprintf("ALTER TABLE %s RENAME TO %s;\n", rebuildName, newName))
+	for _, dependent := range dependents {
+		sb.WriteString(dependent + ";\n")
+	}
+	sb.WriteString(sqliteAbort("SELECT 1 FROM pragma_foreign_key_check", fmt.Sprintf("the rebuild of %s breaks a foreign key, its changes were rolled back", newName)))
+	sb.WriteString("COMMIT;\n")
+	sb.WriteString("PRAGMA legacy_alter_table = OFF;\n")
+	sb.WriteString("PRAGMA foreign_keys = ON;\n")
+	rebuild.Statement = sb.String()
+	return rebuild, nil
+}
+
+// DataLossGuard returns statements that stop the script when the removed
+// columns of a rebuilt table hold values, or when the table has rows if no
+// column is removed. Scripts for SQLite are never idempotent.
+func (dialect sqliteDialect) DataLossGuard(obj models.SchemaObject, removed []string, idempotent bool) string {
+	name := dialect.QuoteIdentifier(obj.Name)
+
+	if len(removed) == 0 {
+		return fmt.Sprintf("-- Data loss guard: stop if %s contains data\n", name) +
+			sqliteAbort("SELECT 1 FROM "+name, dataLossMessage(name, removed))
+	}
+
+	var conditions []string
+	for _, column := range removed {
+		conditions = append(conditions, dialect.QuoteIdentifier(column)+" IS NOT NULL")
+	}
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE %s", name, strings.Join(conditions, " OR "))
+	message := fmt.Sprintf("%s would lose the values of the columns %s. Rerun the comparison with --allow-data-loss to generate it without this guard",
+		name, strings.Join(removed, ", "))
+
+	return fmt.Sprintf("-- Data loss guard: stop if the removed columns of %s contain data\n", name) + sqliteAbort(query, message)
+}
+
+// sqliteAbort returns statements that stop the script with a message when a
+// query returns rows. SQLite only raises errors from triggers, so a row is
+// inserted into a temporary table whose trigger raises the error, which
+// also rolls back the open transaction.
+func sqliteAbort(query, message string) string {
+	var sb strings.Builder
+	sb.WriteString("DROP TABLE IF EXISTS temp.dbgo_guard;\n")
+	sb.WriteString("CREATE TEMP TABLE dbgo_guard (failed INTEGER);\n")
+	sb.WriteString(fmt.Sprintf("CREATE TEMP TRIGGER dbgo_guard_raise BEFORE INSERT ON dbgo_guard BEGIN SELECT RAISE(ROLLBACK, '%s'); END;\n",
+		strings.ReplaceAll(message, "'", "''")))
+	sb.WriteString(fmt.Sprintf("INSERT INTO dbgo_guard SELECT 1 WHERE EXISTS (%s);\n", query))
+	sb.WriteString("DROP TABLE temp.dbgo_guard;\n")
+	return sb.String()
+}
+
+// createTablePattern matches the name of the table created by a CREATE
+// TABLE statement, which can be quoted and qualified with its schema.
+var createTablePattern = regexp.MustCompile("(?is)^(\\s*CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?)" +
+	"(?:(?:\"(?:[^\"]|\"\")*\"|\\[[^\\]]*\\]|`[^`]*`|[\\w$]+)\\s*\\.\\s*)?" +
+	"(\"(?:[^\"]|\"\")*\"|\\[[^\\]]*\\]|`[^`]*`|[\\w$]+)")
+
+// unquoteName removes the double quotes, brackets or backticks SQLite
+// accepts around a name.
+func unquoteName(name string) string {
+	if len(name) < 2 {
+		return name
+	}
+	switch name[0] {
+	case '"':
+		return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+	case '[':
+		return name[1 : len(name)-1]
+	case '`':
+		return strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+	}
+	return name
+}
+
+// renameTable returns a CREATE TABLE statement creating a table with
+// another name.
+func renameTable(definition, quotedName string) string {
+	return createTablePattern.ReplaceAllString(definition, "${1}"+strings.ReplaceAll(quotedName, "$", "$$"))
+}
+
+// definitionColumns returns the stored columns of a CREATE TABLE statement
+// by creating the table in a private in-memory database, which SQLite
+// parses exactly as it would in the target.
+func definitionColumns(definition string) ([]string, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// every connection opens its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(renameTable(definition, `"dbgo_columns"`)); err != nil {
+		return nil, err
+	}
+
+	// generated columns are hidden and cannot be inserted into
+	rows, err := db.Query("SELECT name FROM pragma_table_xinfo('dbgo_columns') WHERE hidden = 0 ORDER BY cid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// tableDependents returns the statements of the indexes and triggers of a
+// table.
+func tableDependents(d *Database, table string) ([]string, error) {
+	rows, err := d.DB.Query(`
+	SELECT sql
+	FROM sqlite_master
+	WHERE type IN ('index', 'trigger') AND tbl_name = ? COLLATE NOCASE AND sql IS NOT NULL
+	ORDER BY type, name
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dependents []string
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, statement)
+	}
+	return dependents, rows.Err()
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// openSQLite connects to a new database file with the given statements run.
+func openSQLite(t *testing.T, statements string) *Database {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Connect(config.DatabaseConfig{Engine: EngineSQLite, Database: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if _, err := d.DB.Exec(statements); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// execScript runs a script on one connection, as dbgo apply does.
+func execScript(t *testing.T, d *Database, script string) error {
+	t.Helper()
+
+	conn, err := d.DB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), script)
+	return err
+}
+
+func queryStrings(t *testing.T, d *Database, query string) []string {
+	t.Helper()
+
+	rows, err := d.DB.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestRenameTable(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       string
+	}{
+		{"CREATE TABLE orders (id INTEGER)", `CREATE TABLE "x" (id INTEGER)`},
+		{`CREATE TABLE "order items"(id INTEGER)`, `CREATE TABLE "x"(id INTEGER)`},
+		{"create table if not exists [orders] (id)", `create table if not exists "x" (id)`},
+		{"CREATE TABLE main.`orders` (id)", `CREATE TABLE "x" (id)`},
+		{`CREATE TABLE "main" . "a""b" (id)`, `CREATE TABLE "x" (id)`},
+	}
+
+	for _, test := range tests {
+		if got := renameTable(test.definition, `"x"`); got != test.want {
+			t.Errorf("renameTable(%q) = %q, want %q", test.definition, got, test.want)
+		}
+	}
+}
+
+func TestRenameTableKeepsDollarSigns(t *testing.T) {
+	got := renameTable("CREATE TABLE t (id)", `"a$1"`)
+	if want := `CREATE TABLE "a$1" (id)`; got != want {
+		t.Errorf("renameTable = %q, want %q", got, want)
+	}
+}
+
+func TestDefinitionColumns(t *testing.T) {
+	definition := `CREATE TABLE "orders" (
+		id INTEGER PRIMARY KEY,
+		"customer name" TEXT NOT NULL,
+		total REAL,
+		total_with_tax REAL GENERATED ALWAYS AS (total * 1.21),
+		CONSTRAINT positive CHECK (total >= 0)
+	)`
+
+	columns, err := definitionColumns(definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "customer name", "total"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("definitionColumns = %q, want %q", columns, want)
+	}
+
+	if _, err := definitionColumns("CREATE TABLE broken ("); err == nil {
+		t.Error("definitionColumns of an invalid statement returned no error")
+	}
+}
+
+const rebuildSchema = `
+CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers (id), note TEXT, total REAL);
+CREATE INDEX orders_customer ON orders (customer_id);
+CREATE TABLE audit (order_id INTEGER);
+CREATE TRIGGER orders_audit AFTER INSERT ON orders BEGIN INSERT INTO audit VALUES (new.id); END;
+INSERT INTO customers VALUES (1, 'Ada');
+INSERT INTO orders VALUES (10, 1, 'first', 5.5);
+`
+
+func TestRebuildStatement(t *testing.T) {
+	d := openSQLite(t, rebuildSchema)
+	obj := models.SchemaObject{Schema: sqliteSchema, Name: "orders", Type: "USER_TABLE"}
+
+	from, err := d.GetObjectDefinition(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := "CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers (id), total REAL NOT NULL DEFAULT 0, status TEXT)"
+
+	rebuild, ok, err := d.RebuildStatement(obj, from, to)
+	if err != nil || !ok {
+		t.Fatalf("RebuildStatement = %v, %v", ok, err)
+	}
+	if want := []string{"note"}; !reflect.DeepEqual(rebuild.DroppedColumns, want) {
+		t.Errorf("DroppedColumns = %q, want %q", rebuild.DroppedColumns, want)
+	}
+	if !strings.HasPrefix(rebuild.Statement, OwnTransactionMarker+"\n") {
+		t.Errorf("the rebuild does not start with the own transaction marker:\n%s", rebuild.Statement)
+	}
+	if strings.Index(rebuild.Statement, "PRAGMA foreign_keys = OFF") > strings.Index(rebuild.Statement, "BEGIN;") {
+		t.Errorf("foreign keys are switched off inside the transaction:\n%s", rebuild.Statement)
+	}
+
+	if err := execScript(t, d, rebuild.Statement); err != nil {
+		t.Fatalf("error running the rebuild: %v\n%s", err, rebuild.Statement)
+	}
+
+	if got := queryStrings(t, d, "SELECT id || ',' || customer_id || ',' || total || ',' || COALESCE(status, 'null') FROM orders"); !reflect.DeepEqual(got, []string{"10,1,5.5,null"}) {
+		t.Errorf("rows after the rebuild = %q", got)
+	}
+	if got := queryStrings(t, d, "SELECT name FROM sqlite_master WHERE tbl_name = 'orders' AND type IN ('index', 'trigger') ORDER BY name"); !reflect.DeepEqual(got, []string{"orders_audit", "orders_customer"}) {
+		t.Errorf("indexes and triggers after the rebuild = %q", got)
+	}
+	if got := queryStrings(t, d, "SELECT name FROM sqlite_master WHERE name LIKE 'dbgo%'"); len(got) > 0 {
+		t.Errorf("rebuild tables left behind: %q", got)
+	}
+	if got := queryStrings(t, d, "PRAGMA foreign_keys"); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("foreign_keys after the rebuild = %q, want 1", got)
+	}
+}
+
+func TestRebuildStatementRollsBackBrokenForeignKeys(t *testing.T) {
+	d := openSQLite(t, rebuildSchema+"INSERT INTO orders VALUES (11, 2, 'orphan', 1);")
+	obj := models.SchemaObject{Schema: sqliteSchema, Name: "customers", Type: "USER_TABLE"}
+
+	from, err := d.GetObjectDefinition(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the orphan order of customer 2 already breaks its foreign key, which the
+	// check after the rebuild reports
+	rebuild, _, err := d.RebuildStatement(obj, from, "CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = execScript(t, d, rebuild.Statement)
+	if err == nil || !strings.Contains(err.Error(), "breaks a foreign key") {
+		t.Fatalf("error running the rebuild = %v, want the foreign key error", err)
+	}
+
+	definition := queryStrings(t, d, "SELECT sql FROM sqlite_master WHERE name = 'customers'")
+	if len(definition) != 1 || strings.Contains(definition[0], "NOT NULL") {
+		t.Errorf("the failed rebuild was not rolled back: %q", definition)
+	}
+	if got := queryStrings(t, d, "SELECT name FROM sqlite_master WHERE name LIKE 'dbgo%'"); len(got) > 0 {
+		t.Errorf("rebuild tables left behind: %q", got)
+	}
+}
+
+func TestSQLiteDataLossGuard(t *testing.T) {
+	d := openSQLite(t, rebuildSchema)
+	obj := models.SchemaObject{Schema: sqliteSchema, Name: "orders", Type: "USER_TABLE"}
+
+	guard, ok := d.DataLossGuard(obj, []string{"note"}, false)
+	if !ok {
+		t.Fatal("SQLite has no data loss guard")
+	}
+	err := execScript(t, d, guard)
+	if err == nil || !strings.Contains(err.Error(), "note") {
+		t.Errorf("guard with values in the removed column = %v, want an error naming the column", err)
+	}
+
+	if _, err := d.DB.Exec("UPDATE orders SET note = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := execScript(t, d, guard); err != nil {
+		t.Errorf("guard without values in the removed column = %v", err)
+	}
+}
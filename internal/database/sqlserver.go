@@ -0,0 +1,555 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+const EngineSQLServer = "sqlserver"
+
+func init() {
+	RegisterDialect(EngineSQLServer, sqlServerDialect{})
+}
+
+// sqlServerDialect reads the catalog views of SQL Server and Azure SQL and
+// writes T-SQL.
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Name() string {
+	return "SQL Server"
+}
+
+func (sqlServerDialect) DriverName() string {
+	return "sqlserver"
+}
+
+func (sqlServerDialect) DataSourceName(config config.DatabaseConfig) string {
+	return fmt.Sprintf("Server=%s,%s;Database=%s;User Id=%s;Password=%s;TrustServerCertificate=true",
+		config.Server, config.Port, config.Database, config.User, config.Password)
+}
+
+func (sqlServerDialect) DatabaseName(config config.DatabaseConfig) string {
+	return config.Database
+}
+
+func (sqlServerDialect) Inspect(d *Database) error {
+	err := d.DB.QueryRow("SELECT CONVERT(NVARCHAR(128), DATABASEPROPERTYEX(DB_NAME(), 'Collation'))").Scan(&d.Collation)
+	if err != nil {
+		return err
+	}
+
+	d.Server, err = queryServerInfo(d.DB)
+	return err
+}
+
+// IsCaseSensitive reports whether identifiers are case sensitive under a
+// collation, which is the case for _CS and binary collations.
+func (sqlServerDialect) RecordsModifyDate(objectType string) bool {
+	return true
+}
+
+func (sqlServerDialect) IsCaseSensitive(collation string) bool {
+	for _, part := range strings.Split(strings.ToUpper(collation), "_") {
+		if part == "CS" || part == "BIN" || part == "BIN2" {
+			return true
+		}
+	}
+	return false
+}
+
+func (sqlServerDialect) ObjectTypes() []string {
+	return []string{"TABLE", "VIEW", "PROCEDURE", "FUNCTION", "TRIGGER"}
+}
+
+func (sqlServerDialect) QuoteIdentifier(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+func (sqlServerDialect) BatchSeparator() string {
+	return "GO"
+}
+
+// Transaction aborts the transaction on any error, so a failed batch cannot
+// leave it open for the following ones.
+func (sqlServerDialect) Transaction() Transaction {
+	return Transaction{
+		Setup:    "SET XACT_ABORT ON",
+		Begin:    "BEGIN TRANSACTION",
+		Commit:   "COMMIT TRANSACTION",
+		Rollback: "IF @@TRANCOUNT > 0 ROLLBACK TRANSACTION",
+	}
+}
+
+func (sqlServerDialect) ListObjects(d *Database, typeDescs []string) ([]models.SchemaObject, error) {
+	var objects []models.SchemaObject
+	ctx := context.Background()
+
+	query := `
+	SELECT 
+		SCHEMA_NAME(o.schema_id) as schema_name,
+		o.name as object_name, 
+		o.type_desc as object_type,
+		o.modify_date
+	FROM 
+		sys.objects o
+	WHERE 
+		o.type_desc IN (%s)
+		AND o.is_ms_shipped = 0
+	ORDER BY 
+		o.type_desc, o.name
+	`
+
+	var sqlTypes []string
+	for _, typeDesc := range typeDescs {
+		sqlTypes = append(sqlTypes, "'"+typeDesc+"'")
+	}
+
+	if len(typeDescs) > 0 {
+		typesStr := strings.Join(sqlTypes, ", ")
+		finalQuery := fmt.Sprintf(query, typesStr)
+
+		rows, err := d.DB.QueryContext(ctx, finalQuery)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var obj models.SchemaObject
+			if err := rows.Scan(&obj.Schema, &obj.Name, &obj.Type, &obj.ModifyDate); err != nil {
+				return nil, err
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+func (sqlServerDialect) FindObject(d *Database, schema, name string) (models.SchemaObject, bool, error) {
+	obj := models.SchemaObject{Schema: schema, Name: name}
+
+	query := `
+	SELECT o.type_desc, o.modify_date
+	FROM sys.objects o
+	WHERE o.name = @name AND SCHEMA_NAME(o.schema_id) = @schema AND o.is_ms_shipped = 0
+	`
+
+	err := d.DB.QueryRow(query, sql.Named("name", name), sql.Named("schema", schema)).Scan(&obj.Type, &obj.ModifyDate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return obj, false, nil
+	}
+	if err != nil {
+		return obj, false, err
+	}
+
+	return obj, true, nil
+}
+
+func (sqlServerDialect) ObjectDefinition(d *Database, obj models.SchemaObject) (string, error) {
+	ctx := context.Background()
+	var definition string
+
+	switch obj.Type {
+	case "USER_TABLE": // for tables, get the definition through a query to sys.columns
+		tableQuery := `
+		WITH IndexCTE AS (
+			SELECT 
+				ic.object_id,
+				ic.index_id,
+				i.name AS index_name,
+				i.type_desc AS index_type,
+				i.is_primary_key,
+				i.is_unique,
+				i.is_unique_constraint,
+				ISNULL(kc.is_system_named, 0) AS is_system_named,
+				(
+					SELECT c.name + ',' 
+					FROM sys.index_columns ic2
+					JOIN sys.columns c ON ic2.object_id = c.object_id AND ic2.column_id = c.column_id
+					WHERE ic2.object_id = ic.object_id AND ic2.index_id = ic.index_id
+					ORDER BY ic2.key_ordinal
+					FOR XML PATH('')
+				) AS columns
+			FROM 
+				sys.indexes i
+			JOIN 
+				sys.index_columns ic ON i.object_id = ic.object_id AND i.index_id = ic.index_id
+			LEFT JOIN 
+				sys.key_constraints kc ON kc.parent_object_id = i.object_id AND kc.unique_index_id = i.index_id
+			WHERE 
+				i.name IS NOT NULL
+			GROUP BY 
+				ic.object_id, ic.index_id, i.name, i.type_desc, i.is_primary_key, i.is_unique, i.is_unique_constraint, kc.is_system_named
+		)
+		SELECT
+			'CREATE TABLE [' + SCHEMA_NAME(t.schema_id) + '].[' + t.name + '] (' + CHAR(10) +
+			(
+				SELECT 
+					'    [' + c.name + '] ' + 
+					CASE 
+						WHEN c.is_computed = 1 THEN 'AS ' + cc.definition 
+						ELSE 
+							'[' + tp.name + ']' + 
+							CASE 
+								WHEN tp.name IN ('varchar', 'nvarchar', 'char', 'nchar') THEN '(' + 
+									CASE WHEN c.max_length = -1 THEN 'MAX' 
+									ELSE 
+										CASE WHEN tp.name IN ('nvarchar', 'nchar') 
+											THEN CAST(c.max_length/2 AS VARCHAR(10)) 
+											ELSE CAST(c.max_length AS VARCHAR(10)) 
+										END 
+									END + ')'
+								WHEN tp.name IN ('decimal', 'numeric') THEN '(' + CAST(c.precision AS VARCHAR(10)) + ', ' + CAST(c.scale AS VARCHAR(10)) + ')'
+								ELSE ''
+							END +
+							CASE WHEN c.collation_name IS NOT NULL AND @ignoreCollation = 0 
+								THEN ' COLLATE ' + c.collation_name 
+								ELSE '' 
+							END +
+							-- Add IDENTITY property if column is identity
+							CASE WHEN c.is_identity = 1 
+								THEN ' IDENTITY(' + 
+									CAST(IDENT_SEED(SCHEMA_NAME(t.schema_id) + '.' + t.name) AS VARCHAR(10)) + ',' + 
+									CAST(IDENT_INCR(SCHEMA_NAME(t.schema_id) + '.' + t.name) AS VARCHAR(10)) + ')'
+								ELSE '' 
+							END +
+							CASE WHEN c.is_nullable = 1 THEN ' NULL' ELSE ' NOT NULL' END
+					END +
+					CASE WHEN c.column_id = (SELECT MAX(column_id) FROM sys.columns c2 WHERE c2.object_id = t.object_id) AND 
+						NOT EXISTS (SELECT 1 FROM sys.indexes i WHERE i.object_id = t.object_id AND i.is_primary_key = 1)
+						THEN ''
+						ELSE ','
+					END + CHAR(10)
+				FROM 
+					sys.columns c
+				LEFT JOIN 
+					sys.types tp ON c.user_type_id = tp.user_type_id
+				LEFT JOIN 
+					sys.computed_columns cc ON c.object_id = cc.object_id AND c.column_id = cc.column_id
+				WHERE 
+					c.object_id = t.object_id
+				ORDER BY 
+					c.column_id
+				FOR XML PATH('')
+			) +
+			ISNULL((
+				SELECT 
+					CASE 
+						WHEN i.is_primary_key = 1 THEN '    ' + 
+							CASE WHEN i.is_system_named = 1 AND @ignoreSystemConstraintNames = 1 THEN '' ELSE 'CONSTRAINT [' + i.index_name + '] ' END +
+							'PRIMARY KEY ' + 
+							CASE WHEN i.index_type LIKE '%CLUSTER%' THEN 'CLUSTERED' ELSE 'NONCLUSTERED' END +
+							' (' + ISNULL(STUFF(i.columns, LEN(i.columns), 1, ''), '') + ')' + CHAR(10)
+						WHEN i.is_unique_constraint = 1 THEN '    ' + 
+							CASE WHEN i.is_system_named = 1 AND @ignoreSystemConstraintNames = 1 THEN '' ELSE 'CONSTRAINT [' + i.index_name + '] ' END +
+							'UNIQUE ' + 
+							CASE WHEN i.index_type LIKE '%CLUSTER%' THEN 'CLUSTERED' ELSE 'NONCLUSTERED' END +
+							' (' + ISNULL(STUFF(i.columns, LEN(i.columns), 1, ''), '') + ')' + CHAR(10)
+						ELSE ''
+					END
+				FROM 
+					IndexCTE i
+				WHERE 
+					i.object_id = t.object_id
+				AND
+					(i.is_primary_key = 1 OR i.is_unique_constraint = 1)
+				FOR XML PATH('')
+			), '') +
+			')' +
+			-- Add filegroup and compression unless table options are ignored
+			CASE WHEN @ignoreTableOptions = 1 THEN '' 
+			ELSE 
+				ISNULL((
+					SELECT ' ON [' + ds.name + ']'
+					FROM sys.indexes i
+					JOIN sys.data_spaces ds ON i.data_space_id = ds.data_space_id
+					WHERE i.object_id = t.object_id AND i.index_id IN (0, 1) AND ds.type = 'FG'
+				), '') +
+				ISNULL((
+					SELECT ' WITH (DATA_COMPRESSION = ' + p.data_compression_desc + ')'
+					FROM sys.partitions p
+					WHERE p.object_id = t.object_id AND p.index_id IN (0, 1) AND p.partition_number = 1 AND p.data_compression <> 0
+				), '')
+			END +
+			';' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10) +
+			-- 'SET ANSI_PADDING OFF' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10) +
+			-- Generate default constraints as separate ALTER TABLE statements
+			ISNULL((
+				SELECT 
+					'ALTER TABLE [' + SCHEMA_NAME(t.schema_id) + '].[' + t.name + '] ADD ' + 
+					CASE WHEN dc.is_system_named = 1 AND @ignoreSystemConstraintNames = 1 THEN '' ELSE 'CONSTRAINT [' + dc.name + '] ' END +
+					'DEFAULT ' + dc.definition + ' FOR [' + c.name + '];' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10)
+				FROM 
+					sys.columns c
+				JOIN 
+					sys.default_constraints dc ON c.default_object_id = dc.object_id
+				WHERE 
+					c.object_id = t.object_id
+				FOR XML PATH('')
+			), '') +
+			-- Add lock escalation when it is not the default
+			CASE WHEN @ignoreTableOptions = 0 AND t.lock_escalation_desc <> 'TABLE' 
+				THEN 'ALTER TABLE [' + SCHEMA_NAME(t.schema_id) + '].[' + t.name + '] SET (LOCK_ESCALATION = ' + t.lock_escalation_desc + ');' + CHAR(10) + 'GO' + CHAR(10) + CHAR(10)
+				ELSE '' 
+			END
+		FROM 
+			sys.tables t
+		WHERE 
+			t.name = @name AND SCHEMA_NAME(t.schema_id) = @schema
+		`
+
+		err := d.DB.QueryRowContext(ctx, tableQuery,
+			sql.Named("name", obj.Name),
+			sql.Named("schema", obj.Schema),
+			sql.Named("ignoreCollation", d.ScriptOptions.IgnoreCollation),
+			sql.Named("ignoreSystemConstraintNames", d.ScriptOptions.IgnoreSystemConstraintNames),
+			sql.Named("ignoreTableOptions", d.ScriptOptions.IgnoreTableOptions)).Scan(&definition)
+		if err != nil {
+			return "", err
+		}
+
+		// get foreign key constraints separately
+		fkQuery := `
+		SELECT 
+			'ALTER TABLE [' + SCHEMA_NAME(tab.schema_id) + '].[' + tab.name + ']  WITH ' + 
+			CASE WHEN fk.is_not_trusted = 1 AND @ignoreFkCheckState = 0 THEN 'NOCHECK' ELSE 'CHECK' END + ' ADD  ' + 
+			CASE WHEN fk.is_system_named = 1 AND @ignoreSystemConstraintNames = 1 THEN '' ELSE 'CONSTRAINT [' + fk.name + '] ' END +
+			'FOREIGN KEY([' + 
+			ISNULL(STUFF((
+				SELECT ',' + COL_NAME(fkc.parent_object_id, fkc.parent_column_id)
+				FROM sys.foreign_key_columns fkc
+				WHERE fkc.constraint_object_id = fk.object_id
+				ORDER BY fkc.constraint_column_id
+				FOR XML PATH('')
+			), 1, 1, ''), '') + '])' + 
+			CHAR(10) + 'REFERENCES [' + SCHEMA_NAME(ref_tab.schema_id) + '].[' + ref_tab.name + '] ([' +
+			ISNULL(STUFF((
+				SELECT ',' + COL_NAME(fkc.referenced_object_id, fkc.referenced_column_id)
+				FROM sys.foreign_key_columns fkc
+				WHERE fkc.constraint_object_id = fk.object_id
+				ORDER BY fkc.constraint_column_id
+				FOR XML PATH('')
+			), 1, 1, ''), '') + '])' + CHAR(10) + 'GO' + CHAR(10) +
+			-- An unnamed constraint cannot be referenced, it is created enabled
+			CASE WHEN fk.is_system_named = 1 AND @ignoreSystemConstraintNames = 1 THEN ''
+			ELSE 
+				CHAR(10) + 'ALTER TABLE [' + SCHEMA_NAME(tab.schema_id) + '].[' + tab.name + '] ' + 
+				CASE WHEN fk.is_disabled = 1 AND @ignoreFkCheckState = 0 THEN 'NOCHECK' ELSE 'CHECK' END + ' CONSTRAINT [' + 
+				fk.name + ']' + CHAR(10)
+			END
+		FROM 
+			sys.foreign_keys fk
+		JOIN 
+			sys.tables tab ON fk.parent_object_id = tab.object_id
+		JOIN 
+			sys.tables ref_tab ON fk.referenced_object_id = ref_tab.object_id
+		WHERE 
+			tab.name = @name AND SCHEMA_NAME(tab.schema_id) = @schema
+		ORDER BY fk.name;
+		`
+
+		fkRows, err := d.DB.QueryContext(ctx, fkQuery,
+			sql.Named("name", obj.Name),
+			sql.Named("schema", obj.Schema),
+			sql.Named("ignoreFkCheckState", d.ScriptOptions.IgnoreFKCheckState),
+			sql.Named("ignoreSystemConstraintNames", d.ScriptOptions.IgnoreSystemConstraintNames))
+		if err != nil {
+			return "", err
+		}
+		defer fkRows.Close()
+
+		var fkConstraints []string
+		for fkRows.Next() {
+			var fkStatement string
+			if err := fkRows.Scan(&fkStatement); err != nil {
+				return "", err
+			}
+			fkConstraints = append(fkConstraints, fkStatement)
+		}
+
+		if len(fkConstraints) > 0 {
+			definition += "\n" + strings.Join(fkConstraints, "\n")
+		}
+
+	default: // for views, procedures, functions and triggers, use sys.sql_modules
+		query := `
+		SELECT definition
+		FROM sys.sql_modules m
+		JOIN sys.objects o ON m.object_id = o.object_id
+		WHERE o.name = @name AND SCHEMA_NAME(o.schema_id) = @schema
+		`
+
+		err := d.DB.QueryRowContext(ctx, query, sql.Named("name", obj.Name), sql.Named("schema", obj.Schema)).Scan(&definition)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return definition, nil
+}
+
+// DropStatement uses DROP ... IF EXISTS for modules when the server supports
+// it, and IF OBJECT_ID checks otherwise.
+func (dialect sqlServerDialect) DropStatement(obj models.SchemaObject, d *Database) (string, error) {
+	name := dialect.QuoteIdentifier(obj.Schema) + "." + dialect.QuoteIdentifier(obj.Name)
+	if keyword, ok := moduleKeywords[obj.Type]; ok && d != nil && d.Server.SupportsDropIfExists() {
+		return fmt.Sprintf("DROP %s IF EXISTS %s;\nGO", keyword, name), nil
+	}
+
+	dropStatement := ""
+	var err error
+
+	switch obj.Type {
+	case "USER_TABLE":
+		if d == nil {
+			dropStatement = fmt.Sprintf("IF OBJECT_ID('%s', 'U') IS NOT NULL DROP TABLE %s;", strings.ReplaceAll(name, "'", "''"), name)
+			break
+		}
+		dropStatement, err = tableDropStatement(d, obj)
+	case "VIEW":
+		dropStatement = fmt.Sprintf("IF OBJECT_ID('%s', 'V') IS NOT NULL DROP VIEW %s;", strings.ReplaceAll(name, "'", "''"), name)
+	case "SQL_STORED_PROCEDURE":
+		dropStatement = fmt.Sprintf("IF OBJECT_ID('%s', 'P') IS NOT NULL DROP PROCEDURE %s;", strings.ReplaceAll(name, "'", "''"), name)
+	case "SQL_SCALAR_FUNCTION", "SQL_INLINE_TABLE_VALUED_FUNCTION", "SQL_TABLE_VALUED_FUNCTION":
+		// table-valued functions have the IF and TF types, not FN
+		dropStatement = fmt.Sprintf("IF OBJECT_ID('%s') IS NOT NULL DROP FUNCTION %s;", strings.ReplaceAll(name, "'", "''"), name)
+	case "SQL_TRIGGER":
+		dropStatement = fmt.Sprintf("IF OBJECT_ID('%s', 'TR') IS NOT NULL DROP TRIGGER %s;", strings.ReplaceAll(name, "'", "''"), name)
+	default:
+		dropStatement = fmt.Sprintf("-- Unknown object type: %s. It must be deleted manually.", obj.Type)
+	}
+
+	dropStatement = fmt.Sprintf("%s\nGO", dropStatement)
+
+	return dropStatement, err
+}
+
+func (sqlServerDialect) RenameStatement(obj models.SchemaObject, newName string) string {
+	return fmt.Sprintf("EXEC sp_rename %s, %s;\nGO\n", unicodeLiteral(quoteName(obj.Schema, obj.Name)), unicodeLiteral(newName))
+}
+
+// RowCount reads the number of rows of a table from its partitions.
+func (sqlServerDialect) RowCount(d *Database, obj models.SchemaObject) (int64, error) {
+	var rowCount int64
+
+	query := `
+	SELECT ISNULL(SUM(p.rows), 0)
+	FROM sys.partitions p
+	JOIN sys.tables t ON p.object_id = t.object_id
+	WHERE t.name = @name AND SCHEMA_NAME(t.schema_id) = @schema AND p.index_id IN (0, 1)
+	`
+
+	err := d.DB.QueryRow(query, sql.Named("name", obj.Name), sql.Named("schema", obj.Schema)).Scan(&rowCount)
+	if err != nil {
+		return 0, err
+	}
+
+	return rowCount, nil
+}
+
+// sqlServerStructure reads the structure of a table from the sys catalog
+// views, with the system type of alias type columns.
+var sqlServerStructure = structureQueries{
+	columns: `
+	SELECT c.name, TYPE_NAME(c.system_type_id), c.is_nullable,
+		CASE
+			WHEN TYPE_NAME(c.system_type_id) NOT IN ('char', 'varchar', 'nchar', 'nvarchar', 'binary', 'varbinary') THEN 0
+			WHEN c.max_length = -1 THEN -1
+			WHEN TYPE_NAME(c.system_type_id) IN ('nchar', 'nvarchar') THEN c.max_length / 2
+			ELSE c.max_length
+		END
+	FROM sys.columns c
+	WHERE c.object_id = OBJECT_ID(QUOTENAME(@schema) + '.' + QUOTENAME(@name))
+	ORDER BY c.column_id
+	`,
+	keys: `
+	SELECT i.name, i.is_primary_key, i.is_unique, c.name
+	FROM sys.indexes i
+	JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+	JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+	WHERE i.object_id = OBJECT_ID(QUOTENAME(@schema) + '.' + QUOTENAME(@name)) AND ic.key_ordinal > 0
+	ORDER BY i.name, ic.key_ordinal
+	`,
+	foreignKeys: `
+	SELECT fk.name, pc.name, SCHEMA_NAME(rt.schema_id), rt.name, rc.name
+	FROM sys.foreign_keys fk
+	JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+	JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+	JOIN sys.tables rt ON rt.object_id = fkc.referenced_object_id
+	JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+	WHERE fk.parent_object_id = OBJECT_ID(QUOTENAME(@schema) + '.' + QUOTENAME(@name))
+	ORDER BY fk.name, fkc.constraint_column_id
+	`,
+}
+
+func (sqlServerDialect) TableStructure(d *Database, obj models.SchemaObject) (models.TableStructure, error) {
+	return readTableStructure(d, sqlServerStructure, sql.Named("schema", obj.Schema), sql.Named("name", obj.Name))
+}
+
+// moduleKeywords maps module types to the keyword of their DROP statement.
+var moduleKeywords = map[string]string{
+	"VIEW":                             "VIEW",
+	"SQL_STORED_PROCEDURE":             "PROCEDURE",
+	"SQL_SCALAR_FUNCTION":              "FUNCTION",
+	"SQL_INLINE_TABLE_VALUED_FUNCTION": "FUNCTION",
+	"SQL_TABLE_VALUED_FUNCTION":        "FUNCTION",
+	"SQL_TRIGGER":                      "TRIGGER",
+}
+
+// tableDropStatement drops a table after the foreign keys and defaults
+// that would keep it from being dropped.
+func tableDropStatement(d *Database, obj models.SchemaObject) (string, error) {
+	ctx := context.Background()
+	var dropStatement string
+
+	query := `
+	SELECT 
+		ISNULL(STUFF((
+			-- Get Foreign Key constraint drops
+			SELECT CHAR(10) + 'ALTER TABLE [' + SCHEMA_NAME(tab.schema_id) + '].[' + tab.name + '] DROP CONSTRAINT [' + fk.name + ']' + CHAR(10) + 'GO' + CHAR(10)
+			FROM sys.foreign_keys fk
+			JOIN sys.tables tab ON fk.parent_object_id = tab.object_id
+			WHERE tab.name = @name AND SCHEMA_NAME(tab.schema_id) = @schema
+			FOR XML PATH('')
+		), 1, 1, ''), '') +
+		ISNULL(STUFF((
+			-- Get Default constraint drops
+			SELECT CHAR(10) + 'ALTER TABLE [' + SCHEMA_NAME(t.schema_id) + '].[' + t.name + '] DROP CONSTRAINT [' + dc.name + ']' + CHAR(10) + 'GO' + CHAR(10)
+			FROM sys.tables t
+			JOIN sys.default_constraints dc ON t.object_id = dc.parent_object_id
+			WHERE t.name = @name AND SCHEMA_NAME(t.schema_id) = @schema
+			FOR XML PATH('')
+		), 1, 1, ''), '') +
+		-- Add final table drop
+		CHAR(10) + 'IF OBJECT_ID(''[' + @schema + '].[' + @name + ']'', ''U'') IS NOT NULL' + CHAR(10) +
+		'DROP TABLE [' + @schema + '].[' + @name + ']' + CHAR(10)
+	`
+
+	err := d.DB.QueryRowContext(ctx, query,
+		sql.Named("name", obj.Name),
+		sql.Named("schema", obj.Schema)).Scan(&dropStatement)
+	if err != nil {
+		return "", err
+	}
+
+	return dropStatement, nil
+}
+
+// ErrorLine returns the line of the batch where a server error was raised.
+// Errors raised inside a called module carry a line of that module instead,
+// so they are not reported.
+func ErrorLine(err error) (int, bool) {
+	var serverError mssql.Error
+	if errors.As(err, &serverError) && serverError.ProcName == "" && serverError.LineNo > 0 {
+		return int(serverError.LineNo), true
+	}
+	return 0, false
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+func TestSQLServerRenameStatement(t *testing.T) {
+	tests := []struct {
+		obj     models.SchemaObject
+		newName string
+		want    string
+	}{
+		{
+			models.SchemaObject{Schema: "dbo", Name: "Orders"},
+			"orders",
+			"EXEC sp_rename N'[dbo].[Orders]', N'orders';\nGO\n",
+		},
+		{
+			models.SchemaObject{Schema: "sales]x", Name: "O'Brien"},
+			"o'brien",
+			"EXEC sp_rename N'[sales]]x].[O''Brien]', N'o''brien';\nGO\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := (sqlServerDialect{}).RenameStatement(test.obj, test.newName); got != test.want {
+			t.Errorf("RenameStatement(%s.%s, %s) = %q, want %q", test.obj.Schema, test.obj.Name, test.newName, got, test.want)
+		}
+	}
+}
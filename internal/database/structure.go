@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// structureQueries are the catalog queries that describe a table, all taking
+// the same arguments:
+//
+//   - columns returns the name, type, nullability and length of each column
+//     in order, the length being -1 for unlimited types and 0 for types
+//     without one
+//   - keys returns the name of each index, whether it is the primary key,
+//     whether it is unique and one of its key columns, in key order
+//   - foreignKeys returns the name of each foreign key, one of its columns,
+//     the referenced schema and table and the referenced column, in key order
+type structureQueries struct {
+	columns     string
+	keys        string
+	foreignKeys string
+}
+
+// GetTableStructure returns the columns, keys, indexes and foreign keys of a
+// table.
+func (d *Database) GetTableStructure(obj models.SchemaObject) (models.TableStructure, error) {
+	return d.Dialect.TableStructure(d, obj)
+}
+
+// readTableStructure runs the structure queries of a dialect and groups the
+// rows of each index and foreign key.
+func readTableStructure(d *Database, queries structureQueries, args ...any) (models.TableStructure, error) {
+	ctx := context.Background()
+	var structure models.TableStructure
+
+	rows, err := d.DB.QueryContext(ctx, queries.columns, args...)
+	if err != nil {
+		return structure, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column models.ColumnStructure
+		if err := rows.Scan(&column.Name, &column.Type, &column.Nullable, &column.Length); err != nil {
+			return structure, err
+		}
+		column.Type = strings.ToLower(strings.TrimSpace(column.Type))
+		structure.Columns = append(structure.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return structure, err
+	}
+
+	keys, err := d.DB.QueryContext(ctx, queries.keys, args...)
+	if err != nil {
+		return structure, err
+	}
+	defer keys.Close()
+	for keys.Next() {
+		var indexName, column string
+		var isPrimaryKey, isUnique bool
+		if err := keys.Scan(&indexName, &isPrimaryKey, &isUnique, &column); err != nil {
+			return structure, err
+		}
+		if isPrimaryKey {
+			structure.PrimaryKey = append(structure.PrimaryKey, column)
+			continue
+		}
+		last := len(structure.Indexes) - 1
+		if last < 0 || structure.Indexes[last].Name != indexName {
+			structure.Indexes = append(structure.Indexes, models.IndexStructure{Name: indexName, Unique: isUnique})
+			last++
+		}
+		structure.Indexes[last].Columns = append(structure.Indexes[last].Columns, column)
+	}
+	if err := keys.Err(); err != nil {
+		return structure, err
+	}
+
+	foreignKeys, err := d.DB.QueryContext(ctx, queries.foreignKeys, args...)
+	if err != nil {
+		return structure, err
+	}
+	defer foreignKeys.Close()
+	for foreignKeys.Next() {
+		var foreignKeyName, column, referencedSchema, referencedTable, referencedColumn string
+		if err := foreignKeys.Scan(&foreignKeyName, &column, &referencedSchema, &referencedTable, &referencedColumn); err != nil {
+			return structure, err
+		}
+		last := len(structure.ForeignKeys) - 1
+		if last < 0 || structure.ForeignKeys[last].Name != foreignKeyName {
+			structure.ForeignKeys = append(structure.ForeignKeys, models.ForeignKeyStructure{
+				Name:             foreignKeyName,
+				ReferencedSchema: referencedSchema,
+				ReferencedTable:  referencedTable,
+			})
+			last++
+		}
+		structure.ForeignKeys[last].Columns = append(structure.ForeignKeys[last].Columns, column)
+		structure.ForeignKeys[last].ReferencedColumns = append(structure.ForeignKeys[last].ReferencedColumns, referencedColumn)
+	}
+	return structure, foreignKeys.Err()
+}
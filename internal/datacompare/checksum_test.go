@@ -0,0 +1,237 @@
+package datacompare
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// checksumReader reads the rows of a table named t with the columns id and
+// code from SQLite, with a checksum SQLite can compute, and records the key
+// ranges whose checksums are read.
+type checksumReader struct {
+	database.Dialect
+	ranges *[]database.KeyRange
+}
+
+func (checksumReader) Columns(d *database.Database, obj models.SchemaObject) ([]database.Column, error) {
+	return []database.Column{{Name: "id", Type: "bigint"}, {Name: "code", Type: "nvarchar"}}, nil
+}
+
+func (checksumReader) PrimaryKey(d *database.Database, obj models.SchemaObject) ([]string, error) {
+	return []string{"id"}, nil
+}
+
+func (checksumReader) QueryRows(ctx context.Context, d *database.Database, obj models.SchemaObject, columns []string, orderBy []database.Column, keyRange *database.KeyRange) (*sql.Rows, error) {
+	if keyRange == nil {
+		return d.DB.QueryContext(ctx, "SELECT id, code FROM t ORDER BY id")
+	}
+	return d.DB.QueryContext(ctx, "SELECT id, code FROM t WHERE id BETWEEN ? AND ? ORDER BY id", keyRange.Low, keyRange.High)
+}
+
+func (r checksumReader) RangeChecksum(ctx context.Context, d *database.Database, obj models.SchemaObject, columns []string, keyRange *database.KeyRange) (database.Checksum, error) {
+	if r.ranges != nil {
+		*r.ranges = append(*r.ranges, *keyRange)
+	}
+
+	var checksum database.Checksum
+	err := d.DB.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM((id % 1000003) * 31 + unicode(code)), 0) FROM t WHERE id BETWEEN ? AND ?",
+		keyRange.Low, keyRange.High).Scan(&checksum.Rows, &checksum.Checksum)
+	return checksum, err
+}
+
+func (checksumReader) KeyBounds(ctx context.Context, d *database.Database, obj models.SchemaObject, column string) (int64, int64, bool, error) {
+	var low, high sql.NullInt64
+	err := d.DB.QueryRowContext(ctx, "SELECT MIN(id), MAX(id) FROM t").Scan(&low, &high)
+	return low.Int64, high.Int64, low.Valid, err
+}
+
+func (checksumReader) TableReferences(d *database.Database) ([]database.TableReference, error) {
+	return nil, nil
+}
+
+// checksumDatabase returns a SQLite database holding a table t with the
+// given codes by id.
+func checksumDatabase(t *testing.T, codes map[int64]string, ranges *[]database.KeyRange) *database.Database {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := database.Connect(config.DatabaseConfig{Engine: database.EngineSQLite, Database: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	d.Dialect = checksumReader{Dialect: d.Dialect, ranges: ranges}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("CREATE TABLE t (id integer PRIMARY KEY, code text NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	for id, code := range codes {
+		if _, err := tx.Exec("INSERT INTO t VALUES (?, ?)", id, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// codeRange returns the code "a" for every id from low to high.
+func codeRange(low, high int64) map[int64]string {
+	codes := map[int64]string{}
+	for id := low; id <= high; id++ {
+		codes[id] = "a"
+	}
+	return codes
+}
+
+// checksumTable compares the table t of both databases and returns the
+// result with the keys of the differences by action.
+func checksumTable(t *testing.T, c *Comparator) (*TableResult, map[RowAction][]int64) {
+	t.Helper()
+
+	table := models.SchemaObject{Schema: "main", Name: "t", Type: "USER_TABLE"}
+	diffs := map[RowAction][]int64{}
+	result, err := c.ChecksumTable(table, table, func(diff RowDiff) error {
+		diffs[diff.Action] = append(diffs[diff.Action], diff.Key[0].Value.(int64))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, diffs
+}
+
+func TestChecksumTableBisect(t *testing.T) {
+	targetCodes := codeRange(1, 3001)
+	delete(targetCodes, 10)
+	targetCodes[2500] = "b"
+
+	var ranges []database.KeyRange
+	c := NewComparator(checksumDatabase(t, codeRange(1, 3000), &ranges), checksumDatabase(t, targetCodes, nil))
+	result, diffs := checksumTable(t, c)
+
+	want := map[RowAction][]int64{RowInsert: {10}, RowUpdate: {2500}, RowDelete: {3001}}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("differences = %v, want %v", diffs, want)
+	}
+	if result.Unchanged != 2998 {
+		t.Errorf("Unchanged = %d, want 2998", result.Unchanged)
+	}
+
+	// the halves of each range are contiguous, and only the halves holding
+	// a difference are split again until they hold at most leafRows rows
+	wantRanges := []database.KeyRange{
+		{Column: "id", Low: 1, High: 3001},
+		{Column: "id", Low: 1, High: 1501},
+		{Column: "id", Low: 1, High: 751},
+		{Column: "id", Low: 752, High: 1501},
+		{Column: "id", Low: 1502, High: 3001},
+		{Column: "id", Low: 1502, High: 2251},
+		{Column: "id", Low: 2252, High: 3001},
+	}
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("checksum ranges = %v, want %v", ranges, wantRanges)
+	}
+	if result.Checksum.Ranges != 7 || result.Checksum.MismatchedRanges != 2 {
+		t.Errorf("Checksum = %+v, want 7 ranges and 2 mismatched", result.Checksum)
+	}
+	if result.Checksum.SourceRows != 3000 || result.Checksum.TargetRows != 3000 {
+		t.Errorf("Checksum rows = %d and %d, want 3000", result.Checksum.SourceRows, result.Checksum.TargetRows)
+	}
+}
+
+func TestChecksumTableOneSidedEmpty(t *testing.T) {
+	tests := []struct {
+		name           string
+		source, target map[int64]string
+		action         RowAction
+	}{
+		{"empty target", codeRange(1, 1500), nil, RowInsert},
+		{"empty source", nil, codeRange(1, 1500), RowDelete},
+	}
+
+	for _, test := range tests {
+		c := NewComparator(checksumDatabase(t, test.source, nil), checksumDatabase(t, test.target, nil))
+		result, diffs := checksumTable(t, c)
+
+		// the key range of the side with rows is bisected down to the leaves
+		if got := len(diffs[test.action]); got != 1500 || len(diffs) != 1 {
+			t.Errorf("%s: %d %s differences of %d actions, want 1500", test.name, got, test.action, len(diffs))
+		}
+		if result.Checksum.MismatchedRanges != 2 || result.Unchanged != 0 {
+			t.Errorf("%s: Checksum = %+v, Unchanged = %d, want 2 mismatched ranges", test.name, result.Checksum, result.Unchanged)
+		}
+	}
+
+	c := NewComparator(checksumDatabase(t, nil, nil), checksumDatabase(t, nil, nil))
+	result, diffs := checksumTable(t, c)
+	if len(diffs) != 0 || result.Checksum.Ranges != 0 {
+		t.Errorf("empty tables: differences = %v, Checksum = %+v, want none", diffs, result.Checksum)
+	}
+}
+
+func TestBisectFullKeyRange(t *testing.T) {
+	codes := map[int64]string{math.MinInt64: "a", 0: "a", math.MaxInt64: "a"}
+	targetCodes := map[int64]string{math.MinInt64: "a", 0: "a", math.MaxInt64: "b"}
+
+	var ranges []database.KeyRange
+	c := NewComparator(checksumDatabase(t, codes, &ranges), checksumDatabase(t, targetCodes, nil))
+	result, err := c.prepare(models.SchemaObject{Name: "t"}, models.SchemaObject{Name: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Checksum = &ChecksumSummary{}
+
+	// row counts above leafRows force a split of the widest range, whose
+	// width does not fit in an int64
+	keyRange := &database.KeyRange{Column: "id", Low: math.MinInt64, High: math.MaxInt64}
+	var updated []int64
+	err = c.bisect(context.Background(), result, keyRange, database.Checksum{Rows: 5000, Checksum: 1}, database.Checksum{Rows: 5000, Checksum: 2},
+		func(diff RowDiff) error {
+			updated = append(updated, diff.Key[0].Value.(int64))
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRanges := []database.KeyRange{
+		{Column: "id", Low: math.MinInt64, High: -1},
+		{Column: "id", Low: 0, High: math.MaxInt64},
+	}
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("checksum ranges = %v, want %v", ranges, wantRanges)
+	}
+	if !reflect.DeepEqual(updated, []int64{math.MaxInt64}) || result.Unchanged != 2 {
+		t.Errorf("updated keys = %v, Unchanged = %d, want the highest key and 2 unchanged rows", updated, result.Unchanged)
+	}
+
+	// a range of a single key is compared row by row whatever its count
+	ranges = nil
+	single := &database.KeyRange{Column: "id", Low: 0, High: 0}
+	err = c.bisect(context.Background(), result, single, database.Checksum{Rows: 5000, Checksum: 1}, database.Checksum{Rows: 5000, Checksum: 2},
+		func(RowDiff) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 0 || result.Checksum.MismatchedRanges != 2 {
+		t.Errorf("a single key range was split into %v, MismatchedRanges = %d, want 2", ranges, result.Checksum.MismatchedRanges)
+	}
+}
@@ -0,0 +1,486 @@
+package datacompare
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+type RowAction string
+
+const (
+	RowInsert RowAction = "INSERT"
+	RowUpdate RowAction = "UPDATE"
+	RowDelete RowAction = "DELETE"
+)
+
+// ColumnValue is the value of a column in a row.
+type ColumnValue struct {
+	Column database.Column
+	Value  any
+}
+
+// ColumnDiff is a column whose value differs between the databases.
+type ColumnDiff struct {
+	Column database.Column
+	Source any
+	Target any
+}
+
+// RowDiff is a row to insert into, update in or delete from the target so it
+// matches the source. Values holds the source row for inserts and updates and
+// the target row for deletes.
+type RowDiff struct {
+	Action  RowAction
+	Key     []ColumnValue
+	Values  []ColumnValue
+	Changes []ColumnDiff
+}
+
+// TableResult summarizes the comparison of the data of a table.
+type TableResult struct {
+	Source models.SchemaObject
+	Target models.SchemaObject
+	// KeyColumns is the primary key the rows are matched on
+	KeyColumns []string
+	// Columns are the columns compared, as named in the source
+	Columns []database.Column
+	// TargetColumns are the names of the compared columns in the target
+	TargetColumns     []string
+	SourceOnlyColumns []string
+	TargetOnlyColumns []string
+	Inserted          int
+	Updated           int
+	Deleted           int
+	Unchanged         int
+	// Checksum is set when the table was compared by checksum
+	Checksum *ChecksumSummary
+	// SourceSize and TargetSize are set by the caller when the partition
+	// stats of the databases can be read
+	SourceSize *models.TableSize
+	TargetSize *models.TableSize
+}
+
+// HasDifferences reports whether the target rows differ from the source.
+func (r *TableResult) HasDifferences() bool {
+	return r.Inserted+r.Updated+r.Deleted > 0
+}
+
+type Comparator struct {
+	SourceDB *database.Database
+	TargetDB *database.Database
+}
+
+func NewComparator(sourceDB, targetDB *database.Database) *Comparator {
+	return &Comparator{
+		SourceDB: sourceDB,
+		TargetDB: targetDB,
+	}
+}
+
+// CompareTable compares the rows of a source table with those of its target
+// counterpart, matching them on the primary key, and calls visit for every
+// row that differs. Both tables are read in key order and merged as they
+// are read, so only the current row of each side is held in memory.
+func (c *Comparator) CompareTable(source, target models.SchemaObject, visit func(RowDiff) error) (*TableResult, error) {
+	result, err := c.prepare(source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.compareRows(context.Background(), result, nil, visit); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// compareRows compares the rows of the tables of result within a key range of
+// the source, or every row when keyRange is nil, and adds them to the counts.
+// The rows of both tables are merged in key order: a source key below the
+// current target key is missing in the target and a target key below the
+// current source key is missing in the source.
+func (c *Comparator) compareRows(ctx context.Context, result *TableResult, keyRange *database.KeyRange, visit func(RowDiff) error) error {
+	keyIndexes := make([]int, len(result.KeyColumns))
+	for i, keyColumn := range result.KeyColumns {
+		keyIndexes[i] = columnIndex(result.Columns, keyColumn)
+	}
+
+	sourceRows, err := openRows(ctx, c.SourceDB, result.Source, result.Columns, keyIndexes, sourceColumnNames(result), keyRange)
+	if err != nil {
+		return err
+	}
+	defer sourceRows.close()
+	targetRows, err := openRows(ctx, c.TargetDB, result.Target, result.Columns, keyIndexes, result.TargetColumns, targetRange(result, keyRange))
+	if err != nil {
+		return err
+	}
+	defer targetRows.close()
+
+	for sourceRows.values != nil || targetRows.values != nil {
+		var order int
+		switch {
+		case targetRows.values == nil:
+			order = -1
+		case sourceRows.values == nil:
+			order = 1
+		default:
+			order = compareKeys(result.Columns, keyIndexes, sourceRows.values, targetRows.values)
+		}
+
+		switch {
+		case order < 0:
+			values := sourceRows.values
+			result.Inserted++
+			err = visit(RowDiff{
+				Action: RowInsert,
+				Key:    columnValues(result.Columns, keyIndexes, values),
+				Values: columnValues(result.Columns, nil, values),
+			})
+			if err == nil {
+				err = sourceRows.next()
+			}
+		case order > 0:
+			values := targetRows.values
+			result.Deleted++
+			err = visit(RowDiff{
+				Action: RowDelete,
+				Key:    columnValues(result.Columns, keyIndexes, values),
+				Values: columnValues(result.Columns, nil, values),
+			})
+			if err == nil {
+				err = targetRows.next()
+			}
+		default:
+			err = compareRow(result, keyIndexes, sourceRows.values, targetRows.values, visit)
+			if err == nil {
+				err = sourceRows.next()
+			}
+			if err == nil {
+				err = targetRows.next()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compareRow compares the values of a row found in both tables.
+func compareRow(result *TableResult, keyIndexes []int, values, targetValues []any, visit func(RowDiff) error) error {
+	var changes []ColumnDiff
+	for i, column := range result.Columns {
+		if FormatValue(column, values[i]) != FormatValue(column, targetValues[i]) {
+			changes = append(changes, ColumnDiff{Column: column, Source: values[i], Target: targetValues[i]})
+		}
+	}
+	if len(changes) == 0 {
+		result.Unchanged++
+		return nil
+	}
+
+	result.Updated++
+	return visit(RowDiff{
+		Action:  RowUpdate,
+		Key:     columnValues(result.Columns, keyIndexes, values),
+		Values:  columnValues(result.Columns, nil, values),
+		Changes: changes,
+	})
+}
+
+// prepare matches the columns and primary keys of both tables.
+func (c *Comparator) prepare(source, target models.SchemaObject) (*TableResult, error) {
+	result := &TableResult{Source: source, Target: target}
+
+	keyColumns, err := c.SourceDB.GetPrimaryKey(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyColumns) == 0 {
+		return nil, fmt.Errorf("%s.%s has no primary key", source.Schema, source.Name)
+	}
+	targetKeyColumns, err := c.TargetDB.GetPrimaryKey(target)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(strings.Join(keyColumns, ","), strings.Join(targetKeyColumns, ",")) {
+		return nil, fmt.Errorf("the primary key of %s.%s is (%s) in the source and (%s) in the target",
+			source.Schema, source.Name, strings.Join(keyColumns, ", "), strings.Join(targetKeyColumns, ", "))
+	}
+	result.KeyColumns = keyColumns
+
+	sourceColumns, err := c.SourceDB.GetColumns(source)
+	if err != nil {
+		return nil, err
+	}
+	targetColumns, err := c.TargetDB.GetColumns(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetNames []string
+	matched := make(map[string]bool)
+	for _, column := range sourceColumns {
+		targetColumn, exists := findColumn(targetColumns, column.Name)
+		if !exists {
+			result.SourceOnlyColumns = append(result.SourceOnlyColumns, column.Name)
+			continue
+		}
+		matched[strings.ToLower(column.Name)] = true
+		if !column.IsComparable() || !targetColumn.IsComparable() {
+			continue
+		}
+		// text keys are matched with the collation of the target
+		column.Collation = targetColumn.Collation
+		result.Columns = append(result.Columns, column)
+		targetNames = append(targetNames, targetColumn.Name)
+	}
+	for _, column := range targetColumns {
+		if !matched[strings.ToLower(column.Name)] {
+			result.TargetOnlyColumns = append(result.TargetOnlyColumns, column.Name)
+		}
+	}
+
+	for _, keyColumn := range keyColumns {
+		if columnIndex(result.Columns, keyColumn) < 0 {
+			return nil, fmt.Errorf("the primary key column %s of %s.%s cannot be compared", keyColumn, source.Schema, source.Name)
+		}
+	}
+
+	result.TargetColumns = targetNames
+	return result, nil
+}
+
+// rowCursor reads the rows of a table in key order, one at a time.
+type rowCursor struct {
+	table      models.SchemaObject
+	rows       *sql.Rows
+	columns    []database.Column
+	keyIndexes []int
+	// values is the current row, nil once every row is read
+	values []any
+}
+
+// openRows queries the rows of a table, whose columns are named names, in
+// key order and reads the first one.
+func openRows(ctx context.Context, db *database.Database, table models.SchemaObject, columns []database.Column, keyIndexes []int, names []string, keyRange *database.KeyRange) (*rowCursor, error) {
+	orderBy := make([]database.Column, len(keyIndexes))
+	for i, index := range keyIndexes {
+		orderBy[i] = columns[index]
+		orderBy[i].Name = names[index]
+	}
+
+	rows, err := db.QueryRows(ctx, table, names, orderBy, keyRange)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s.%s: %v", table.Schema, table.Name, err)
+	}
+
+	cursor := &rowCursor{table: table, rows: rows, columns: columns, keyIndexes: keyIndexes}
+	if err := cursor.next(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// next reads the following row. The database must return the rows in the
+// key order of compareKeys, as the merge of both tables relies on it. Text
+// keys are ordered by code point by QueryRows; a row out of order, such as
+// a varchar key holding characters the code page orders otherwise, is
+// reported as an error.
+func (c *rowCursor) next() error {
+	previous := c.values
+	c.values = nil
+	if !c.rows.Next() {
+		if err := c.rows.Err(); err != nil {
+			return fmt.Errorf("error reading %s.%s: %v", c.table.Schema, c.table.Name, err)
+		}
+		return nil
+	}
+
+	values := make([]any, len(c.columns))
+	pointers := make([]any, len(c.columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := c.rows.Scan(pointers...); err != nil {
+		return fmt.Errorf("error reading %s.%s: %v", c.table.Schema, c.table.Name, err)
+	}
+	if previous != nil && compareKeys(c.columns, c.keyIndexes, previous, values) > 0 {
+		return fmt.Errorf("the rows of %s.%s are not returned in the key order dbgo compares them in",
+			c.table.Schema, c.table.Name)
+	}
+	c.values = values
+	return nil
+}
+
+func (c *rowCursor) close() {
+	c.rows.Close()
+}
+
+// compareKeys orders two rows by their primary key, returning a negative
+// number when a comes first, a positive one when b does and 0 when they
+// match. Text values are compared by code point, as QueryRows orders them
+// with a binary collation, ignoring trailing spaces and, under a
+// case-insensitive collation, case.
+func compareKeys(columns []database.Column, keyIndexes []int, a, b []any) int {
+	for _, index := range keyIndexes {
+		if order := compareValues(columns[index], a[index], b[index]); order != 0 {
+			return order
+		}
+	}
+	return 0
+}
+
+func compareValues(column database.Column, a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(keyText(column, a), keyText(column, b))
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	case []byte:
+		if b, ok := b.([]byte); ok {
+			switch column.Type {
+			case "uniqueidentifier":
+				return bytes.Compare(guidOrder(a), guidOrder(b))
+			case "decimal", "numeric", "money", "smallmoney":
+				if x, ok := new(big.Rat).SetString(string(a)); ok {
+					if y, ok := new(big.Rat).SetString(string(b)); ok {
+						return x.Cmp(y)
+					}
+				}
+			}
+			return bytes.Compare(a, b)
+		}
+	}
+
+	if x, ok := keyNumber(a); ok {
+		if y, ok := keyNumber(b); ok {
+			return x.Cmp(y)
+		}
+	}
+	return strings.Compare(FormatValue(column, a), FormatValue(column, b))
+}
+
+func keyText(column database.Column, text string) string {
+	text = strings.TrimRight(text, " ")
+	if column.IsCaseInsensitive() {
+		text = strings.ToLower(text)
+	}
+	return text
+}
+
+// guidOrder returns the bytes of a uniqueidentifier in the order SQL Server
+// sorts them, starting with the last group.
+func guidOrder(id []byte) []byte {
+	if len(id) != 16 {
+		return id
+	}
+	ordered := make([]byte, 0, 16)
+	ordered = append(ordered, id[10:16]...)
+	ordered = append(ordered, id[8:10]...)
+	ordered = append(ordered, id[6:8]...)
+	ordered = append(ordered, id[4:6]...)
+	return append(ordered, id[0:4]...)
+}
+
+func keyNumber(value any) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case uint8:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case float64:
+		// SetFloat64 returns nil for infinities and NaN
+		number := new(big.Rat).SetFloat64(v)
+		return number, number != nil
+	case float32:
+		number := new(big.Rat).SetFloat64(float64(v))
+		return number, number != nil
+	case bool:
+		if v {
+			return big.NewRat(1, 1), true
+		}
+		return new(big.Rat), true
+	}
+	return nil, false
+}
+
+func columnValues(columns []database.Column, indexes []int, values []any) []ColumnValue {
+	if indexes == nil {
+		indexes = make([]int, len(columns))
+		for i := range indexes {
+			indexes[i] = i
+		}
+	}
+
+	columnValues := make([]ColumnValue, len(indexes))
+	for i, index := range indexes {
+		columnValues[i] = ColumnValue{Column: columns[index], Value: values[index]}
+	}
+	return columnValues
+}
+
+func sourceColumnNames(result *TableResult) []string {
+	names := make([]string, len(result.Columns))
+	for i, column := range result.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// targetRange returns a source key range with the name of the key column in
+// the target.
+func targetRange(result *TableResult, keyRange *database.KeyRange) *database.KeyRange {
+	if keyRange == nil {
+		return nil
+	}
+	return &database.KeyRange{
+		Column: result.TargetColumns[columnIndex(result.Columns, keyRange.Column)],
+		Low:    keyRange.Low,
+		High:   keyRange.High,
+	}
+}
+
+func findColumn(columns []database.Column, name string) (database.Column, bool) {
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, name) {
+			return column, true
+		}
+	}
+	return database.Column{}, false
+}
+
+func columnIndex(columns []database.Column, name string) int {
+	for i, column := range columns {
+		if strings.EqualFold(column.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
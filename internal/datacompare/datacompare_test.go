@@ -0,0 +1,73 @@
+package datacompare
+
+import (
+	"testing"
+	"time"
+
+	"github.com/victorlunam/dbgo/internal/database"
+)
+
+func TestCompareKeys(t *testing.T) {
+	text := database.Column{Name: "code", Type: "varchar", Collation: "Latin1_General_CI_AS"}
+	binaryText := database.Column{Name: "code", Type: "varchar", Collation: "Latin1_General_BIN2"}
+	number := database.Column{Name: "id", Type: "int"}
+	amount := database.Column{Name: "amount", Type: "decimal"}
+	id := database.Column{Name: "id", Type: "uniqueidentifier"}
+	date := database.Column{Name: "day", Type: "date"}
+
+	// the last group of a uniqueidentifier sorts first
+	lowID := []byte{0xFF, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	highID := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+
+	tests := []struct {
+		column database.Column
+		a, b   any
+		want   int
+	}{
+		{text, "abc", "ABC  ", 0},
+		{text, "abc", "abd", -1},
+		{binaryText, "B", "a", -1},
+		// code point order, which the binary collation of QueryRows matches
+		{text, "A-1", "A1", -1},
+		{text, "Émile", "Emily", 1},
+		{text, "émile", "ÉMILE", 0},
+		{binaryText, "Zoë", "Zoe", 1},
+		{number, int64(9), int64(10), -1},
+		{number, int64(10), float64(9.5), 1},
+		{amount, []byte("10.50"), []byte("9.75"), 1},
+		{amount, []byte("1.0"), []byte("1.00"), 0},
+		{id, lowID, highID, -1},
+		{date, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1},
+	}
+
+	for _, test := range tests {
+		columns := []database.Column{test.column}
+		got := compareKeys(columns, []int{0}, []any{test.a}, []any{test.b})
+		if sign(got) != test.want {
+			t.Errorf("compareKeys(%s %v, %v) = %d, want %d", test.column.Type, test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCompareKeysUsesKeyOrder(t *testing.T) {
+	columns := []database.Column{{Name: "name", Type: "varchar"}, {Name: "id", Type: "int"}}
+	a := []any{"z", int64(1)}
+	b := []any{"a", int64(2)}
+
+	if got := compareKeys(columns, []int{1, 0}, a, b); got >= 0 {
+		t.Errorf("compareKeys on (id, name) = %d, want a negative number", got)
+	}
+	if got := compareKeys(columns, []int{0, 1}, a, b); got <= 0 {
+		t.Errorf("compareKeys on (name, id) = %d, want a positive number", got)
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+package datacompare
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// Exporter writes the differing rows of a table to a CSV or JSON file as
+// they are found, so large differences are not held in memory. The file is
+// created with the first row, tables without differences get no file.
+//
+// Every row has a status and the source and target value of each compared
+// column: the target values of inserts and the source values of deletes are
+// empty in CSV files and null in JSON files. CSV files write NULL as an empty
+// field, JSON files keep numbers and booleans as such and write other values
+// as text.
+type Exporter struct {
+	Format string
+	Path   string
+	file   *os.File
+	writer *bufio.Writer
+	csv    *csv.Writer
+	rows   int
+}
+
+func NewExporter(format, path string) (*Exporter, error) {
+	if format != ExportCSV && format != ExportJSON {
+		return nil, fmt.Errorf("unknown export format %q, expected %s or %s", format, ExportCSV, ExportJSON)
+	}
+	return &Exporter{Format: format, Path: path}, nil
+}
+
+// Write writes a row difference to the file.
+func (e *Exporter) Write(diff RowDiff) error {
+	if e.file == nil {
+		if err := e.open(diff); err != nil {
+			return err
+		}
+	}
+
+	source, target := sideValues(diff)
+	var err error
+	if e.Format == ExportCSV {
+		record := []string{string(diff.Action)}
+		for i, value := range diff.Values {
+			targetValue := ColumnValue{Column: value.Column}
+			if target != nil {
+				targetValue.Value = target[i]
+			}
+			record = append(record, csvValue(value, source), csvValue(targetValue, target != nil))
+		}
+		err = e.csv.Write(record)
+	} else {
+		err = e.writeJSON(diff, source, target)
+	}
+	e.rows++
+	return err
+}
+
+// Close flushes and closes the file, if a row was written.
+func (e *Exporter) Close() error {
+	if e.file == nil {
+		return nil
+	}
+	if e.Format == ExportCSV {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			e.file.Close()
+			return err
+		}
+	} else {
+		fmt.Fprint(e.writer, "\n]\n")
+	}
+	if err := e.writer.Flush(); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
+
+// open creates the file and writes the CSV header from the columns of the
+// first row.
+func (e *Exporter) open(diff RowDiff) error {
+	file, err := os.Create(e.Path)
+	if err != nil {
+		return err
+	}
+	e.file = file
+	e.writer = bufio.NewWriter(file)
+
+	if e.Format == ExportJSON {
+		_, err := fmt.Fprint(e.writer, "[")
+		return err
+	}
+
+	e.csv = csv.NewWriter(e.writer)
+	header := []string{"status"}
+	for _, value := range diff.Values {
+		header = append(header, "source."+value.Column.Name, "target."+value.Column.Name)
+	}
+	return e.csv.Write(header)
+}
+
+func (e *Exporter) writeJSON(diff RowDiff, source bool, target []any) error {
+	if e.rows > 0 {
+		fmt.Fprint(e.writer, ",")
+	}
+	fmt.Fprintf(e.writer, "\n  {\"status\": %q, \"source\": ", diff.Action)
+
+	// objects are written by hand to keep the column order
+	sourceValues := make([]any, len(diff.Values))
+	for i, value := range diff.Values {
+		sourceValues[i] = value.Value
+	}
+	if err := e.writeJSONRow(diff, sourceValues, source); err != nil {
+		return err
+	}
+	fmt.Fprint(e.writer, ", \"target\": ")
+	if err := e.writeJSONRow(diff, target, target != nil); err != nil {
+		return err
+	}
+
+	changed := []string{}
+	for _, change := range diff.Changes {
+		changed = append(changed, change.Column.Name)
+	}
+	encoded, err := json.Marshal(changed)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.writer, ", \"changed\": %s}", encoded)
+	return err
+}
+
+func (e *Exporter) writeJSONRow(diff RowDiff, values []any, exists bool) error {
+	if !exists {
+		_, err := fmt.Fprint(e.writer, "null")
+		return err
+	}
+
+	fields := make([]string, len(diff.Values))
+	for i, value := range diff.Values {
+		name, err := json.Marshal(value.Column.Name)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(jsonValue(ColumnValue{Column: value.Column, Value: values[i]}))
+		if err != nil {
+			return err
+		}
+		fields[i] = string(name) + ": " + string(encoded)
+	}
+	_, err := fmt.Fprintf(e.writer, "{%s}", strings.Join(fields, ", "))
+	return err
+}
+
+// sideValues reports whether the row exists in the source and returns the
+// target values, nil when the row does not exist in the target. Values holds
+// the target row of deletes, and the target values of an update are those of
+// the source except for the changed columns.
+func sideValues(diff RowDiff) (bool, []any) {
+	switch diff.Action {
+	case RowInsert:
+		return true, nil
+	case RowDelete:
+		target := make([]any, len(diff.Values))
+		for i, value := range diff.Values {
+			target[i] = value.Value
+		}
+		return false, target
+	}
+
+	target := make([]any, len(diff.Values))
+	for i, value := range diff.Values {
+		target[i] = value.Value
+		for _, change := range diff.Changes {
+			if change.Column.Name == value.Column.Name {
+				target[i] = change.Target
+			}
+		}
+	}
+	return true, target
+}
+
+func csvValue(value ColumnValue, exists bool) string {
+	if !exists || value.Value == nil {
+		return ""
+	}
+	return FormatValue(value.Column, value.Value)
+}
+
+func jsonValue(value ColumnValue) any {
+	switch v := value.Value.(type) {
+	case nil, bool, int64, float64, string:
+		return v
+	}
+	return FormatValue(value.Column, value.Value)
+}
@@ -0,0 +1,397 @@
+package datacompare
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/victorlunam/dbgo/internal/comparator"
+	"github.com/victorlunam/dbgo/internal/database"
+)
+
+// insertRows is the number of rows of each INSERT statement, below the limit
+// of 1000 rows of a VALUES list.
+const insertRows = 100
+
+// SyncScript collects the row differences of compared tables and writes the
+// script that brings the target rows in line with the source.
+type SyncScript struct {
+	SourceDB *database.Database
+	TargetDB *database.Database
+	// BatchSize is the number of rows changed by each batch of the script
+	BatchSize int
+	tables    []syncTable
+}
+
+type syncTable struct {
+	result *TableResult
+	rows   *RowSpool
+	// unchecked tables reference themselves or are part of a cycle of
+	// foreign keys, so their constraints are disabled while rows change
+	unchecked bool
+}
+
+func NewSyncScript(sourceDB, targetDB *database.Database, batchSize int) *SyncScript {
+	return &SyncScript{
+		SourceDB:  sourceDB,
+		TargetDB:  targetDB,
+		BatchSize: batchSize,
+	}
+}
+
+// AddTable adds the row differences found by the comparison of a table.
+// The script owns the spool from then on and removes it on Close.
+func (s *SyncScript) AddTable(result *TableResult, rows *RowSpool) {
+	s.tables = append(s.tables, syncTable{result: result, rows: rows})
+}
+
+// Close removes the spooled row differences of the tables.
+func (s *SyncScript) Close() error {
+	var err error
+	for _, table := range s.tables {
+		if closeErr := table.rows.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Write writes the synchronization script. Rows are deleted first, from the
+// tables that reference others to the tables they reference, so the unique
+// values of deleted rows are free for the rows that take them. Rows are then
+// updated and inserted in the reverse order, following the foreign keys of
+// the target. The updates of a table run before its inserts, as an update
+// may free a unique value for a new row.
+func (s *SyncScript) Write(w io.Writer, references []database.TableReference) error {
+	fmt.Fprint(w, "-- Data synchronization script\n")
+	fmt.Fprint(w, "-- Rows that differ between databases\n\n")
+	fmt.Fprint(w, comparator.DatabaseMarkers(s.SourceDB, s.TargetDB))
+	fmt.Fprint(w, "\n")
+
+	tables := orderTables(s.tables, references)
+	for _, table := range tables {
+		fmt.Fprintf(w, "-- Table: %s.%s, %d to insert, %d to update, %d to delete\n", table.result.Target.Schema, table.result.Target.Name,
+			table.result.Inserted, table.result.Updated, table.result.Deleted)
+	}
+	fmt.Fprint(w, "\n")
+
+	for _, table := range tables {
+		if table.unchecked {
+			fmt.Fprintf(w, "-- %s references itself or is part of a cycle of foreign keys\n", tableName(table.result))
+			fmt.Fprintf(w, "ALTER TABLE %s NOCHECK CONSTRAINT ALL\nGO\n\n", tableName(table.result))
+		}
+	}
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := s.writeDeletes(w, tables[i]); err != nil {
+			return err
+		}
+	}
+	for _, table := range tables {
+		if err := s.writeUpdates(w, table); err != nil {
+			return err
+		}
+		if err := s.writeInserts(w, table); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range tables {
+		if table.unchecked {
+			fmt.Fprintf(w, "ALTER TABLE %s WITH CHECK CHECK CONSTRAINT ALL\nGO\n\n", tableName(table.result))
+		}
+	}
+	return nil
+}
+
+// writeInserts writes the inserts of a table in multi-row INSERT statements.
+// IDENTITY_INSERT is set in every batch, as batches may run on different
+// connections. Rows are read back from the spool one at a time, so a row is
+// written once the next one shows whether it ends its statement.
+func (s *SyncScript) writeInserts(w io.Writer, table syncTable) error {
+	if table.result.Inserted == 0 {
+		return nil
+	}
+
+	result := table.result
+	hasIdentity := false
+	for _, column := range result.Columns {
+		hasIdentity = hasIdentity || column.IsIdentity
+	}
+	columns := quoteColumns(result.TargetColumns)
+	endBatch := func() {
+		if hasIdentity {
+			fmt.Fprintf(w, "SET IDENTITY_INSERT %s OFF;\n", tableName(result))
+		}
+		fmt.Fprint(w, "GO\n\n")
+	}
+
+	fmt.Fprintf(w, "-- Rows to insert into %s: %d\n", tableName(result), result.Inserted)
+	pending := ""
+	err := s.eachRow(table, RowInsert, func(diff RowDiff, batchRow int) {
+		if pending != "" {
+			separator := ","
+			if batchRow%insertRows == 0 {
+				separator = ";"
+			}
+			fmt.Fprintf(w, "%s%s\n", pending, separator)
+			if batchRow == 0 {
+				endBatch()
+			}
+		}
+		if batchRow == 0 && hasIdentity {
+			fmt.Fprintf(w, "SET IDENTITY_INSERT %s ON;\n", tableName(result))
+		}
+		if batchRow%insertRows == 0 {
+			fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES\n", tableName(result), columns)
+		}
+
+		values := make([]string, len(diff.Values))
+		for i, value := range diff.Values {
+			values[i] = SQLLiteral(value.Column, value.Value)
+		}
+		pending = "    (" + strings.Join(values, ", ") + ")"
+	})
+	if pending != "" {
+		fmt.Fprintf(w, "%s;\n", pending)
+		endBatch()
+	}
+	return err
+}
+
+// writeUpdates writes an UPDATE statement setting the changed columns of
+// every row. Identity columns cannot be updated and are left as they are.
+func (s *SyncScript) writeUpdates(w io.Writer, table syncTable) error {
+	if table.result.Updated == 0 {
+		return nil
+	}
+
+	result := table.result
+	fmt.Fprintf(w, "-- Rows to update in %s: %d\n", tableName(result), result.Updated)
+	rows := 0
+	err := s.eachRow(table, RowUpdate, func(diff RowDiff, batchRow int) {
+		if batchRow == 0 && rows > 0 {
+			fmt.Fprint(w, "GO\n\n")
+		}
+		rows++
+
+		var assignments []string
+		for _, change := range diff.Changes {
+			name := targetColumn(result, change.Column.Name)
+			if change.Column.IsIdentity {
+				fmt.Fprintf(w, "-- The identity column %s cannot be updated, it differs in the row %s\n", name, keyCondition(result, diff.Key))
+				continue
+			}
+			assignments = append(assignments, fmt.Sprintf("%s = %s", name, SQLLiteral(change.Column, change.Source)))
+		}
+		if len(assignments) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "UPDATE %s SET %s WHERE %s;\n", tableName(result), strings.Join(assignments, ", "), keyCondition(result, diff.Key))
+	})
+	if rows > 0 {
+		fmt.Fprint(w, "GO\n\n")
+	}
+	return err
+}
+
+func (s *SyncScript) writeDeletes(w io.Writer, table syncTable) error {
+	if table.result.Deleted == 0 {
+		return nil
+	}
+
+	result := table.result
+	fmt.Fprintf(w, "-- Rows to delete from %s: %d\n", tableName(result), result.Deleted)
+	rows := 0
+	err := s.eachRow(table, RowDelete, func(diff RowDiff, batchRow int) {
+		if batchRow == 0 && rows > 0 {
+			fmt.Fprint(w, "GO\n\n")
+		}
+		rows++
+		fmt.Fprintf(w, "DELETE FROM %s WHERE %s;\n", tableName(result), keyCondition(result, diff.Key))
+	})
+	if rows > 0 {
+		fmt.Fprint(w, "GO\n\n")
+	}
+	return err
+}
+
+// eachRow calls write for every spooled row of a table with the given
+// action, along with the position of the row in its batch.
+func (s *SyncScript) eachRow(table syncTable, action RowAction, write func(diff RowDiff, batchRow int)) error {
+	size := s.BatchSize
+	if size <= 0 {
+		size = math.MaxInt
+	}
+
+	rows := 0
+	err := table.rows.each(func(diff RowDiff) {
+		if diff.Action == action {
+			write(diff, rows%size)
+			rows++
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error reading the row differences of %s: %v", tableName(table.result), err)
+	}
+	return nil
+}
+
+// orderTables sorts tables so that every table comes after the tables it
+// references. Tables that reference themselves or are part of a cycle are
+// marked unchecked, and the tables of a cycle keep their original order.
+func orderTables(tables []syncTable, references []database.TableReference) []syncTable {
+	index := make(map[string]int)
+	for i, table := range tables {
+		index[tableKey(table.result.Target.Schema, table.result.Target.Name)] = i
+	}
+
+	parents := make([]map[int]bool, len(tables))
+	for i := range parents {
+		parents[i] = make(map[int]bool)
+	}
+	for _, reference := range references {
+		child, childExists := index[tableKey(reference.Schema, reference.Name)]
+		parent, parentExists := index[tableKey(reference.ReferencedSchema, reference.ReferencedName)]
+		if !childExists || !parentExists {
+			continue
+		}
+		if child == parent {
+			tables[child].unchecked = true
+			continue
+		}
+		parents[child][parent] = true
+	}
+
+	ordered := make([]syncTable, 0, len(tables))
+	placed := make([]bool, len(tables))
+	for len(ordered) < len(tables) {
+		progress := false
+		for i, table := range tables {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for parent := range parents[i] {
+				ready = ready && placed[parent]
+			}
+			if ready {
+				ordered = append(ordered, table)
+				placed[i] = true
+				progress = true
+			}
+		}
+		if progress {
+			continue
+		}
+
+		// the remaining tables reference each other
+		for i, table := range tables {
+			if !placed[i] {
+				table.unchecked = true
+				ordered = append(ordered, table)
+				placed[i] = true
+			}
+		}
+	}
+	return ordered
+}
+
+// keyCondition returns the WHERE condition matching a row on its primary key.
+func keyCondition(result *TableResult, key []ColumnValue) string {
+	conditions := make([]string, len(key))
+	for i, value := range key {
+		conditions[i] = fmt.Sprintf("%s = %s", targetColumn(result, value.Column.Name), SQLLiteral(value.Column, value.Value))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// targetColumn returns the quoted target name of a compared column.
+func targetColumn(result *TableResult, name string) string {
+	return quoteColumns([]string{result.TargetColumns[columnIndex(result.Columns, name)]})
+}
+
+func tableName(result *TableResult) string {
+	return quoteIdentifier(result.Target.Schema) + "." + quoteIdentifier(result.Target.Name)
+}
+
+func tableKey(schema, name string) string {
+	return strings.ToLower(schema + "." + name)
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteIdentifier(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+func init() {
+	// the driver reads dates and times as time.Time values
+	gob.Register(time.Time{})
+}
+
+// RowSpool holds the row differences of a table in a temporary file, so the
+// synchronization script of large differences is not held in memory.
+type RowSpool struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *gob.Encoder
+}
+
+func NewRowSpool() (*RowSpool, error) {
+	file, err := os.CreateTemp("", "dbgo-sync-*")
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(file)
+	return &RowSpool{file: file, writer: writer, encoder: gob.NewEncoder(writer)}, nil
+}
+
+// Write adds a row difference to the spool.
+func (s *RowSpool) Write(diff RowDiff) error {
+	return s.encoder.Encode(diff)
+}
+
+// each reads the row differences back in the order they were written.
+func (s *RowSpool) each(visit func(RowDiff)) error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	decoder := gob.NewDecoder(bufio.NewReader(s.file))
+	for {
+		var diff RowDiff
+		err := decoder.Decode(&diff)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		visit(diff)
+	}
+
+	// rows written later are appended at the end of the file
+	_, err := s.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// Close removes the temporary file of the spool.
+func (s *RowSpool) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
@@ -0,0 +1,139 @@
+package datacompare
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+var (
+	idColumn   = database.Column{Name: "id", Type: "int"}
+	codeColumn = database.Column{Name: "code", Type: "nvarchar"}
+	dayColumn  = database.Column{Name: "day", Type: "date"}
+)
+
+// syncResult returns the result of a table keyed on id, with the counts of
+// the row differences spooled for it.
+func syncResult(t *testing.T, name string, diffs ...RowDiff) (*TableResult, *RowSpool) {
+	t.Helper()
+
+	result := &TableResult{
+		Target:        models.SchemaObject{Schema: "dbo", Name: name},
+		KeyColumns:    []string{"id"},
+		Columns:       []database.Column{idColumn, codeColumn, dayColumn},
+		TargetColumns: []string{"id", "code", "day"},
+	}
+	rows, err := NewRowSpool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rows.Close() })
+
+	for _, diff := range diffs {
+		switch diff.Action {
+		case RowInsert:
+			result.Inserted++
+		case RowUpdate:
+			result.Updated++
+		case RowDelete:
+			result.Deleted++
+		}
+		if err := rows.Write(diff); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return result, rows
+}
+
+func syncRow(action RowAction, id int64, code any) RowDiff {
+	values := []ColumnValue{
+		{Column: idColumn, Value: id},
+		{Column: codeColumn, Value: code},
+		{Column: dayColumn, Value: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	diff := RowDiff{Action: action, Key: values[:1], Values: values}
+	if action == RowUpdate {
+		diff.Changes = []ColumnDiff{{Column: codeColumn, Source: code, Target: "old"}}
+	}
+	return diff
+}
+
+func newTestSyncScript(batchSize int) *SyncScript {
+	source := &database.Database{Config: config.DatabaseConfig{Server: "source", Database: "app"}}
+	target := &database.Database{Config: config.DatabaseConfig{Server: "target", Database: "app"}}
+	return NewSyncScript(source, target, batchSize)
+}
+
+func TestSyncScriptOrder(t *testing.T) {
+	s := newTestSyncScript(0)
+	s.AddTable(syncResult(t, "orders", syncRow(RowInsert, 1, "a"), syncRow(RowDelete, 2, "b"), syncRow(RowUpdate, 3, "c")))
+	s.AddTable(syncResult(t, "customers", syncRow(RowInsert, 1, "a"), syncRow(RowDelete, 2, nil)))
+
+	var sb strings.Builder
+	references := []database.TableReference{{Schema: "dbo", Name: "orders", ReferencedSchema: "dbo", ReferencedName: "customers"}}
+	if err := s.Write(&sb, references); err != nil {
+		t.Fatal(err)
+	}
+	script := sb.String()
+
+	// deletes run from the child to the parent, then updates and inserts
+	// from the parent to the child
+	order := []string{
+		"DELETE FROM [dbo].[orders] WHERE [id] = 2;",
+		"DELETE FROM [dbo].[customers] WHERE [id] = 2;",
+		"INSERT INTO [dbo].[customers]",
+		"UPDATE [dbo].[orders] SET [code] = N'c' WHERE [id] = 3;",
+		"INSERT INTO [dbo].[orders]",
+	}
+	position := -1
+	for _, statement := range order {
+		index := strings.Index(script, statement)
+		if index < 0 {
+			t.Fatalf("the script has no %q:\n%s", statement, script)
+		}
+		if index < position {
+			t.Errorf("%q is out of order:\n%s", statement, script)
+		}
+		position = index
+	}
+
+	if !strings.Contains(script, "    (1, N'a', '2024-05-01');\n") {
+		t.Errorf("the inserted row was not read back from the spool:\n%s", script)
+	}
+}
+
+func TestSyncScriptInsertBatches(t *testing.T) {
+	var diffs []RowDiff
+	for id := int64(1); id <= 250; id++ {
+		diffs = append(diffs, syncRow(RowInsert, id, "x"))
+	}
+
+	s := newTestSyncScript(120)
+	s.AddTable(syncResult(t, "codes", diffs...))
+
+	var sb strings.Builder
+	if err := s.Write(&sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	script := sb.String()
+
+	// batches of 120, 120 and 10 rows, with statements of at most 100 rows
+	if got := strings.Count(script, "GO\n"); got != 3 {
+		t.Errorf("the inserts have %d batches, want 3:\n%s", got, script)
+	}
+	if got := strings.Count(script, "INSERT INTO"); got != 5 {
+		t.Errorf("the inserts have %d statements, want 5", got)
+	}
+	if got := strings.Count(script, ");\n"); got != 5 {
+		t.Errorf("%d rows end a statement, want 5", got)
+	}
+	for _, row := range []string{"    (100, N'x', '2024-05-01');\n", "    (120, N'x', '2024-05-01');\nGO\n", "    (250, N'x', '2024-05-01');\nGO\n"} {
+		if !strings.Contains(script, row) {
+			t.Errorf("the script has no %q:\n%s", row, script)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package datacompare
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/victorlunam/dbgo/internal/database"
+)
+
+// FormatValue returns the text of a column value as read by the driver,
+// which is also the form compared between databases.
+func FormatValue(column database.Column, value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		switch column.Type {
+		case "uniqueidentifier":
+			var id mssql.UniqueIdentifier
+			if err := id.Scan(v); err == nil {
+				return id.String()
+			}
+		case "decimal", "numeric", "money", "smallmoney":
+			return string(v)
+		}
+		return "0x" + strings.ToUpper(hex.EncodeToString(v))
+	case time.Time:
+		switch column.Type {
+		case "date":
+			return v.Format("2006-01-02")
+		case "time":
+			return v.Format("15:04:05.9999999")
+		case "datetimeoffset":
+			return v.Format("2006-01-02T15:04:05.9999999-07:00")
+		}
+		return v.Format("2006-01-02T15:04:05.9999999")
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// displayValue formats a value for a report, quoting text so that blanks and
+// NULL can be told apart.
+func displayValue(column database.Column, value any) string {
+	if text, ok := value.(string); ok {
+		return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+	}
+	return FormatValue(column, value)
+}
+
+// WriteRowDiff writes a row difference as a line of a report.
+func WriteRowDiff(w io.Writer, diff RowDiff) {
+	key := make([]string, len(diff.Key))
+	for i, value := range diff.Key {
+		key[i] = fmt.Sprintf("%s=%s", value.Column.Name, displayValue(value.Column, value.Value))
+	}
+
+	switch diff.Action {
+	case RowInsert:
+		values := make([]string, len(diff.Values))
+		for i, value := range diff.Values {
+			values[i] = fmt.Sprintf("%s=%s", value.Column.Name, displayValue(value.Column, value.Value))
+		}
+		fmt.Fprintf(w, "  + INSERT (%s) %s\n", strings.Join(key, ", "), strings.Join(values, ", "))
+	case RowUpdate:
+		fmt.Fprintf(w, "  ~ UPDATE (%s)\n", strings.Join(key, ", "))
+		for _, change := range diff.Changes {
+			fmt.Fprintf(w, "      %s: %s -> %s\n", change.Column.Name,
+				displayValue(change.Column, change.Target), displayValue(change.Column, change.Source))
+		}
+	case RowDelete:
+		fmt.Fprintf(w, "  - DELETE (%s)\n", strings.Join(key, ", "))
+	}
+}
+
+// WriteTableSummary writes the header of the report of a table.
+func WriteTableSummary(w io.Writer, result *TableResult) {
+	fmt.Fprintf(w, "Table %s.%s: %d to insert, %d to update, %d to delete, %d unchanged\n",
+		result.Source.Schema, result.Source.Name, result.Inserted, result.Updated, result.Deleted, result.Unchanged)
+	if len(result.SourceOnlyColumns) > 0 {
+		fmt.Fprintf(w, "  Columns only in the source, not compared: %s\n", strings.Join(result.SourceOnlyColumns, ", "))
+	}
+	if len(result.TargetOnlyColumns) > 0 {
+		fmt.Fprintf(w, "  Columns only in the target, not compared: %s\n", strings.Join(result.TargetOnlyColumns, ", "))
+	}
+	if result.SourceSize != nil {
+		fmt.Fprintf(w, "  Source size: %s\n", result.SourceSize)
+	}
+	if result.TargetSize != nil {
+		fmt.Fprintf(w, "  Target size: %s\n", result.TargetSize)
+	}
+	if checksum := result.Checksum; checksum != nil {
+		fmt.Fprintf(w, "  Rows: %d in the source, %d in the target\n", checksum.SourceRows, checksum.TargetRows)
+		fmt.Fprintf(w, "  Checksum: %d in the source, %d in the target\n", checksum.SourceChecksum, checksum.TargetChecksum)
+		fmt.Fprintf(w, "  Key ranges: %d compared by checksum, %d compared row by row\n", checksum.Ranges, checksum.MismatchedRanges)
+	}
+}
+
+// SQLLiteral returns a column value as a T-SQL literal of the column type.
+// Unicode text is written as N'text' literals, binary data as 0x literals and
+// dates in the ISO 8601 format with the precision of their type.
+func SQLLiteral(column database.Column, value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		literal := "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		switch column.Type {
+		case "nchar", "nvarchar", "ntext", "xml", "sysname":
+			return "N" + literal
+		}
+		return literal
+	case []byte:
+		switch column.Type {
+		case "uniqueidentifier":
+			return "'" + FormatValue(column, v) + "'"
+		case "decimal", "numeric", "money", "smallmoney":
+			return string(v)
+		}
+		return FormatValue(column, v)
+	case time.Time:
+		switch column.Type {
+		case "datetime":
+			return "'" + v.Format("2006-01-02T15:04:05.000") + "'"
+		case "smalldatetime":
+			return "'" + v.Format("2006-01-02T15:04:05") + "'"
+		}
+		return "'" + FormatValue(column, v) + "'"
+	}
+	return FormatValue(column, value)
+}
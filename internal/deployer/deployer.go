@@ -0,0 +1,244 @@
+package deployer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/victorlunam/dbgo/internal/comparator"
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+	"github.com/victorlunam/dbgo/internal/tsql"
+)
+
+type Deployer struct {
+	TargetDB       *database.Database
+	CompareConfig  config.CompareConfig
+	UseTransaction bool
+}
+
+// BatchError reports the batch that stopped a deployment and the script line
+// where the error was raised.
+type BatchError struct {
+	Batch tsql.Batch
+	Index int
+	Line  int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d failed at line %d: %v", e.Index, e.Line, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func NewDeployer(targetDB *database.Database, compareConfig config.CompareConfig, useTransaction bool) *Deployer {
+	targetDB.ScriptOptions = database.ScriptOptionsFromConfig(compareConfig)
+
+	return &Deployer{
+		TargetDB:       targetDB,
+		CompareConfig:  compareConfig,
+		UseTransaction: useTransaction,
+	}
+}
+
+// Script is a generated script split into batches, with the metadata of the
+// comparison it was generated from.
+type Script struct {
+	Path     string
+	Hash     string
+	Batches  []tsql.Batch
+	Metadata comparator.ScriptMetadata
+}
+
+// ReadScript reads a script file and splits it into batches. The SQLCMD
+// commands of the script are run and its variables substituted, with the
+// values of variables taking precedence over its :setvar commands. The
+// DELIMITER commands of scripts for a MySQL or MariaDB engine are removed.
+func ReadScript(path, engine string, variables map[string]string) (*Script, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading script: %v", err)
+	}
+
+	metadata, err := comparator.ParseScriptMetadata(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := tsql.PreprocessSQLCMD(string(content), variables)
+	if err != nil {
+		return nil, fmt.Errorf("error in SQLCMD script: %v", err)
+	}
+	// statements run on the server, which does not read the DELIMITER
+	// commands of MySQL scripts
+	if database.IsMySQL(engine) {
+		script = database.StripDelimiters(script)
+	}
+
+	hash := sha256.Sum256(content)
+
+	return &Script{
+		Path:     path,
+		Hash:     hex.EncodeToString(hash[:]),
+		Batches:  tsql.SplitBatches(script),
+		Metadata: metadata,
+	}, nil
+}
+
+// Deployment returns the history record of applying the script to the
+// target database.
+func (s *Script) Deployment(targetDB *database.Database, version string) *models.Deployment {
+	deployment := &models.Deployment{
+		SourceServer:   s.Metadata.SourceServer,
+		SourceDatabase: s.Metadata.SourceDatabase,
+		TargetServer:   targetDB.Config.Server + "," + targetDB.Config.Port,
+		TargetDatabase: targetDB.Config.Database,
+		Version:        version,
+		ScriptName:     filepath.Base(s.Path),
+		ScriptHash:     s.Hash,
+	}
+
+	for _, action := range s.Metadata.Actions {
+		deployment.Objects = append(deployment.Objects, models.DeploymentObject{
+			Schema: action.Schema,
+			Name:   action.Name,
+			Type:   action.Type,
+			Action: action.Kind,
+			Risk:   action.Risk,
+		})
+	}
+
+	return deployment
+}
+
+// SkipDriftGuard removes the drift guard batch from the script.
+func (s *Script) SkipDriftGuard() {
+	batches := []tsql.Batch{}
+	for _, batch := range s.Batches {
+		if !database.IsDriftGuard(batch.SQL) {
+			batches = append(batches, batch)
+		}
+	}
+	s.Batches = batches
+}
+
+// VerifyFingerprints compares the target objects with the fingerprints
+// recorded when the script was generated and describes every object that
+// was created, dropped or modified since.
+func (d *Deployer) VerifyFingerprints(fingerprints []comparator.ObjectFingerprint) ([]string, error) {
+	var drifts []string
+
+	for _, fingerprint := range fingerprints {
+		name := fmt.Sprintf("%s.%s", fingerprint.Schema, fingerprint.Name)
+
+		obj, exists, err := d.TargetDB.FindObject(fingerprint.Schema, fingerprint.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up %s: %v", name, err)
+		}
+
+		switch {
+		case !fingerprint.Exists && exists:
+			drifts = append(drifts, fmt.Sprintf("%s was created", name))
+			continue
+		case fingerprint.Exists && !exists:
+			drifts = append(drifts, fmt.Sprintf("%s was dropped", name))
+			continue
+		case !exists:
+			continue
+		}
+
+		definition, err := d.TargetDB.GetObjectDefinition(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error getting definition of %s: %v", name, err)
+		}
+
+		if comparator.Fingerprint(d.CompareConfig, obj, definition) != fingerprint.Hash {
+			drifts = append(drifts, fmt.Sprintf("%s definition changed (modified %s)", name, obj.ModifyDate.Format("2006-01-02 15:04:05")))
+		} else if !obj.ModifyDate.Truncate(time.Millisecond).Equal(fingerprint.ModifyDate.Truncate(time.Millisecond)) {
+			drifts = append(drifts, fmt.Sprintf("%s was modified on %s", name, obj.ModifyDate.Format("2006-01-02 15:04:05")))
+		}
+	}
+
+	return drifts, nil
+}
+
+// PrintPlan prints the batches that Apply would execute.
+func PrintPlan(batches []tsql.Batch, useTransaction bool) {
+	if useTransaction {
+		color.Cyan("The script runs in a single transaction with XACT_ABORT ON")
+	} else {
+		color.Cyan("The script runs with XACT_ABORT ON, each batch is committed on its own")
+	}
+
+	for i, batch := range batches {
+		repeat := ""
+		if batch.Count > 1 {
+			repeat = fmt.Sprintf(" (x%d)", batch.Count)
+		}
+		fmt.Printf("[%d/%d] line %d%s: %s\n", i+1, len(batches), batch.StartLine, repeat, batch.Summary())
+	}
+}
+
+// Apply executes the batches in order against the target database and stops
+// at the first error. All batches run on the same connection, so session
+// settings and the optional wrapping transaction span the whole script.
+// Scripts for other engines than SQL Server have no batch separators and run
+// as a single batch.
+func (d *Deployer) Apply(batches []tsql.Batch) error {
+	ctx := context.Background()
+
+	conn, err := d.TargetDB.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	transaction := d.TargetDB.Dialect.Transaction()
+	if transaction.Setup != "" {
+		if _, err := conn.ExecContext(ctx, transaction.Setup); err != nil {
+			return err
+		}
+	}
+
+	if d.UseTransaction {
+		if _, err := conn.ExecContext(ctx, transaction.Begin); err != nil {
+			return err
+		}
+	}
+
+	for i, batch := range batches {
+		color.Cyan("[%d/%d] Executing batch at line %d: %s", i+1, len(batches), batch.StartLine, batch.Summary())
+
+		for run := 0; run < batch.Count; run++ {
+			if _, err := conn.ExecContext(ctx, batch.SQL); err != nil {
+				if d.UseTransaction {
+					conn.ExecContext(ctx, transaction.Rollback)
+				}
+
+				line := batch.StartLine
+				if errorLine, ok := database.ErrorLine(err); ok {
+					line += errorLine - 1
+				}
+
+				return &BatchError{Batch: batch, Index: i + 1, Line: line, Err: err}
+			}
+		}
+	}
+
+	if d.UseTransaction {
+		if _, err := conn.ExecContext(ctx, transaction.Commit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package deployer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/victorlunam/dbgo/internal/comparator"
+	"github.com/victorlunam/dbgo/internal/config"
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+// openSQLite connects to a new database file with the given statements run.
+func openSQLite(t *testing.T, statements string) *database.Database {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := database.Connect(config.DatabaseConfig{Engine: database.EngineSQLite, Database: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if _, err := d.DB.Exec(statements); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestVerifyFingerprints(t *testing.T) {
+	targetDB := openSQLite(t, `
+		CREATE TABLE orders (id integer PRIMARY KEY, total real);
+		CREATE VIEW big_orders AS SELECT id FROM orders WHERE total > 100;
+		CREATE TABLE hotfix (id integer);
+		CREATE TABLE customers (id integer PRIMARY KEY);
+	`)
+	compareConfig := config.CompareConfig{}
+
+	fingerprint := func(name, objectType string) comparator.ObjectFingerprint {
+		obj := models.SchemaObject{Schema: "main", Name: name, Type: objectType}
+		definition, err := targetDB.GetObjectDefinition(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return comparator.ObjectFingerprint{
+			Schema: "main",
+			Name:   name,
+			Type:   objectType,
+			Exists: true,
+			Hash:   comparator.Fingerprint(compareConfig, obj, definition),
+		}
+	}
+
+	unchanged := fingerprint("orders", "USER_TABLE")
+	changed := fingerprint("big_orders", "VIEW")
+	changed.Hash = "0000"
+	// same definition, with a modify date the target no longer reports
+	touched := fingerprint("customers", "USER_TABLE")
+	touched.ModifyDate = time.Date(2024, 5, 1, 13, 45, 30, 0, time.UTC)
+	dropped := unchanged
+	dropped.Name = "archived_orders"
+	created := comparator.ObjectFingerprint{Schema: "main", Name: "hotfix", Type: "USER_TABLE"}
+	absent := comparator.ObjectFingerprint{Schema: "main", Name: "new_orders", Type: "USER_TABLE"}
+
+	deploy := NewDeployer(targetDB, compareConfig, false)
+	drifts, err := deploy.VerifyFingerprints([]comparator.ObjectFingerprint{unchanged, changed, touched, dropped, created, absent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"main.big_orders definition changed (modified 0001-01-01 00:00:00)",
+		"main.customers was modified on 0001-01-01 00:00:00",
+		"main.archived_orders was dropped",
+		"main.hotfix was created",
+	}
+	if !reflect.DeepEqual(drifts, want) {
+		t.Errorf("VerifyFingerprints = %q, want %q", drifts, want)
+	}
+}
+
+func TestScriptDeployment(t *testing.T) {
+	content := "-- dbgo:source [dev-sql,1433].[SalesDev]\n" +
+		"-- dbgo:target [prod-sql,1433].[Sales]\n" +
+		"-- dbgo:action MISSING SAFE VIEW [dbo].[active_orders]\n" +
+		"-- dbgo:action CHANGED DESTRUCTIVE USER_TABLE [dbo].[orders]\n" +
+		"CREATE VIEW [dbo].[active_orders] AS SELECT 1 AS id\n" +
+		"GO\n"
+	path := filepath.Join(t.TempDir(), "deploy.sql")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := ReadScript(path, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targetDB := &database.Database{Config: config.DatabaseConfig{Server: "prod-sql", Port: "1433", Database: "Sales"}}
+	deployment := script.Deployment(targetDB, "1.4.0")
+
+	hash := sha256.Sum256([]byte(content))
+	want := &models.Deployment{
+		SourceServer:   "dev-sql,1433",
+		SourceDatabase: "SalesDev",
+		TargetServer:   "prod-sql,1433",
+		TargetDatabase: "Sales",
+		Version:        "1.4.0",
+		ScriptName:     "deploy.sql",
+		ScriptHash:     hex.EncodeToString(hash[:]),
+		Objects: []models.DeploymentObject{
+			{Schema: "dbo", Name: "active_orders", Type: "VIEW", Action: models.DiffMissing, Risk: models.RiskSafe},
+			{Schema: "dbo", Name: "orders", Type: "USER_TABLE", Action: models.DiffChanged, Risk: models.RiskDestructive},
+		},
+	}
+	if !reflect.DeepEqual(deployment, want) {
+		t.Errorf("Deployment =\n%+v\nwant\n%+v", deployment, want)
+	}
+}
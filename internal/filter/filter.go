@@ -0,0 +1,202 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule matches objects by type, schema and name. A rule is written as
+// [TYPE:][schema.]name, where schema and name are globs using * or % for any
+// sequence and ? for a single character, or regular expressions between
+// slashes. A rule without a schema matches every schema.
+type Rule struct {
+	Type   string
+	Schema *regexp.Regexp
+	Name   *regexp.Regexp
+	Text   string
+}
+
+type Filter struct {
+	Include []Rule
+	Exclude []Rule
+}
+
+var typePrefix = regexp.MustCompile(`^([A-Za-z_]+):`)
+
+func ParseRule(text string) (Rule, error) {
+	rule := Rule{Text: text}
+	pattern := strings.TrimSpace(text)
+
+	if match := typePrefix.FindStringSubmatch(pattern); match != nil {
+		rule.Type = strings.ToUpper(match[1])
+		pattern = pattern[len(match[0]):]
+	}
+
+	schemaPattern, namePattern := "", pattern
+	if i := splitIndex(pattern); i >= 0 {
+		schemaPattern, namePattern = pattern[:i], pattern[i+1:]
+	}
+
+	if namePattern == "" {
+		return rule, fmt.Errorf("invalid filter rule %q: missing object name", text)
+	}
+
+	var err error
+	if schemaPattern != "" {
+		if rule.Schema, err = compilePattern(schemaPattern); err != nil {
+			return rule, fmt.Errorf("invalid filter rule %q: %v", text, err)
+		}
+	}
+	if rule.Name, err = compilePattern(namePattern); err != nil {
+		return rule, fmt.Errorf("invalid filter rule %q: %v", text, err)
+	}
+
+	return rule, nil
+}
+
+// splitIndex returns the position of the dot separating schema and name,
+// skipping dots inside a regular expression.
+func splitIndex(pattern string) int {
+	if strings.HasPrefix(pattern, "/") {
+		end := strings.Index(pattern[1:], "/")
+		if end < 0 {
+			return -1
+		}
+		end++
+		if end+1 < len(pattern) && pattern[end+1] == '.' {
+			return end + 1
+		}
+		return -1
+	}
+	return strings.Index(pattern, ".")
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+
+	var sb strings.Builder
+	sb.WriteString("(?i)^")
+	for _, r := range pattern {
+		switch r {
+		case '*', '%':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+
+	return regexp.Compile(sb.String())
+}
+
+// Matches reports whether the rule applies to an object. objectType is the
+// object type as shown in the selector (TABLE, VIEW...) and typeDesc the
+// sys.objects type description, either of which can satisfy the rule type.
+func (r Rule) Matches(schema, name, objectType, typeDesc string) bool {
+	if r.Type != "" && r.Type != objectType && r.Type != typeDesc {
+		return false
+	}
+	if r.Schema != nil && !r.Schema.MatchString(schema) {
+		return false
+	}
+	return r.Name.MatchString(name)
+}
+
+// New parses the include and exclude rules of a filter.
+func New(include, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	if err := f.AddInclude(include...); err != nil {
+		return nil, err
+	}
+	if err := f.AddExclude(exclude...); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Filter) AddInclude(rules ...string) error {
+	for _, text := range rules {
+		rule, err := ParseRule(text)
+		if err != nil {
+			return err
+		}
+		f.Include = append(f.Include, rule)
+	}
+	return nil
+}
+
+func (f *Filter) AddExclude(rules ...string) error {
+	for _, text := range rules {
+		rule, err := ParseRule(text)
+		if err != nil {
+			return err
+		}
+		f.Exclude = append(f.Exclude, rule)
+	}
+	return nil
+}
+
+// LoadIgnoreFile adds the rules of an ignore file as exclusions. Each line
+// holds one rule, blank lines and lines starting with # are skipped. A
+// missing file is not an error.
+func (f *Filter) LoadIgnoreFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := f.AddExclude(line); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Allows reports whether an object passes the filter: it must match one of
+// the include rules, if any, and none of the exclude rules.
+func (f *Filter) Allows(schema, name, objectType, typeDesc string) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Include) > 0 {
+		included := false
+		for _, rule := range f.Include {
+			if rule.Matches(schema, name, objectType, typeDesc) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, rule := range f.Exclude {
+		if rule.Matches(schema, name, objectType, typeDesc) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		text       string
+		ruleType   string
+		matches    [][2]string
+		nonMatches [][2]string
+	}{
+		{
+			text:       "dbo.Orders",
+			matches:    [][2]string{{"dbo", "Orders"}, {"DBO", "orders"}},
+			nonMatches: [][2]string{{"sales", "Orders"}, {"dbo", "Orders2"}},
+		},
+		{
+			text:       "tmp_*",
+			matches:    [][2]string{{"dbo", "tmp_a"}, {"sales", "TMP_"}},
+			nonMatches: [][2]string{{"dbo", "a_tmp_b"}},
+		},
+		{
+			text:       "sales.%_log?",
+			matches:    [][2]string{{"sales", "audit_log1"}},
+			nonMatches: [][2]string{{"sales", "audit_log"}, {"dbo", "audit_log1"}},
+		},
+		{
+			text:       "PROCEDURE:dbo.usp_*",
+			ruleType:   "PROCEDURE",
+			matches:    [][2]string{{"dbo", "usp_get"}},
+			nonMatches: [][2]string{{"dbo", "get"}},
+		},
+		{
+			text:       "/^(dbo|sales)$/./^v\\d+\\.x$/",
+			matches:    [][2]string{{"sales", "v12.x"}},
+			nonMatches: [][2]string{{"hr", "v12.x"}, {"dbo", "V1.x"}},
+		},
+		{
+			text:       "/a.b/",
+			matches:    [][2]string{{"dbo", "aXb"}},
+			nonMatches: [][2]string{{"dbo", "ab"}},
+		},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseRule(test.text)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", test.text, err)
+			continue
+		}
+		if rule.Type != test.ruleType {
+			t.Errorf("ParseRule(%q).Type = %q, want %q", test.text, rule.Type, test.ruleType)
+		}
+		for _, object := range test.matches {
+			if !rule.Matches(object[0], object[1], "PROCEDURE", "SQL_STORED_PROCEDURE") {
+				t.Errorf("rule %q does not match %s.%s", test.text, object[0], object[1])
+			}
+		}
+		for _, object := range test.nonMatches {
+			if rule.Matches(object[0], object[1], "PROCEDURE", "SQL_STORED_PROCEDURE") {
+				t.Errorf("rule %q matches %s.%s", test.text, object[0], object[1])
+			}
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, text := range []string{"", "dbo.", "TABLE:", "/[/", "dbo./(/"} {
+		if _, err := ParseRule(text); err == nil {
+			t.Errorf("ParseRule(%q) returned no error", text)
+		}
+	}
+}
+
+func TestRuleTypes(t *testing.T) {
+	rule, err := ParseRule("user_table:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rule.Matches("dbo", "t", "TABLE", "USER_TABLE") {
+		t.Error("the rule does not match its sys.objects type description")
+	}
+	if rule.Matches("dbo", "v", "VIEW", "VIEW") {
+		t.Error("the rule matches another type")
+	}
+}
+
+func TestFilterAllows(t *testing.T) {
+	f, err := New([]string{"dbo.*", "TABLE:sales.*"}, []string{"*_old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		schema, name, objectType string
+		want                     bool
+	}{
+		{"dbo", "orders", "TABLE", true},
+		{"dbo", "orders_old", "TABLE", false},
+		{"sales", "invoices", "TABLE", true},
+		{"sales", "v_invoices", "VIEW", false},
+		{"hr", "people", "TABLE", false},
+	}
+	for _, test := range tests {
+		if got := f.Allows(test.schema, test.name, test.objectType, ""); got != test.want {
+			t.Errorf("Allows(%s.%s %s) = %t, want %t", test.schema, test.name, test.objectType, got, test.want)
+		}
+	}
+
+	var none *Filter
+	if !none.Allows("dbo", "t", "TABLE", "USER_TABLE") {
+		t.Error("a nil filter does not allow every object")
+	}
+}
+
+func TestLoadIgnoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".dbgoignore")
+	content := "# generated objects\n\ndbo.tmp_*\n  VIEW:*_v  \n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &Filter{}
+	if err := f.LoadIgnoreFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Exclude) != 2 || f.Exclude[1].Type != "VIEW" {
+		t.Fatalf("exclude rules = %+v", f.Exclude)
+	}
+
+	if err := f.LoadIgnoreFile(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("a missing ignore file returned %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("dbo.ok\ndbo./(/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := (&Filter{}).LoadIgnoreFile(path)
+	if err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("LoadIgnoreFile error = %v, want one at line 2", err)
+	}
+}
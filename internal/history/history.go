@@ -0,0 +1,158 @@
+package history
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+
+	"github.com/victorlunam/dbgo/internal/database"
+	"github.com/victorlunam/dbgo/internal/models"
+)
+
+const (
+	OutcomeRunning   = "RUNNING"
+	OutcomeSucceeded = "SUCCEEDED"
+	OutcomeFailed    = "FAILED"
+
+	historyTable = "dbgo_deployment_history"
+	objectsTable = "dbgo_deployment_objects"
+)
+
+// Store records deployments in history tables of the target database, with
+// the statements of its dialect.
+type Store struct {
+	DB         *database.Database
+	Schema     string
+	statements database.HistoryStatements
+}
+
+// NewStore returns the history store of a database whose dialect records the
+// history, as reported by RecordsHistory.
+func NewStore(db *database.Database, schema string) *Store {
+	statements, _ := db.HistoryStatements(schema, historyTable, objectsTable)
+	return &Store{
+		DB:         db,
+		Schema:     schema,
+		statements: statements,
+	}
+}
+
+// EnsureTables creates the history tables when they do not exist yet.
+func (s *Store) EnsureTables() error {
+	_, err := s.DB.DB.Exec(s.statements.CreateTables)
+	return err
+}
+
+// Exists reports whether the history tables were created in the database.
+func (s *Store) Exists() (bool, error) {
+	var exists bool
+	err := s.DB.DB.QueryRow(s.statements.TablesExist).Scan(&exists)
+	return exists, err
+}
+
+// Start records a running deployment with its objects and sets its run id.
+func (s *Store) Start(deployment *models.Deployment) error {
+	runID, err := newRunID()
+	if err != nil {
+		return err
+	}
+	deployment.RunID = runID
+	deployment.Outcome = OutcomeRunning
+
+	tx, err := s.DB.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(s.statements.InsertDeployment, deployment.RunID, deployment.SourceServer, deployment.SourceDatabase,
+		deployment.TargetServer, deployment.TargetDatabase, deployment.Version, deployment.ScriptName, deployment.ScriptHash,
+		deployment.Outcome)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range deployment.Objects {
+		_, err = tx.Exec(s.statements.InsertObject, deployment.RunID, obj.Schema, obj.Name, obj.Type, string(obj.Action), string(obj.Risk))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Finish records the outcome of a deployment.
+func (s *Store) Finish(runID, outcome, errorMessage string) error {
+	_, err := s.DB.DB.Exec(s.statements.FinishDeployment, runID, outcome, errorMessage)
+	return err
+}
+
+func scanDeployment(scan func(dest ...any) error) (models.Deployment, error) {
+	var deployment models.Deployment
+	var finishedAt sql.NullTime
+
+	err := scan(&deployment.RunID, &deployment.SourceServer, &deployment.SourceDatabase, &deployment.TargetServer,
+		&deployment.TargetDatabase, &deployment.Version, &deployment.ScriptName, &deployment.ScriptHash,
+		&deployment.StartedAt, &finishedAt, &deployment.Outcome, &deployment.ErrorMessage, &deployment.AppliedBy)
+	if finishedAt.Valid {
+		deployment.FinishedAt = finishedAt.Time
+	}
+
+	return deployment, err
+}
+
+// List returns the most recent deployments, newest first.
+func (s *Store) List(limit int) ([]models.Deployment, error) {
+	rows, err := s.DB.DB.Query(s.statements.ListDeployments, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deployments []models.Deployment
+	for rows.Next() {
+		deployment, err := scanDeployment(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, deployment)
+	}
+
+	return deployments, rows.Err()
+}
+
+// Get returns a deployment with its objects.
+func (s *Store) Get(runID string) (models.Deployment, error) {
+	deployment, err := scanDeployment(s.DB.DB.QueryRow(s.statements.GetDeployment, runID).Scan)
+	if err != nil {
+		return deployment, err
+	}
+
+	rows, err := s.DB.DB.Query(s.statements.GetObjects, runID)
+	if err != nil {
+		return deployment, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var obj models.DeploymentObject
+		if err := rows.Scan(&obj.Schema, &obj.Name, &obj.Type, &obj.Action, &obj.Risk); err != nil {
+			return deployment, err
+		}
+		deployment.Objects = append(deployment.Objects, obj)
+	}
+
+	return deployment, rows.Err()
+}
+
+// newRunID returns a random version 4 UUID.
+func newRunID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
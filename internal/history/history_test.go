@@ -0,0 +1,71 @@
+package history
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewRunID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		runID, err := newRunID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !uuid.MatchString(runID) {
+			t.Fatalf("newRunID = %q, want a version 4 UUID", runID)
+		}
+		if seen[runID] {
+			t.Fatalf("newRunID returned %q twice", runID)
+		}
+		seen[runID] = true
+	}
+}
+
+func TestScanDeployment(t *testing.T) {
+	startedAt := time.Date(2024, 5, 1, 13, 45, 0, 0, time.UTC)
+	finishedAt := startedAt.Add(time.Minute)
+
+	// scan sets the columns of a history row in the order of the queries
+	scan := func(finished sql.NullTime) func(dest ...any) error {
+		return func(dest ...any) error {
+			values := []any{"run", "src,1433", "SalesDev", "tgt,1433", "Sales", "dev", "deploy.sql", "abc",
+				startedAt, finished, OutcomeSucceeded, "", "deployer"}
+			for i, value := range values {
+				switch d := dest[i].(type) {
+				case *string:
+					*d = value.(string)
+				case *time.Time:
+					*d = value.(time.Time)
+				case *sql.NullTime:
+					*d = value.(sql.NullTime)
+				}
+			}
+			return nil
+		}
+	}
+
+	deployment, err := scanDeployment(scan(sql.NullTime{Time: finishedAt, Valid: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Version != "dev" || deployment.TargetDatabase != "Sales" || deployment.AppliedBy != "deployer" {
+		t.Errorf("scanDeployment = %+v", deployment)
+	}
+	if !deployment.StartedAt.Equal(startedAt) || !deployment.FinishedAt.Equal(finishedAt) {
+		t.Errorf("scanDeployment times = %v, %v, want %v, %v", deployment.StartedAt, deployment.FinishedAt, startedAt, finishedAt)
+	}
+
+	// a running deployment has no finish time yet
+	running, err := scanDeployment(scan(sql.NullTime{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !running.FinishedAt.IsZero() {
+		t.Errorf("FinishedAt of a running deployment = %v, want zero", running.FinishedAt)
+	}
+}
@@ -1,15 +1,165 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
+type DiffKind string
+
+const (
+	DiffMissing    DiffKind = "MISSING"
+	DiffChanged    DiffKind = "CHANGED"
+	DiffCaseRename DiffKind = "CASE_RENAME"
+)
+
+// ChangeRisk classifies the impact of applying a difference to the target.
+type ChangeRisk string
+
+const (
+	RiskSafe        ChangeRisk = "SAFE"
+	RiskRisky       ChangeRisk = "RISKY"
+	RiskDestructive ChangeRisk = "DESTRUCTIVE"
+)
+
 type SchemaObject struct {
-	Name    string
-	Type    string
-	Schema  string
-	Content string
+	Name       string
+	Type       string
+	Schema     string
+	Content    string
+	ModifyDate time.Time
 }
 
 type DiffResult struct {
 	Object           SchemaObject
+	TargetObject     SchemaObject
+	Kind             DiffKind
 	Exists           bool
 	HasDifferences   bool
 	DifferenceScript string
+	TargetDefinition string
+	// Fingerprint is the hash of the normalized target definition, empty when
+	// the object does not exist in the target
+	Fingerprint string
+	// TargetNewer is set when the target object was modified after the source
+	// one, which usually means a hotfix was applied directly to the target
+	TargetNewer bool
+	// Excluded results are reported but left out of the generated script
+	Excluded bool
+	Risk     ChangeRisk
+	// TargetRowCount is the number of rows in a target table that is dropped
+	TargetRowCount int64
+	// RemovedColumns lists the target table columns missing in the source
+	RemovedColumns []string
+	// Warnings describe the changes made to the source definition for the
+	// target engine version and the features the target lacks
+	Warnings []string
+	// RollbackScript is the statement that reverts the result when it
+	// cannot be derived from the target definition, as for rebuilt tables
+	RollbackScript string
+	// SourceSize and TargetSize are set for tables when the partition stats
+	// of the database can be read
+	SourceSize *TableSize
+	TargetSize *TableSize
+}
+
+// TableSize is the row count and space of a table, with its indexes.
+type TableSize struct {
+	Rows       int64
+	ReservedKB int64
+	UsedKB     int64
+}
+
+func (s TableSize) String() string {
+	return fmt.Sprintf("%d rows, %s reserved, %s used", s.Rows, FormatKB(s.ReservedKB), FormatKB(s.UsedKB))
+}
+
+// DatabaseSize is the space allocated to the files of a database and the
+// totals of its user tables.
+type DatabaseSize struct {
+	DataKB int64
+	LogKB  int64
+	Tables TableSize
+}
+
+func (s DatabaseSize) String() string {
+	return fmt.Sprintf("%s data files, %s log files, user tables: %s", FormatKB(s.DataKB), FormatKB(s.LogKB), s.Tables)
+}
+
+// FormatKB formats a size in kilobytes with the largest fitting unit.
+func FormatKB(kb int64) string {
+	size := float64(kb)
+	for _, unit := range []string{"KB", "MB", "GB"} {
+		if size < 1024 {
+			if unit == "KB" {
+				return fmt.Sprintf("%d KB", kb)
+			}
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%.1f TB", size)
+}
+
+// TableStructure is the shape of a table independent of its engine, used to
+// compare tables whose definitions are written in different dialects.
+type TableStructure struct {
+	Columns []ColumnStructure
+	// PrimaryKey lists the key columns in key order, empty without one
+	PrimaryKey  []string
+	Indexes     []IndexStructure
+	ForeignKeys []ForeignKeyStructure
+}
+
+type ColumnStructure struct {
+	Name string
+	// Type is the name of the data type without its length or precision
+	Type     string
+	Nullable bool
+	// Length is the maximum length of string and binary types, -1 when
+	// they are unlimited and 0 when the engine reports none
+	Length int
+}
+
+// IndexStructure is an index or unique constraint other than the primary
+// key. Expression columns have an empty name.
+type IndexStructure struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+type ForeignKeyStructure struct {
+	Name              string
+	Columns           []string
+	ReferencedSchema  string
+	ReferencedTable   string
+	ReferencedColumns []string
+}
+
+// Deployment is a run of the apply command recorded in the target history.
+type Deployment struct {
+	RunID          string
+	SourceServer   string
+	SourceDatabase string
+	TargetServer   string
+	TargetDatabase string
+	Version        string
+	ScriptName     string
+	ScriptHash     string
+	StartedAt      time.Time
+	// FinishedAt is zero while the deployment is running
+	FinishedAt   time.Time
+	Outcome      string
+	ErrorMessage string
+	AppliedBy    string
+	Objects      []DeploymentObject
+}
+
+type DeploymentObject struct {
+	Schema string
+	Name   string
+	Type   string
+	Action DiffKind
+	Risk   ChangeRisk
 }
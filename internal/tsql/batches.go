@@ -0,0 +1,118 @@
+package tsql
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Batch struct {
+	SQL string
+	// StartLine is the 1-based script line where the batch starts
+	StartLine int
+	// Count is the number of times the batch runs, as given by "GO n"
+	Count int
+}
+
+// SplitBatches splits a script on GO separators. GO must be alone on its
+// line, optionally followed by a repeat count and a comment, and is ignored
+// inside comments and string literals.
+func SplitBatches(script string) []Batch {
+	var batches []Batch
+	var sb strings.Builder
+	startLine := 1
+
+	flush := func(count int, nextLine int) {
+		if strings.TrimSpace(sb.String()) != "" {
+			batches = append(batches, Batch{SQL: sb.String(), StartLine: startLine, Count: count})
+		}
+		sb.Reset()
+		startLine = nextLine
+	}
+
+	for _, line := range splitLines(Tokenize(script)) {
+		if count, ok := separatorCount(line.tokens); ok {
+			flush(count, line.number+1)
+			continue
+		}
+		for _, token := range line.tokens {
+			sb.WriteString(token.Text)
+		}
+	}
+	flush(1, 0)
+
+	return batches
+}
+
+// Summary returns the first line of the batch that is not blank or a
+// comment, which is enough to recognize it in progress messages.
+func (b Batch) Summary() string {
+	for _, line := range splitLines(Tokenize(b.SQL)) {
+		var sb strings.Builder
+		for _, token := range line.tokens {
+			if token.Kind != Newline && !token.IsComment() {
+				sb.WriteString(token.Text)
+			}
+		}
+		if text := strings.TrimSpace(sb.String()); text != "" {
+			// truncate on characters, not bytes, so names in other
+			// alphabets are not cut in the middle of a character
+			if runes := []rune(text); len(runes) > 80 {
+				text = string(runes[:77]) + "..."
+			}
+			return text
+		}
+	}
+	return ""
+}
+
+type tokenLine struct {
+	number int
+	tokens []Token
+}
+
+// splitLines groups tokens by line, keeping the line break with the line it
+// ends. Tokens spanning several lines belong to the line where they start.
+func splitLines(tokens []Token) []tokenLine {
+	var lines []tokenLine
+	current := tokenLine{number: 1}
+	for _, token := range tokens {
+		if len(current.tokens) == 0 {
+			current.number = token.Line
+		}
+		current.tokens = append(current.tokens, token)
+		if token.Kind == Newline {
+			lines = append(lines, current)
+			current = tokenLine{}
+		}
+	}
+	if len(current.tokens) > 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+func separatorCount(tokens []Token) (int, bool) {
+	var significant []Token
+	for _, token := range tokens {
+		if token.Kind == Whitespace || token.Kind == Newline || token.Kind == LineComment {
+			continue
+		}
+		significant = append(significant, token)
+	}
+
+	if len(significant) == 0 || len(significant) > 2 {
+		return 0, false
+	}
+	if significant[0].Kind != Word || !strings.EqualFold(significant[0].Text, "GO") {
+		return 0, false
+	}
+	if len(significant) == 1 {
+		return 1, true
+	}
+
+	count, err := strconv.Atoi(significant[1].Text)
+	if significant[1].Kind != Number || err != nil || count < 1 {
+		return 0, false
+	}
+	return count, true
+}
@@ -0,0 +1,52 @@
+package tsql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitBatches(t *testing.T) {
+	script := "CREATE TABLE t (id int)\n" +
+		"GO\n" +
+		"INSERT INTO t VALUES (1)\n" +
+		"  go 3 -- repeat\n" +
+		"SELECT 'GO\nGO'\n" +
+		"/*\nGO\n*/\n" +
+		"SELECT 1 GO\n" +
+		"GO\n" +
+		"GO\n"
+
+	want := []Batch{
+		{SQL: "CREATE TABLE t (id int)\n", StartLine: 1, Count: 1},
+		{SQL: "INSERT INTO t VALUES (1)\n", StartLine: 3, Count: 3},
+		{SQL: "SELECT 'GO\nGO'\n/*\nGO\n*/\nSELECT 1 GO\n", StartLine: 5, Count: 1},
+	}
+
+	if got := SplitBatches(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitBatches =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSplitBatchesWithoutSeparators(t *testing.T) {
+	script := "CREATE TABLE t (id integer);\nCREATE INDEX i ON t (id);\n"
+
+	want := []Batch{{SQL: script, StartLine: 1, Count: 1}}
+	if got := SplitBatches(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitBatches = %+v, want %+v", got, want)
+	}
+}
+
+func TestBatchSummary(t *testing.T) {
+	batch := Batch{SQL: "-- comment\n\n/* block */\n  CREATE VIEW v AS SELECT 1\n"}
+	if got, want := batch.Summary(), "CREATE VIEW v AS SELECT 1"; got != want {
+		t.Errorf("Summary = %q, want %q", got, want)
+	}
+
+	long := Batch{SQL: "SELECT N'" + strings.Repeat("é", 100) + "'\n"}
+	want := "SELECT N'" + strings.Repeat("é", 68) + "..."
+	if got := long.Summary(); got != want || !utf8.ValidString(got) {
+		t.Errorf("Summary = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package tsql
+
+import "strings"
+
+// SetCreateOrAlter rewrites the leading CREATE of a module definition as
+// CREATE OR ALTER, or the other way around, leaving the rest of the
+// definition unchanged.
+func SetCreateOrAlter(definition string, createOrAlter bool) string {
+	tokens := Tokenize(definition)
+	significant := significantTokens(tokens)
+	if len(significant) == 0 || !isWord(tokens[significant[0]], "CREATE") {
+		return definition
+	}
+
+	create := significant[0]
+	hasOrAlter := len(significant) > 2 && isWord(tokens[significant[1]], "OR") && isWord(tokens[significant[2]], "ALTER")
+
+	switch {
+	case createOrAlter && !hasOrAlter:
+		tokens[create].Text += " OR ALTER"
+	case !createOrAlter && hasOrAlter:
+		for i := create + 1; i <= significant[2]; i++ {
+			tokens[i].Text = ""
+		}
+	}
+
+	return joinText(tokens)
+}
+
+// RemoveOption removes every "name = value" item of a WITH (...) option list,
+// such as OPTIMIZE_FOR_SEQUENTIAL_KEY = OFF, along with its comma. A list
+// left empty is removed with its WITH keyword. It reports whether the option
+// was found.
+func RemoveOption(sql, name string) (string, bool) {
+	tokens := Tokenize(sql)
+	found := false
+
+	for {
+		significant := significantTokens(tokens)
+		at := -1
+		for i := 0; i+2 < len(significant); i++ {
+			if isWord(tokens[significant[i]], name) && tokens[significant[i+1]].Text == "=" {
+				at = i
+				break
+			}
+		}
+		if at < 0 {
+			break
+		}
+		found = true
+
+		// the option spans its name, the equal sign and the value
+		first, last := at, at+2
+		switch {
+		case at > 0 && tokens[significant[at-1]].Text == ",":
+			first--
+		case last+1 < len(significant) && tokens[significant[last+1]].Text == ",":
+			last++
+		case at > 1 && tokens[significant[at-1]].Text == "(" && isWord(tokens[significant[at-2]], "WITH") &&
+			last+1 < len(significant) && tokens[significant[last+1]].Text == ")":
+			first, last = at-2, last+1
+		}
+
+		for i := significant[first]; i <= significant[last]; i++ {
+			tokens[i].Text = ""
+		}
+	}
+
+	return joinText(tokens), found
+}
+
+// significantTokens returns the indexes of the tokens that are not
+// whitespace or comments.
+func significantTokens(tokens []Token) []int {
+	var indexes []int
+	for i, token := range tokens {
+		if token.Kind != Whitespace && token.Kind != Newline && !token.IsComment() && token.Text != "" {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func isWord(token Token, word string) bool {
+	return token.Kind == Word && strings.EqualFold(token.Text, word)
+}
+
+func joinText(tokens []Token) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString(token.Text)
+	}
+	return sb.String()
+}
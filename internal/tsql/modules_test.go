@@ -0,0 +1,60 @@
+package tsql
+
+import "testing"
+
+func TestSetCreateOrAlter(t *testing.T) {
+	tests := []struct {
+		definition    string
+		createOrAlter bool
+		want          string
+	}{
+		{"CREATE PROCEDURE p AS SELECT 1", true, "CREATE OR ALTER PROCEDURE p AS SELECT 1"},
+		{"CREATE OR ALTER PROCEDURE p AS SELECT 1", true, "CREATE OR ALTER PROCEDURE p AS SELECT 1"},
+		{"create  or\n alter view v AS SELECT 1", false, "create view v AS SELECT 1"},
+		{"CREATE VIEW v AS SELECT 1", false, "CREATE VIEW v AS SELECT 1"},
+		{"-- header\n/* CREATE */ CREATE FUNCTION f() RETURNS int AS BEGIN RETURN 1 END", true,
+			"-- header\n/* CREATE */ CREATE OR ALTER FUNCTION f() RETURNS int AS BEGIN RETURN 1 END"},
+		{"ALTER PROCEDURE p AS SELECT 1", true, "ALTER PROCEDURE p AS SELECT 1"},
+		{"", true, ""},
+	}
+
+	for _, test := range tests {
+		if got := SetCreateOrAlter(test.definition, test.createOrAlter); got != test.want {
+			t.Errorf("SetCreateOrAlter(%q, %v) = %q, want %q", test.definition, test.createOrAlter, got, test.want)
+		}
+	}
+}
+
+func TestRemoveOption(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantFound bool
+	}{
+		{
+			"CREATE INDEX ix ON t (a) WITH (PAD_INDEX = OFF, OPTIMIZE_FOR_SEQUENTIAL_KEY = OFF, FILLFACTOR = 90)",
+			"CREATE INDEX ix ON t (a) WITH (PAD_INDEX = OFF, FILLFACTOR = 90)", true,
+		},
+		{
+			"CREATE INDEX ix ON t (a) WITH (optimize_for_sequential_key = ON, PAD_INDEX = OFF)",
+			"CREATE INDEX ix ON t (a) WITH ( PAD_INDEX = OFF)", true,
+		},
+		{
+			"CREATE INDEX ix ON t (a) WITH (OPTIMIZE_FOR_SEQUENTIAL_KEY = OFF) ON [PRIMARY]",
+			"CREATE INDEX ix ON t (a)  ON [PRIMARY]", true,
+		},
+		{
+			"CONSTRAINT pk PRIMARY KEY (id) WITH (OPTIMIZE_FOR_SEQUENTIAL_KEY = OFF),\n" +
+				"CONSTRAINT uq UNIQUE (code) WITH (OPTIMIZE_FOR_SEQUENTIAL_KEY = OFF, PAD_INDEX = OFF)",
+			"CONSTRAINT pk PRIMARY KEY (id) ,\nCONSTRAINT uq UNIQUE (code) WITH ( PAD_INDEX = OFF)", true,
+		},
+		{"CREATE INDEX ix ON t (a) WITH (PAD_INDEX = OFF)", "CREATE INDEX ix ON t (a) WITH (PAD_INDEX = OFF)", false},
+	}
+
+	for _, test := range tests {
+		got, found := RemoveOption(test.sql, "OPTIMIZE_FOR_SEQUENTIAL_KEY")
+		if got != test.want || found != test.wantFound {
+			t.Errorf("RemoveOption(%q) = %q, %v, want %q, %v", test.sql, got, found, test.want, test.wantFound)
+		}
+	}
+}
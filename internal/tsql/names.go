@@ -0,0 +1,187 @@
+package tsql
+
+import "strings"
+
+// objectKeywords holds the words that are followed by an object name, such
+// as FROM dbo.Orders or EXEC dbo.GetUser.
+var objectKeywords = toSet(`
+FROM JOIN APPLY INTO UPDATE DELETE MERGE USING EXEC EXECUTE REFERENCES TABLE
+VIEW PROCEDURE PROC FUNCTION TRIGGER SYNONYM SEQUENCE TYPE TRANSFER FOR
+`)
+
+// listKeywords holds the words that start a comma-separated list of object
+// names, as in FROM dbo.Orders o, dbo.Lines l or DROP TABLE a.t1, a.t2.
+var listKeywords = toSet(`FROM TABLE VIEW PROCEDURE PROC FUNCTION TRIGGER`)
+
+// MapNames rewrites the schema and database parts of multi-part object names,
+// such as Sales.dbo.Orders, using the given mappings. Only names in object
+// positions are rewritten: after FROM, JOIN, INTO, UPDATE, EXEC, REFERENCES
+// and similar words, in FROM lists, in function calls and as the type of a
+// variable, so columns qualified by an alias, such as hr.Salary, are kept.
+// Mappings match names without regard to case, and each replaced part keeps
+// its quoting style.
+func MapNames(sql string, schemas, databases map[string]string) string {
+	tokens := Tokenize(sql)
+	significant := significantTokens(tokens)
+
+	// lists tells, for each parenthesis depth, whether a comma starts
+	// another object name
+	lists := []bool{false}
+	previous := -1
+
+	for n := 0; n < len(significant); n++ {
+		i := significant[n]
+		token := tokens[i]
+
+		switch {
+		case token.Kind == Symbol && token.Text == "(":
+			lists = append(lists, false)
+		case token.Kind == Symbol && token.Text == ")":
+			if len(lists) > 1 {
+				lists = lists[:len(lists)-1]
+			}
+		case token.Kind == Word && IsKeyword(token.Text):
+			word := strings.ToUpper(token.Text)
+			top := len(lists) - 1
+			lists[top] = listKeywords[word] || (lists[top] && (word == "AS" || word == "WITH"))
+		case isNamePart(token) && (i == 0 || !isDot(tokens[i-1])):
+			parts := qualifiedNameParts(tokens, i)
+			last := lastPart(parts, i)
+			for n+1 < len(significant) && significant[n+1] <= last {
+				n++
+			}
+			next := -1
+			if n+1 < len(significant) {
+				next = significant[n+1]
+			}
+
+			if isObjectName(tokens, previous, next, len(parts), lists[len(lists)-1]) {
+				if len(parts) >= 2 {
+					mapNamePart(tokens, parts[len(parts)-2], schemas)
+				}
+				if len(parts) >= 3 {
+					mapNamePart(tokens, parts[len(parts)-3], databases)
+				}
+			}
+			i = last
+		}
+		previous = i
+	}
+
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString(token.Text)
+	}
+	return sb.String()
+}
+
+// isObjectName reports whether a name of the given number of parts, between
+// the significant tokens at previous and next, names an object.
+func isObjectName(tokens []Token, previous, next, parts int, inList bool) bool {
+	opensParenthesis := next >= 0 && tokens[next].Kind == Symbol && tokens[next].Text == "("
+	if parts == 2 && opensParenthesis {
+		// a call such as dbo.GetTotal(@id)
+		return true
+	}
+	if previous < 0 {
+		return false
+	}
+
+	token := tokens[previous]
+	switch {
+	case token.Kind == Symbol && token.Text == ",":
+		return inList
+	case token.Kind != Word:
+		return false
+	case strings.HasPrefix(token.Text, "@"):
+		// the type of a variable or parameter, as in @lines dbo.LineList
+		return true
+	case isWord(token, "ON"):
+		// the table of an index, trigger or permission, but not a join
+		// condition
+		if opensParenthesis {
+			return true
+		}
+		if next < 0 {
+			return false
+		}
+		for _, word := range []string{"FOR", "AFTER", "INSTEAD", "WITH", "TO", "FROM"} {
+			if isWord(tokens[next], word) {
+				return true
+			}
+		}
+		return false
+	}
+	return objectKeywords[strings.ToUpper(token.Text)]
+}
+
+// qualifiedNameParts returns the token index of each part of the name that
+// starts at start, with -1 for parts omitted as in Sales..Orders.
+func qualifiedNameParts(tokens []Token, start int) []int {
+	parts := []int{start}
+	for i := start + 1; i < len(tokens) && isDot(tokens[i]); i++ {
+		if i+1 < len(tokens) && isNamePart(tokens[i+1]) {
+			parts = append(parts, i+1)
+			i++
+		} else if i+1 < len(tokens) && isDot(tokens[i+1]) {
+			parts = append(parts, -1)
+		} else {
+			break
+		}
+	}
+	return parts
+}
+
+func lastPart(parts []int, fallback int) int {
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] >= 0 {
+			return parts[i]
+		}
+	}
+	return fallback
+}
+
+func mapNamePart(tokens []Token, index int, mapping map[string]string) {
+	if index < 0 {
+		return
+	}
+
+	name := tokens[index].Text
+	if tokens[index].Kind == QuotedIdentifier {
+		name = UnquoteIdentifier(name)
+	}
+
+	for from, to := range mapping {
+		if !strings.EqualFold(name, from) {
+			continue
+		}
+		tokens[index].Text = quoteLike(tokens[index], to)
+		return
+	}
+}
+
+// quoteLike returns name quoted in the style of the identifier token it
+// replaces, adding brackets when name is not a regular identifier.
+func quoteLike(token Token, name string) string {
+	switch {
+	case strings.HasPrefix(token.Text, `"`):
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	case token.Kind == QuotedIdentifier || !regularIdentifier.MatchString(name) || IsKeyword(name):
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	}
+	return name
+}
+
+func isNamePart(token Token) bool {
+	switch token.Kind {
+	case QuotedIdentifier:
+		return true
+	case Word:
+		return !strings.HasPrefix(token.Text, "@") && !IsKeyword(token.Text)
+	}
+	return false
+}
+
+func isDot(token Token) bool {
+	return token.Kind == Symbol && token.Text == "."
+}
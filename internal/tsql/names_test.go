@@ -0,0 +1,74 @@
+package tsql
+
+import "testing"
+
+func TestMapNames(t *testing.T) {
+	schemas := map[string]string{"hr": "people", "dbo": "app"}
+	databases := map[string]string{"SalesDev": "Sales"}
+
+	tests := []struct {
+		name, sql, want string
+	}{
+		{
+			"alias named like a schema",
+			"SELECT hr.Salary FROM hr.Employees hr WHERE hr.Active = 1",
+			"SELECT hr.Salary FROM people.Employees hr WHERE hr.Active = 1",
+		},
+		{
+			"database and join",
+			"SELECT o.id FROM SalesDev.dbo.Orders o JOIN [dbo].[Lines] l ON l.order_id = o.id",
+			"SELECT o.id FROM Sales.app.Orders o JOIN [app].[Lines] l ON l.order_id = o.id",
+		},
+		{
+			"from list",
+			"SELECT * FROM hr.A a WITH (NOLOCK), (SELECT hr.x FROM hr.B hr) b, hr.C WHERE hr.y = 1",
+			"SELECT * FROM people.A a WITH (NOLOCK), (SELECT hr.x FROM people.B hr) b, people.C WHERE hr.y = 1",
+		},
+		{
+			"select list",
+			"SELECT hr.a, hr.b INTO hr.Copy FROM t hr",
+			"SELECT hr.a, hr.b INTO people.Copy FROM t hr",
+		},
+		{
+			"statements",
+			"INSERT INTO hr.Log (id) VALUES (1); UPDATE hr.Log SET hr.id = 2; EXEC dbo.Notify @id = 1",
+			"INSERT INTO people.Log (id) VALUES (1); UPDATE people.Log SET hr.id = 2; EXEC app.Notify @id = 1",
+		},
+		{
+			"function call and variable type",
+			"DECLARE @rows hr.RowList; SELECT dbo.Total(hr.amount) FROM t hr",
+			"DECLARE @rows people.RowList; SELECT app.Total(hr.amount) FROM t hr",
+		},
+		{
+			"index and trigger tables",
+			"CREATE INDEX ix ON hr.Employees (id)\nCREATE TRIGGER hr.t ON hr.Employees AFTER INSERT AS SELECT 1",
+			"CREATE INDEX ix ON people.Employees (id)\nCREATE TRIGGER people.t ON people.Employees AFTER INSERT AS SELECT 1",
+		},
+		{
+			"references and drop list",
+			"CREATE TABLE hr.T (id int REFERENCES hr.P (id), hr int)\nDROP TABLE hr.A, hr.B",
+			"CREATE TABLE people.T (id int REFERENCES people.P (id), hr int)\nDROP TABLE people.A, people.B",
+		},
+		{
+			"comments and strings",
+			"SELECT 'hr.x' FROM /* hr.y */ hr.z",
+			"SELECT 'hr.x' FROM /* hr.y */ people.z",
+		},
+	}
+
+	for _, test := range tests {
+		if got := MapNames(test.sql, schemas, databases); got != test.want {
+			t.Errorf("%s: MapNames =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMapNamesQuoting(t *testing.T) {
+	schemas := map[string]string{"app_dev": "app data"}
+
+	got := MapNames(`SELECT * FROM APP_DEV.t1 JOIN "app_dev".t2 ON 1 = 1`, schemas, nil)
+	want := `SELECT * FROM [app data].t1 JOIN "app data".t2 ON 1 = 1`
+	if got != want {
+		t.Errorf("MapNames = %s, want %s", got, want)
+	}
+}
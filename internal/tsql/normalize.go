@@ -0,0 +1,129 @@
+package tsql
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Options selects which differences Normalize should ignore.
+type Options struct {
+	StripComments            bool
+	FoldKeywordCase          bool
+	UnquoteIdentifiers       bool
+	IgnoreTrailingSemicolons bool
+	IgnoreBlankLines         bool
+	// IgnoreWhitespace also treats line breaks as insignificant whitespace
+	IgnoreWhitespace bool
+}
+
+var regularIdentifier = regexp.MustCompile(`^[\p{L}_@#][\p{L}\p{N}_@#$]*$`)
+
+// Normalize rewrites a T-SQL definition into a canonical form so that two
+// definitions which only differ in formatting compare equal. Tokens on the
+// same line are separated by exactly one space and line breaks are kept.
+func Normalize(sql string, opts Options) string {
+	tokens := Tokenize(sql)
+
+	var lines [][]string
+	var current []string
+
+	for i, token := range tokens {
+		switch token.Kind {
+		case Whitespace:
+			continue
+		case Newline:
+			if opts.IgnoreWhitespace {
+				continue
+			}
+			lines = append(lines, current)
+			current = nil
+			continue
+		case LineComment, BlockComment:
+			if opts.StripComments {
+				continue
+			}
+		}
+
+		text := token.Text
+		switch {
+		case token.Kind == Word && opts.FoldKeywordCase && IsKeyword(text):
+			text = strings.ToUpper(text)
+		case token.Kind == String && opts.FoldKeywordCase && text[0] == 'n':
+			text = "N" + text[1:]
+		case token.Kind == QuotedIdentifier && opts.UnquoteIdentifiers:
+			text = unquoteIdentifier(text)
+		case token.Kind == Symbol && text == ";" && opts.IgnoreTrailingSemicolons && endsLine(tokens[i+1:]):
+			continue
+		}
+
+		current = append(current, text)
+	}
+	lines = append(lines, current)
+
+	var result []string
+	for _, line := range lines {
+		if len(line) == 0 && opts.IgnoreBlankLines {
+			continue
+		}
+		result = append(result, joinTokens(line))
+	}
+
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
+
+// UnquoteIdentifier returns the name inside a bracket or double-quote
+// delimited identifier.
+func UnquoteIdentifier(text string) string {
+	if len(text) < 2 {
+		return text
+	}
+	switch text[0] {
+	case '[':
+		return strings.ReplaceAll(text[1:len(text)-1], "]]", "]")
+	case '"':
+		return strings.ReplaceAll(text[1:len(text)-1], `""`, `"`)
+	}
+	return text
+}
+
+// unquoteIdentifier drops the delimiters of identifiers that would be valid
+// without them and uses brackets for the rest.
+func unquoteIdentifier(text string) string {
+	name := UnquoteIdentifier(text)
+	if regularIdentifier.MatchString(name) && !IsKeyword(name) {
+		return name
+	}
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// endsLine reports whether only whitespace and comments remain before the
+// next line break.
+func endsLine(tokens []Token) bool {
+	for _, token := range tokens {
+		switch {
+		case token.Kind == Newline:
+			return true
+		case token.Kind == Whitespace, token.IsComment() && !strings.Contains(token.Text, "\n"):
+			continue
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// joinTokens joins the tokens of a line with single spaces, except around
+// qualifying dots and inside parentheses and lists where spacing is noise.
+func joinTokens(tokens []string) string {
+	var sb strings.Builder
+	for i, token := range tokens {
+		if i > 0 {
+			previous := tokens[i-1]
+			if previous != "." && previous != "(" && token != "." && token != ")" && token != "," && token != ";" {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(token)
+	}
+	return sb.String()
+}
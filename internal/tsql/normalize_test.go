@@ -0,0 +1,72 @@
+package tsql
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	all := Options{
+		StripComments:            true,
+		FoldKeywordCase:          true,
+		UnquoteIdentifiers:       true,
+		IgnoreTrailingSemicolons: true,
+		IgnoreBlankLines:         true,
+	}
+
+	tests := []struct {
+		name string
+		sql  string
+		opts Options
+		want string
+	}{
+		{
+			name: "spacing",
+			sql:  "SELECT  a ,b\tFROM [dbo] . [t]  ( x )",
+			want: "SELECT a, b FROM [dbo].[t] (x)",
+		},
+		{
+			name: "all options",
+			sql:  "create view [dbo].[v] as -- note\n\n\nselect [id], [select], [a b] from t; /* c */\n",
+			opts: all,
+			want: "CREATE VIEW dbo.v AS\nSELECT id, [select], [a b] FROM t",
+		},
+		{
+			name: "unicode strings",
+			sql:  "select n'x'",
+			opts: Options{FoldKeywordCase: true},
+			want: "SELECT N'x'",
+		},
+		{
+			name: "semicolons inside a line are kept",
+			sql:  "SELECT 1; SELECT 2;\n",
+			opts: all,
+			want: "SELECT 1; SELECT 2",
+		},
+		{
+			name: "whitespace",
+			sql:  "SELECT a\n  FROM t",
+			opts: Options{IgnoreWhitespace: true},
+			want: "SELECT a FROM t",
+		},
+	}
+
+	for _, test := range tests {
+		if got := Normalize(test.sql, test.opts); got != test.want {
+			t.Errorf("%s: Normalize = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUnquoteIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"[a]]b]":   "a]b",
+		`"a""b"`:   `a"b`,
+		"plain":    "plain",
+		"[]":       "",
+		"[select]": "select",
+	}
+
+	for text, want := range tests {
+		if got := UnquoteIdentifier(text); got != want {
+			t.Errorf("UnquoteIdentifier(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
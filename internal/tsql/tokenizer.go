@@ -0,0 +1,171 @@
+package tsql
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type TokenKind int
+
+const (
+	Whitespace TokenKind = iota
+	Newline
+	LineComment
+	BlockComment
+	String
+	QuotedIdentifier
+	Word
+	Number
+	Symbol
+)
+
+type Token struct {
+	Kind TokenKind
+	Text string
+	// Line is the 1-based line where the token starts
+	Line int
+}
+
+// IsComment reports whether the token is a line or block comment.
+func (t Token) IsComment() bool {
+	return t.Kind == LineComment || t.Kind == BlockComment
+}
+
+// Tokenize splits a T-SQL text into tokens. Concatenating the text of every
+// token returns the original input, so callers can rewrite single tokens and
+// rebuild the script.
+func Tokenize(sql string) []Token {
+	var tokens []Token
+	line := 1
+
+	for pos := 0; pos < len(sql); {
+		start := pos
+		kind := Symbol
+		r, size := utf8.DecodeRuneInString(sql[pos:])
+
+		switch {
+		case r == '\n' || r == '\r':
+			kind = Newline
+			if strings.HasPrefix(sql[pos:], "\r\n") {
+				pos += 2
+			} else {
+				pos++
+			}
+		case unicode.IsSpace(r):
+			kind = Whitespace
+			pos = scanWhile(sql, pos, func(r rune) bool { return r != '\n' && r != '\r' && unicode.IsSpace(r) })
+		case strings.HasPrefix(sql[pos:], "--"):
+			kind = LineComment
+			pos = scanWhile(sql, pos, func(r rune) bool { return r != '\n' && r != '\r' })
+		case strings.HasPrefix(sql[pos:], "/*"):
+			kind = BlockComment
+			pos = scanBlockComment(sql, pos)
+		case r == '\'':
+			kind = String
+			pos = scanQuoted(sql, pos, '\'')
+		case (r == 'N' || r == 'n') && strings.HasPrefix(sql[pos+1:], "'"):
+			kind = String
+			pos = scanQuoted(sql, pos+1, '\'')
+		case r == '[':
+			kind = QuotedIdentifier
+			pos = scanQuoted(sql, pos, ']')
+		case r == '"':
+			kind = QuotedIdentifier
+			pos = scanQuoted(sql, pos, '"')
+		case unicode.IsDigit(r) || (r == '.' && pos+1 < len(sql) && sql[pos+1] >= '0' && sql[pos+1] <= '9'):
+			kind = Number
+			pos = scanNumber(sql, pos)
+		case isWordStart(r):
+			kind = Word
+			pos = scanWhile(sql, pos, isWordPart)
+		default:
+			pos += size
+		}
+
+		text := sql[start:pos]
+		tokens = append(tokens, Token{Kind: kind, Text: text, Line: line})
+		line += countNewlines(text)
+	}
+
+	return tokens
+}
+
+func isWordStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '@' || r == '#'
+}
+
+func isWordPart(r rune) bool {
+	return isWordStart(r) || unicode.IsDigit(r) || r == '$'
+}
+
+func scanWhile(sql string, pos int, accept func(rune) bool) int {
+	for pos < len(sql) {
+		r, size := utf8.DecodeRuneInString(sql[pos:])
+		if !accept(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+// scanBlockComment scans a /* */ comment, which T-SQL allows to be nested.
+func scanBlockComment(sql string, pos int) int {
+	depth := 0
+	for pos < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[pos:], "/*"):
+			depth++
+			pos += 2
+		case strings.HasPrefix(sql[pos:], "*/"):
+			depth--
+			pos += 2
+			if depth == 0 {
+				return pos
+			}
+		default:
+			pos++
+		}
+	}
+	return pos
+}
+
+// scanQuoted scans a delimited token starting at pos, where a doubled closing
+// delimiter is an escaped one.
+func scanQuoted(sql string, pos int, closing byte) int {
+	pos++
+	for pos < len(sql) {
+		if sql[pos] == closing {
+			if pos+1 < len(sql) && sql[pos+1] == closing {
+				pos += 2
+				continue
+			}
+			return pos + 1
+		}
+		pos++
+	}
+	return pos
+}
+
+func scanNumber(sql string, pos int) int {
+	if strings.HasPrefix(sql[pos:], "0x") || strings.HasPrefix(sql[pos:], "0X") {
+		return scanWhile(sql, pos+2, func(r rune) bool { return unicode.Is(unicode.ASCII_Hex_Digit, r) })
+	}
+
+	pos = scanWhile(sql, pos, func(r rune) bool { return unicode.IsDigit(r) || r == '.' })
+	if pos < len(sql) && (sql[pos] == 'e' || sql[pos] == 'E') {
+		next := pos + 1
+		if next < len(sql) && (sql[next] == '+' || sql[next] == '-') {
+			next++
+		}
+		if next < len(sql) && sql[next] >= '0' && sql[next] <= '9' {
+			pos = scanWhile(sql, next, unicode.IsDigit)
+		}
+	}
+	return pos
+}
+
+func countNewlines(text string) int {
+	return strings.Count(text, "\n") + strings.Count(text, "\r") - strings.Count(text, "\r\n")
+}
@@ -0,0 +1,68 @@
+package tsql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizeKinds(t *testing.T) {
+	sql := "SELECT N'it''s', [a]]b], \"c\" -- note\r\n/* outer /* inner */ still */ 0x1F 1.5e3 @id"
+
+	var got []Token
+	for _, token := range Tokenize(sql) {
+		if token.Kind != Whitespace {
+			got = append(got, token)
+		}
+	}
+
+	want := []Token{
+		{Word, "SELECT", 1},
+		{String, "N'it''s'", 1},
+		{Symbol, ",", 1},
+		{QuotedIdentifier, "[a]]b]", 1},
+		{Symbol, ",", 1},
+		{QuotedIdentifier, `"c"`, 1},
+		{LineComment, "-- note", 1},
+		{Newline, "\r\n", 1},
+		{BlockComment, "/* outer /* inner */ still */", 2},
+		{Number, "0x1F", 2},
+		{Number, "1.5e3", 2},
+		{Word, "@id", 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Tokenize returned %d tokens, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTokenizeRoundTrip(t *testing.T) {
+	scripts := []string{
+		"CREATE PROCEDURE dbo.p AS\nBEGIN\n    SELECT 'a\nb' -- c\nEND\n",
+		"SELECT 'unterminated",
+		"/* unterminated comment",
+		"SELECT [x\r\n",
+	}
+
+	for _, script := range scripts {
+		var sb strings.Builder
+		for _, token := range Tokenize(script) {
+			sb.WriteString(token.Text)
+		}
+		if sb.String() != script {
+			t.Errorf("tokens of %q join to %q", script, sb.String())
+		}
+	}
+}
+
+func TestTokenizeLines(t *testing.T) {
+	tokens := Tokenize("SELECT 'a\nb'\nFROM t")
+
+	last := tokens[len(tokens)-1]
+	if last.Text != "t" || last.Line != 3 {
+		t.Errorf("last token = %+v, want t on line 3", last)
+	}
+}
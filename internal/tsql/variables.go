@@ -0,0 +1,216 @@
+package tsql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// variablePattern matches a SQLCMD-style $(name) reference
+var variablePattern = regexp.MustCompile(`\$\(([A-Za-z_][A-Za-z0-9_]*)\)`)
+
+// SubstituteVariables replaces the $(name) references of a script with the
+// values of variables. As in SQLCMD, names are case-insensitive and references
+// are replaced everywhere, including comments and string literals. A reference
+// to an undefined variable is an error.
+func SubstituteVariables(script string, variables map[string]string) (string, error) {
+	values := make(map[string]string, len(variables))
+	for name, value := range variables {
+		values[strings.ToLower(name)] = value
+	}
+
+	undefined := map[string]string{}
+	result := variablePattern.ReplaceAllStringFunc(script, func(reference string) string {
+		name := variablePattern.FindStringSubmatch(reference)[1]
+		value, ok := values[strings.ToLower(name)]
+		if !ok {
+			if _, seen := undefined[strings.ToLower(name)]; !seen {
+				undefined[strings.ToLower(name)] = name
+			}
+			return reference
+		}
+		return value
+	})
+
+	if len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for _, name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return script, fmt.Errorf("undefined variables: %s", strings.Join(names, ", "))
+	}
+
+	return result, nil
+}
+
+// ParameterizeVariables replaces the database and server names that are the
+// value of a variable with a $(name) reference, so definitions from
+// environments with different values compare equal.
+func ParameterizeVariables(sql string, variables map[string]string) string {
+	return replaceIdentifiers(sql, variables, func(token Token, name string) string {
+		reference := "$(" + name + ")"
+		if token.Kind == QuotedIdentifier {
+			return quoteLike(token, reference)
+		}
+		return reference
+	})
+}
+
+// ReplaceVariableValues replaces the database and server names that are the
+// value of a variable in from with the value of the same variable in to.
+// Variables that to does not define are left unchanged.
+func ReplaceVariableValues(sql string, from, to map[string]string) string {
+	return replaceIdentifiers(sql, from, func(token Token, name string) string {
+		for toName, value := range to {
+			if strings.EqualFold(toName, name) {
+				return quoteLike(token, value)
+			}
+		}
+		return token.Text
+	})
+}
+
+// replaceIdentifiers rewrites the database and server parts of multi-part
+// names, such as Sales.dbo.Orders or Remote.Sales.dbo.Orders, that match a
+// variable value, ignoring case. Columns, tables and schemas named like a
+// value are left alone. When several variables share a value, the first name
+// in sorted order is used.
+func replaceIdentifiers(sql string, variables map[string]string, replace func(token Token, name string) string) string {
+	if len(variables) == 0 {
+		return sql
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	replacePart := func(tokens []Token, index int) {
+		if index < 0 {
+			return
+		}
+		identifier := tokens[index].Text
+		if tokens[index].Kind == QuotedIdentifier {
+			identifier = UnquoteIdentifier(identifier)
+		}
+		for _, name := range names {
+			if value := variables[name]; value != "" && strings.EqualFold(identifier, value) {
+				tokens[index].Text = replace(tokens[index], name)
+				return
+			}
+		}
+	}
+
+	tokens := Tokenize(sql)
+	for i := 0; i < len(tokens); i++ {
+		if !isNamePart(tokens[i]) || (i > 0 && isDot(tokens[i-1])) {
+			continue
+		}
+
+		parts := qualifiedNameParts(tokens, i)
+		if len(parts) >= 3 {
+			replacePart(tokens, parts[len(parts)-3])
+		}
+		if len(parts) >= 4 {
+			replacePart(tokens, parts[len(parts)-4])
+		}
+		i = lastPart(parts, i)
+	}
+
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString(token.Text)
+	}
+	return sb.String()
+}
+
+// PreprocessSQLCMD runs the SQLCMD commands of a script and substitutes its
+// $(name) references. :setvar defines a variable, overridden by the values in
+// overrides, and :on error is accepted since scripts always stop at the first
+// error. Command lines are blanked so line numbers are kept. A script without
+// commands is not a SQLCMD mode script and is returned unchanged.
+func PreprocessSQLCMD(script string, overrides map[string]string) (string, error) {
+	variables := map[string]string{}
+	hasCommands := false
+
+	var sb strings.Builder
+	for _, line := range splitLines(Tokenize(script)) {
+		command, ok := sqlcmdCommand(line.tokens)
+		if !ok {
+			for _, token := range line.tokens {
+				sb.WriteString(token.Text)
+			}
+			continue
+		}
+		hasCommands = true
+
+		fields := strings.Fields(command)
+		switch {
+		case len(fields) >= 2 && strings.EqualFold(fields[0], ":setvar"):
+			rest := strings.TrimSpace(command[len(fields[0]):])
+			value, err := setvarValue(strings.TrimSpace(rest[len(fields[1]):]))
+			if err != nil {
+				return script, fmt.Errorf("line %d: %v", line.number, err)
+			}
+			variables[fields[1]] = value
+		case len(fields) == 3 && strings.EqualFold(fields[0], ":on") && strings.EqualFold(fields[1], "error"):
+		default:
+			return script, fmt.Errorf("line %d: unsupported SQLCMD command %s", line.number, fields[0])
+		}
+
+		if last := line.tokens[len(line.tokens)-1]; last.Kind == Newline {
+			sb.WriteString(last.Text)
+		}
+	}
+
+	if !hasCommands {
+		return script, nil
+	}
+
+	for name, value := range overrides {
+		for defined := range variables {
+			if strings.EqualFold(defined, name) {
+				delete(variables, defined)
+			}
+		}
+		variables[name] = value
+	}
+
+	return SubstituteVariables(sb.String(), variables)
+}
+
+// sqlcmdCommand returns the text of a line that starts with a SQLCMD command.
+func sqlcmdCommand(tokens []Token) (string, bool) {
+	var sb strings.Builder
+	started := false
+	for _, token := range tokens {
+		if !started {
+			if token.Kind == Whitespace {
+				continue
+			}
+			if token.Kind != Symbol || token.Text != ":" {
+				return "", false
+			}
+			started = true
+		}
+		if token.Kind != Newline {
+			sb.WriteString(token.Text)
+		}
+	}
+	return strings.TrimSpace(sb.String()), started
+}
+
+// setvarValue reads the value of a :setvar command, which may be written in
+// double quotes with doubled inner quotes.
+func setvarValue(text string) (string, error) {
+	if !strings.HasPrefix(text, `"`) {
+		return text, nil
+	}
+	if len(text) < 2 || !strings.HasSuffix(text, `"`) {
+		return "", fmt.Errorf("unterminated :setvar value %s", text)
+	}
+	return strings.ReplaceAll(text[1:len(text)-1], `""`, `"`), nil
+}
@@ -0,0 +1,83 @@
+package tsql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreprocessSQLCMD(t *testing.T) {
+	script := ":setvar DatabaseName \"Sales \"\"EU\"\"\"\n" +
+		":on error exit\n" +
+		"SELECT * FROM [$(databasename)].dbo.t\n" +
+		"GO\n" +
+		"  :SETVAR Region west\n" +
+		"SELECT '$(Region)'\n"
+
+	got, err := PreprocessSQLCMD(script, map[string]string{"region": "east"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\n\nSELECT * FROM [Sales \"EU\"].dbo.t\nGO\n\nSELECT 'east'\n"
+	if got != want {
+		t.Errorf("PreprocessSQLCMD =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPreprocessSQLCMDWithoutCommands(t *testing.T) {
+	// scripts that are not in SQLCMD mode may contain $( in their text
+	script := "SELECT '$(not_a_variable)'\n"
+
+	got, err := PreprocessSQLCMD(script, nil)
+	if err != nil || got != script {
+		t.Errorf("PreprocessSQLCMD = %q, %v, want the script unchanged", got, err)
+	}
+}
+
+func TestPreprocessSQLCMDErrors(t *testing.T) {
+	tests := map[string]string{
+		":setvar a 1\nSELECT $(b)\n":       "undefined variables: b",
+		":connect server\n":                "line 1: unsupported SQLCMD command :connect",
+		"SELECT 1\n:setvar a \"unclosed\n": "line 2: unterminated :setvar value",
+		":setvar a 1\n:r other.sql\n":      "line 2: unsupported SQLCMD command :r",
+		":setvar a 1\nSELECT $(B), $(c)\n": "undefined variables: B, c",
+	}
+
+	for script, want := range tests {
+		_, err := PreprocessSQLCMD(script, nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("PreprocessSQLCMD(%q) error = %v, want %q", script, err, want)
+		}
+	}
+}
+
+func TestParameterizeVariables(t *testing.T) {
+	variables := map[string]string{"DatabaseName": "Sales", "LinkedServer": "REPORTS01"}
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM Sales.dbo.Orders", "SELECT * FROM $(DatabaseName).dbo.Orders"},
+		{"SELECT * FROM [sales]..[Orders]", "SELECT * FROM [$(DatabaseName)]..[Orders]"},
+		{"SELECT * FROM REPORTS01.Sales.dbo.Orders", "SELECT * FROM $(LinkedServer).$(DatabaseName).dbo.Orders"},
+		{"SELECT Sales FROM dbo.Sales s WHERE s.Sales > 0", "SELECT Sales FROM dbo.Sales s WHERE s.Sales > 0"},
+		{"EXEC Sales.dbo.usp_refresh @Sales = 1", "EXEC $(DatabaseName).dbo.usp_refresh @Sales = 1"},
+	}
+
+	for _, test := range tests {
+		if got := ParameterizeVariables(test.sql, variables); got != test.want {
+			t.Errorf("ParameterizeVariables(%q) = %q, want %q", test.sql, got, test.want)
+		}
+	}
+}
+
+func TestReplaceVariableValues(t *testing.T) {
+	from := map[string]string{"DatabaseName": "Sales"}
+	to := map[string]string{"databasename": "Sales QA"}
+
+	got := ReplaceVariableValues("SELECT Sales FROM Sales.dbo.Sales", from, to)
+	if want := "SELECT Sales FROM [Sales QA].dbo.Sales"; got != want {
+		t.Errorf("ReplaceVariableValues = %q, want %q", got, want)
+	}
+}